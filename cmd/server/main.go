@@ -2,8 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	_ "time/tzdata" // schedule time zones; the alpine runtime image ships no zoneinfo
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -12,6 +20,7 @@ import (
 	"github.com/gsarma/tusker/internal/crypto"
 	"github.com/gsarma/tusker/internal/store"
 	"github.com/gsarma/tusker/internal/worker"
+	"github.com/gsarma/tusker/internal/workflow"
 )
 
 func main() {
@@ -25,6 +34,84 @@ func main() {
 		log.Fatal("ROOT_ENCRYPTION_KEY is required")
 	}
 
+	// How long to wait for in-flight jobs and HTTP requests on SIGTERM/SIGINT.
+	shutdownTimeout := 30 * time.Second
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("invalid SHUTDOWN_TIMEOUT: %v", err)
+		}
+		shutdownTimeout = d
+	}
+
+	// Default cap on concurrently running jobs per tenant; 0 means no cap.
+	tenantConcurrency := 0
+	if v := os.Getenv("TENANT_MAX_CONCURRENT_JOBS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("invalid TENANT_MAX_CONCURRENT_JOBS: %q", v)
+		}
+		tenantConcurrency = n
+	}
+
+	// Consecutive provider failures that open a tenant's circuit for the
+	// provider, and how long it stays open; 0 keeps the worker's defaults.
+	breakerThreshold := 0
+	if v := os.Getenv("CIRCUIT_BREAKER_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatalf("invalid CIRCUIT_BREAKER_THRESHOLD: %q", v)
+		}
+		breakerThreshold = n
+	}
+	var breakerCooldown time.Duration
+	if v := os.Getenv("CIRCUIT_BREAKER_COOLDOWN"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("invalid CIRCUIT_BREAKER_COOLDOWN: %v", err)
+		}
+		breakerCooldown = d
+	}
+
+	// Days finished jobs are kept before the worker purges them, for tenants
+	// without their own window; 0 keeps them.
+	retentionDays := 0
+	if v := os.Getenv("JOB_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("invalid JOB_RETENTION_DAYS: %q", v)
+		}
+		retentionDays = n
+	}
+
+	// Goroutines serving every queue, plus dedicated per-queue pools given as
+	// "name:concurrency,...", e.g. WORKER_QUEUES=transactional:10.
+	concurrency := 5
+	if v := os.Getenv("WORKER_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("invalid WORKER_CONCURRENCY: %q", v)
+		}
+		concurrency = n
+	}
+	workerOpts := []worker.Option{
+		worker.WithShutdownTimeout(shutdownTimeout),
+		worker.WithWorkerID(os.Getenv("WORKER_ID")),
+		worker.WithTenantConcurrency(tenantConcurrency),
+		worker.WithCircuitBreaker(breakerThreshold, breakerCooldown),
+		worker.WithRetention(retentionDays),
+	}
+	if v := os.Getenv("WORKER_QUEUES"); v != "" {
+		for _, entry := range strings.Split(v, ",") {
+			name, size, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			n, err := strconv.Atoi(size)
+			if !ok || name == "" || err != nil || n < 1 {
+				log.Fatalf("invalid WORKER_QUEUES entry %q: want name:concurrency", entry)
+			}
+			workerOpts = append(workerOpts, worker.WithQueue(name, n))
+		}
+	}
+
 	pool, err := pgxpool.New(context.Background(), dbURL)
 	if err != nil {
 		log.Fatalf("failed to connect to database: %v", err)
@@ -37,36 +124,88 @@ func main() {
 	}
 
 	router := gin.Default()
-	h := api.RegisterRoutes(router, pool, enc)
+	// Operator key for the /admin endpoints; they are disabled when unset.
+	h := api.RegisterRoutes(router, pool, enc, os.Getenv("ADMIN_API_KEY"))
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	w := worker.New(store.New(pool), h, 5)
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	srv := &http.Server{Addr: ":" + port, Handler: router}
+
+	// ctx is cancelled on the first SIGTERM/SIGINT; a second signal kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	w := worker.New(store.New(pool), h, concurrency,
+		append(workerOpts,
+			worker.WithListener(worker.NewPGListener(pool, worker.JobsChannel)),
+			worker.WithWorkflowTx(workflow.InTx(pool)),
+		)...,
+	)
 
 	switch os.Getenv("MODE") {
+	case "encrypt-payloads":
+		// One-off migration: seal payloads stored before payload encryption,
+		// then exit. Safe to run alongside the API and workers.
+		n, err := h.EncryptPayloads(ctx)
+		if err != nil {
+			log.Fatalf("encrypt payloads: %v (%d sealed)", err, n)
+		}
+		log.Printf("encrypted %d payloads", n)
 	case "worker":
 		log.Println("starting in worker-only mode")
-		w.Start(ctx) // blocks until ctx cancelled
+		w.Start(ctx) // blocks until ctx cancelled and in-flight jobs drained
 	case "api":
 		// API-only: no embedded worker goroutines; scale workers separately.
 		log.Println("starting in api-only mode")
-		port := os.Getenv("PORT")
-		if port == "" {
-			port = "8080"
-		}
-		if err := router.Run(":" + port); err != nil {
+		go h.ListenJobEvents(ctx, worker.NewPGListener(pool, worker.JobEventsChannel))
+		if err := serve(ctx, srv, shutdownTimeout); err != nil {
 			log.Fatalf("server error: %v", err)
 		}
 	default:
 		// Default: run both API server and worker in the same process.
-		go w.Start(ctx)
+		go h.ListenJobEvents(ctx, worker.NewPGListener(pool, worker.JobEventsChannel))
+		workerDone := make(chan struct{})
+		go func() {
+			w.Start(ctx)
+			close(workerDone)
+		}()
 
-		port := os.Getenv("PORT")
-		if port == "" {
-			port = "8080"
-		}
-		if err := router.Run(":" + port); err != nil {
+		if err := serve(ctx, srv, shutdownTimeout); err != nil {
 			log.Fatalf("server error: %v", err)
 		}
+		<-workerDone
+	}
+	log.Println("shutdown complete")
+}
+
+// serve runs srv until ctx is cancelled, then stops accepting connections and
+// waits up to timeout for in-flight requests to finish.
+func serve(ctx context.Context, srv *http.Server, timeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("shutting down http server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
+	return nil
 }
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS jobs_notify_pending ON jobs;
+DROP FUNCTION IF EXISTS notify_job_pending();
@@ -0,0 +1,16 @@
+-- Wake idle workers as soon as a job becomes claimable instead of waiting for
+-- their next poll. The payload is the job ID; workers treat it only as a signal
+-- and still claim through ClaimNextJob.
+CREATE OR REPLACE FUNCTION notify_job_pending() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('tusker_jobs', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Jobs scheduled for the future are picked up by the workers' fallback poll.
+CREATE TRIGGER jobs_notify_pending
+AFTER INSERT OR UPDATE OF status ON jobs
+FOR EACH ROW
+WHEN (NEW.status = 'pending' AND NEW.run_at <= NOW())
+EXECUTE FUNCTION notify_job_pending();
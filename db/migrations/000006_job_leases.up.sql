@@ -0,0 +1,5 @@
+-- A running job holds a lease that its worker extends while executing.
+-- Jobs whose lease has expired belong to a crashed worker and are reclaimed
+-- by the reaper (see ReapExpiredJobs).
+ALTER TABLE jobs ADD COLUMN lease_expires_at TIMESTAMPTZ;
+CREATE INDEX idx_jobs_running_lease ON jobs (lease_expires_at) WHERE status = 'running';
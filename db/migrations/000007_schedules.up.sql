@@ -0,0 +1,16 @@
+CREATE TABLE schedules (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id   UUID NOT NULL REFERENCES tenants(id),
+    name        TEXT NOT NULL,
+    cron_expr   TEXT NOT NULL,
+    timezone    TEXT NOT NULL DEFAULT 'UTC',
+    job_type    TEXT NOT NULL,
+    payload     JSONB NOT NULL,
+    paused      BOOLEAN NOT NULL DEFAULT FALSE,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    last_run_at TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (tenant_id, name)
+);
+CREATE INDEX idx_schedules_due ON schedules (next_run_at) WHERE NOT paused;
@@ -0,0 +1,2 @@
+UPDATE jobs SET status = 'failed', error = 'cancelled' WHERE status = 'cancelled';
+ALTER TABLE jobs DROP COLUMN IF EXISTS cancel_requested;
@@ -0,0 +1,3 @@
+-- Set on a running job when its cancellation is requested; the worker picks it
+-- up on its next heartbeat and cancels the job's context.
+ALTER TABLE jobs ADD COLUMN cancel_requested BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_jobs_tenant_created;
+DROP INDEX IF EXISTS idx_jobs_tenant_status_created;
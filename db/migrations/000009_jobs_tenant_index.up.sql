@@ -0,0 +1,3 @@
+-- Back GET /jobs: filter by status, or list everything, newest first.
+CREATE INDEX IF NOT EXISTS idx_jobs_tenant_status_created ON jobs (tenant_id, status, created_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_tenant_created ON jobs (tenant_id, created_at);
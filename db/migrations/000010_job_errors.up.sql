@@ -0,0 +1,10 @@
+-- One row per failed attempt, so a job's full error history survives retries
+-- (jobs.error only holds the latest).
+CREATE TABLE job_errors (
+    id         BIGSERIAL PRIMARY KEY,
+    job_id     UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    attempt    INT NOT NULL,
+    error      TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_job_errors_job ON job_errors (job_id, id);
@@ -0,0 +1,16 @@
+CREATE TABLE job_errors (
+    id         BIGSERIAL PRIMARY KEY,
+    job_id     UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    attempt    INT NOT NULL,
+    error      TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_job_errors_job ON job_errors (job_id, id);
+
+INSERT INTO job_errors (job_id, attempt, error, created_at)
+SELECT job_id, attempt, error, COALESCE(finished_at, started_at)
+FROM job_attempts
+WHERE error IS NOT NULL
+ORDER BY id;
+
+DROP TABLE IF EXISTS job_attempts;
@@ -0,0 +1,22 @@
+-- One row per attempt: opened when a worker claims the job, closed when the
+-- attempt completes, fails, is cancelled, is interrupted by shutdown, or its
+-- lease expires. Supersedes job_errors.
+CREATE TABLE job_attempts (
+    id          BIGSERIAL PRIMARY KEY,
+    job_id      UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    attempt     INT NOT NULL,
+    worker_id   TEXT NOT NULL,
+    outcome     TEXT NOT NULL DEFAULT 'running',
+    error       TEXT,
+    started_at  TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ,
+    duration_ms BIGINT
+);
+CREATE INDEX idx_job_attempts_job ON job_attempts (job_id, id);
+
+INSERT INTO job_attempts (job_id, attempt, worker_id, outcome, error, started_at, finished_at)
+SELECT job_id, attempt, '', 'failed', error, created_at, created_at
+FROM job_errors
+ORDER BY id;
+
+DROP TABLE job_errors;
@@ -0,0 +1,4 @@
+ALTER TABLE jobs
+    DROP COLUMN IF EXISTS backoff_base_seconds,
+    DROP COLUMN IF EXISTS backoff_max_seconds,
+    DROP COLUMN IF EXISTS backoff_jitter;
@@ -0,0 +1,7 @@
+-- Per-job retry policy. A failed attempt is retried after
+-- backoff_base_seconds * 2^attempt, capped at backoff_max_seconds and reduced
+-- by a random fraction of up to backoff_jitter.
+ALTER TABLE jobs
+    ADD COLUMN backoff_base_seconds INT NOT NULL DEFAULT 10,
+    ADD COLUMN backoff_max_seconds  INT NOT NULL DEFAULT 3600,
+    ADD COLUMN backoff_jitter       DOUBLE PRECISION NOT NULL DEFAULT 0;
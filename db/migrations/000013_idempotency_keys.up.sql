@@ -0,0 +1,14 @@
+-- Responses to requests sent with an Idempotency-Key header, kept per tenant
+-- until expires_at so retried requests are answered without enqueueing again.
+-- A row with a NULL status_code is a request still in progress.
+CREATE TABLE idempotency_keys (
+    tenant_id    UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    key          TEXT NOT NULL,
+    request_hash BYTEA NOT NULL,
+    status_code  INT,
+    response     JSONB,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (tenant_id, key)
+);
+CREATE INDEX idx_idempotency_keys_expires ON idempotency_keys (expires_at);
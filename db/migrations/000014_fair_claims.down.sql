@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_jobs_tenant_pending;
+ALTER TABLE tenants DROP COLUMN IF EXISTS max_concurrent_jobs;
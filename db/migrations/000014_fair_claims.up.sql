@@ -0,0 +1,6 @@
+-- Optional cap on how many of a tenant's jobs may run at once across all
+-- workers. NULL falls back to the worker's default (if any).
+ALTER TABLE tenants ADD COLUMN max_concurrent_jobs INT;
+
+-- Back the per-tenant round-robin in ClaimNextJob: each tenant's oldest due job.
+CREATE INDEX IF NOT EXISTS idx_jobs_tenant_pending ON jobs (tenant_id, run_at) WHERE status = 'pending';
@@ -0,0 +1,13 @@
+CREATE OR REPLACE FUNCTION notify_job_pending() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('tusker_jobs', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_jobs_tenant_pending;
+CREATE INDEX idx_jobs_tenant_pending ON jobs (tenant_id, run_at) WHERE status = 'pending';
+
+ALTER TABLE jobs
+    DROP COLUMN IF EXISTS queue,
+    DROP COLUMN IF EXISTS priority;
@@ -0,0 +1,17 @@
+-- Named queues let dedicated workers serve, e.g., transactional mail apart
+-- from bulk sends. Within a queue, higher-priority jobs are claimed first.
+ALTER TABLE jobs
+    ADD COLUMN queue    TEXT NOT NULL DEFAULT 'default',
+    ADD COLUMN priority INT NOT NULL DEFAULT 0;
+
+DROP INDEX IF EXISTS idx_jobs_tenant_pending;
+CREATE INDEX idx_jobs_tenant_pending ON jobs (tenant_id, queue, priority DESC, run_at) WHERE status = 'pending';
+
+-- Prefix the notification payload with the job's queue so workers wake a
+-- goroutine that serves it: "<queue>:<job id>".
+CREATE OR REPLACE FUNCTION notify_job_pending() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('tusker_jobs', NEW.queue || ':' || NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
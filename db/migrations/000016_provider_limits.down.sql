@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS provider_circuits;
+DROP TABLE IF EXISTS provider_rate_limits;
+ALTER TABLE jobs DROP COLUMN IF EXISTS provider;
@@ -0,0 +1,30 @@
+-- The provider a job calls (e.g. "twilio"), so claims can skip providers that
+-- are throttled or tripped. Empty for jobs that call no rate-limited provider.
+ALTER TABLE jobs ADD COLUMN provider TEXT NOT NULL DEFAULT '';
+UPDATE jobs SET provider = payload->>'provider'
+WHERE status IN ('pending', 'running') AND payload ? 'provider';
+
+-- Token bucket per tenant and provider, shared by every worker: tokens refill
+-- at rate_per_second up to burst, and each claimed job takes one.
+CREATE TABLE provider_rate_limits (
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    provider        TEXT NOT NULL,
+    rate_per_second DOUBLE PRECISION NOT NULL,
+    burst           INT NOT NULL,
+    tokens          DOUBLE PRECISION NOT NULL,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, provider)
+);
+
+-- Circuit breaker per tenant and provider. While open_until is in the future
+-- no jobs for the provider are claimed.
+CREATE TABLE provider_circuits (
+    tenant_id            UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    provider             TEXT NOT NULL,
+    consecutive_failures INT NOT NULL DEFAULT 0,
+    open_until           TIMESTAMPTZ,
+    last_error           TEXT,
+    last_failure_at      TIMESTAMPTZ,
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, provider)
+);
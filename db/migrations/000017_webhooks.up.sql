@@ -0,0 +1,31 @@
+-- Tenant endpoints notified of job events. The signing secret is encrypted
+-- with the tenant's data key.
+CREATE TABLE webhook_endpoints (
+    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id        UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    url              TEXT NOT NULL,
+    events           TEXT[] NOT NULL,
+    encrypted_secret BYTEA NOT NULL,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_webhook_endpoints_tenant ON webhook_endpoints (tenant_id);
+
+-- One row per event per endpoint, doubling as the delivery log. Each delivery
+-- is sent by a webhook.deliver job, which carries its retries.
+CREATE TABLE webhook_deliveries (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    endpoint_id     UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    job_id          UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    event           TEXT NOT NULL,
+    data            JSONB NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'pending', -- pending | succeeded | failed
+    attempts        INT NOT NULL DEFAULT 0,
+    max_attempts    INT NOT NULL,
+    response_status INT,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at    TIMESTAMPTZ
+);
+CREATE INDEX idx_webhook_deliveries_endpoint ON webhook_deliveries (endpoint_id, created_at DESC);
+CREATE INDEX idx_webhook_deliveries_job ON webhook_deliveries (job_id);
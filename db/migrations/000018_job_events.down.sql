@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS jobs_notify_event_update ON jobs;
+DROP TRIGGER IF EXISTS jobs_notify_event_insert ON jobs;
+DROP FUNCTION IF EXISTS notify_job_event();
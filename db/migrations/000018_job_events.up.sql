@@ -0,0 +1,28 @@
+-- Publish every job status transition so API servers can stream them to
+-- clients over SSE, whichever container made the change. Errors are truncated
+-- to keep the payload well under NOTIFY's 8000-byte limit.
+CREATE OR REPLACE FUNCTION notify_job_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('tusker_job_events', json_build_object(
+        'job_id', NEW.id,
+        'tenant_id', NEW.tenant_id,
+        'job_type', NEW.job_type,
+        'status', NEW.status,
+        'attempt', NEW.attempt,
+        'error', left(NEW.error, 1000),
+        'at', NOW()
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER jobs_notify_event_insert
+AFTER INSERT ON jobs
+FOR EACH ROW
+EXECUTE FUNCTION notify_job_event();
+
+CREATE TRIGGER jobs_notify_event_update
+AFTER UPDATE OF status ON jobs
+FOR EACH ROW
+WHEN (OLD.status IS DISTINCT FROM NEW.status)
+EXECUTE FUNCTION notify_job_event();
@@ -0,0 +1,5 @@
+ALTER TABLE jobs
+    DROP COLUMN IF EXISTS output,
+    DROP COLUMN IF EXISTS workflow_id;
+DROP TABLE IF EXISTS workflow_steps;
+DROP TABLE IF EXISTS workflows;
@@ -0,0 +1,43 @@
+-- A small DAG of jobs submitted together. Steps are queued as their upstream
+-- steps finish, following on_success / on_failure edges.
+CREATE TABLE workflows (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id    UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name         TEXT NOT NULL DEFAULT '',
+    status       TEXT NOT NULL DEFAULT 'running', -- running | completed | failed | cancelled
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+CREATE INDEX idx_workflows_tenant ON workflows (tenant_id, created_at DESC);
+
+-- payload is the step's job payload with {{steps.<name>...}} placeholders
+-- still in it; they are filled in when the step's job is queued. output and
+-- error are copied from the job once it finishes.
+CREATE TABLE workflow_steps (
+    workflow_id          UUID NOT NULL REFERENCES workflows(id) ON DELETE CASCADE,
+    name                 TEXT NOT NULL,
+    position             INT NOT NULL,
+    job_type             TEXT NOT NULL,
+    provider             TEXT NOT NULL DEFAULT '',
+    payload              JSONB NOT NULL,
+    queue                TEXT NOT NULL DEFAULT 'default',
+    priority             INT NOT NULL DEFAULT 0,
+    max_attempts         INT NOT NULL,
+    backoff_base_seconds INT NOT NULL,
+    backoff_max_seconds  INT NOT NULL,
+    backoff_jitter       DOUBLE PRECISION NOT NULL DEFAULT 0,
+    on_success           TEXT[] NOT NULL DEFAULT '{}',
+    on_failure           TEXT[] NOT NULL DEFAULT '{}',
+    status               TEXT NOT NULL DEFAULT 'waiting', -- waiting | queued | completed | failed | cancelled | skipped
+    job_id               UUID REFERENCES jobs(id) ON DELETE SET NULL,
+    output               JSONB,
+    error                TEXT,
+    PRIMARY KEY (workflow_id, name)
+);
+CREATE UNIQUE INDEX idx_workflow_steps_job ON workflow_steps (job_id);
+
+-- output is the result an executor reports for a job, e.g. a code execution's
+-- stdout, available to later workflow steps.
+ALTER TABLE jobs
+    ADD COLUMN workflow_id UUID REFERENCES workflows(id) ON DELETE SET NULL,
+    ADD COLUMN output      JSONB;
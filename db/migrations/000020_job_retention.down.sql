@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_workflows_finished;
+DROP INDEX IF EXISTS idx_jobs_finished;
+
+ALTER TABLE code_executions
+    DROP CONSTRAINT code_executions_job_id_fkey,
+    ADD CONSTRAINT code_executions_job_id_fkey
+        FOREIGN KEY (job_id) REFERENCES jobs(id);
+
+ALTER TABLE tenants DROP COLUMN IF EXISTS job_retention_days;
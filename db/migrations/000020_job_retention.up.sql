@@ -0,0 +1,21 @@
+-- Days a tenant's finished jobs are kept before the worker purges them; NULL
+-- falls back to the worker's JOB_RETENTION_DAYS.
+ALTER TABLE tenants ADD COLUMN job_retention_days INT;
+
+-- Code execution results are purged with their job.
+ALTER TABLE code_executions
+    DROP CONSTRAINT code_executions_job_id_fkey,
+    ADD CONSTRAINT code_executions_job_id_fkey
+        FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE;
+
+-- Jobs the worker failed were left without a completion time, which the purge
+-- needs.
+UPDATE jobs SET completed_at = run_at
+WHERE status IN ('completed', 'failed', 'cancelled') AND completed_at IS NULL;
+
+-- The purge reads only finished jobs, never the pending ones behind
+-- idx_jobs_pending and idx_jobs_tenant_pending.
+CREATE INDEX idx_jobs_finished ON jobs (tenant_id, completed_at)
+    WHERE status IN ('completed', 'failed', 'cancelled');
+CREATE INDEX idx_workflows_finished ON workflows (tenant_id, completed_at)
+    WHERE status <> 'running';
@@ -0,0 +1,12 @@
+-- Workers register on startup and heartbeat while they run, so operators can
+-- see which are alive. The jobs a worker is running are its open attempts in
+-- job_attempts.
+CREATE TABLE workers (
+    id                TEXT PRIMARY KEY,
+    hostname          TEXT NOT NULL,
+    queues            TEXT[] NOT NULL, -- '*' for a pool serving every queue
+    concurrency       INT NOT NULL,
+    started_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    stopped_at        TIMESTAMPTZ
+);
@@ -0,0 +1,12 @@
+-- A set of jobs submitted together in one request, e.g. a campaign's emails.
+-- Progress is counted from the jobs themselves.
+CREATE TABLE batches (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id  UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    total      INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE jobs ADD COLUMN batch_id UUID REFERENCES batches(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_jobs_batch ON jobs (batch_id) WHERE batch_id IS NOT NULL;
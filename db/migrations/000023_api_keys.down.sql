@@ -0,0 +1,17 @@
+-- Tenants keep their oldest active key; a tenant without one is left with a
+-- placeholder hash no key matches.
+ALTER TABLE tenants ADD COLUMN api_key_hash TEXT;
+
+UPDATE tenants t SET api_key_hash = COALESCE((
+    SELECT k.key_hash FROM api_keys k
+    WHERE k.tenant_id = t.id AND k.revoked_at IS NULL
+      AND (k.expires_at IS NULL OR k.expires_at > NOW())
+    ORDER BY k.created_at
+    LIMIT 1
+), 'revoked:' || gen_random_uuid());
+
+ALTER TABLE tenants
+    ALTER COLUMN api_key_hash SET NOT NULL,
+    ADD CONSTRAINT tenants_api_key_hash_key UNIQUE (api_key_hash);
+
+DROP TABLE IF EXISTS api_keys;
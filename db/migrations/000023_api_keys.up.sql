@@ -0,0 +1,21 @@
+-- A tenant may hold several API keys, so a leaked key can be replaced and
+-- revoked without losing the tenant. Only a hash of each key is stored.
+-- Revoked keys are kept for the audit trail.
+CREATE TABLE api_keys (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id    UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name         TEXT NOT NULL,
+    key_hash     TEXT NOT NULL UNIQUE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    expires_at   TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
+
+CREATE INDEX idx_api_keys_tenant ON api_keys (tenant_id, created_at);
+
+-- Each tenant's existing key becomes its "default" key.
+INSERT INTO api_keys (tenant_id, name, key_hash, created_at)
+SELECT id, 'default', api_key_hash, created_at FROM tenants;
+
+ALTER TABLE tenants DROP COLUMN api_key_hash;
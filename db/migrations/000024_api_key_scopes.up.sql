@@ -0,0 +1,3 @@
+-- The routes an API key may call. '*' grants every scope; existing keys keep
+-- full access, as does the key a new tenant is created with.
+ALTER TABLE api_keys ADD COLUMN scopes TEXT[] NOT NULL DEFAULT '{*}';
@@ -0,0 +1,16 @@
+ALTER TABLE schedules
+    DROP CONSTRAINT schedules_tenant_id_fkey,
+    ADD CONSTRAINT schedules_tenant_id_fkey
+        FOREIGN KEY (tenant_id) REFERENCES tenants(id);
+ALTER TABLE code_executions
+    DROP CONSTRAINT code_executions_tenant_id_fkey,
+    ADD CONSTRAINT code_executions_tenant_id_fkey
+        FOREIGN KEY (tenant_id) REFERENCES tenants(id);
+ALTER TABLE jobs
+    DROP CONSTRAINT jobs_tenant_id_fkey,
+    ADD CONSTRAINT jobs_tenant_id_fkey
+        FOREIGN KEY (tenant_id) REFERENCES tenants(id);
+
+ALTER TABLE tenants
+    DROP COLUMN IF EXISTS suspended_at,
+    DROP COLUMN IF EXISTS name;
@@ -0,0 +1,20 @@
+-- Tenants are provisioned and managed through the admin API. A suspended
+-- tenant's API keys are rejected and its jobs and schedules are held until it
+-- is reactivated.
+ALTER TABLE tenants
+    ADD COLUMN name         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN suspended_at TIMESTAMPTZ;
+
+-- Deleting a tenant deletes everything it owns.
+ALTER TABLE jobs
+    DROP CONSTRAINT jobs_tenant_id_fkey,
+    ADD CONSTRAINT jobs_tenant_id_fkey
+        FOREIGN KEY (tenant_id) REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE code_executions
+    DROP CONSTRAINT code_executions_tenant_id_fkey,
+    ADD CONSTRAINT code_executions_tenant_id_fkey
+        FOREIGN KEY (tenant_id) REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE schedules
+    DROP CONSTRAINT schedules_tenant_id_fkey,
+    ADD CONSTRAINT schedules_tenant_id_fkey
+        FOREIGN KEY (tenant_id) REFERENCES tenants(id) ON DELETE CASCADE;
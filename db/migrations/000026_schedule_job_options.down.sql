@@ -0,0 +1,7 @@
+ALTER TABLE schedules
+    DROP COLUMN IF EXISTS queue,
+    DROP COLUMN IF EXISTS priority,
+    DROP COLUMN IF EXISTS max_attempts,
+    DROP COLUMN IF EXISTS backoff_base_seconds,
+    DROP COLUMN IF EXISTS backoff_max_seconds,
+    DROP COLUMN IF EXISTS backoff_jitter;
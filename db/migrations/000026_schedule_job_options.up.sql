@@ -0,0 +1,18 @@
+-- Schedules carry the queue, priority and retry policy of the jobs they fire,
+-- resolved from the job type's defaults and the request's overrides when the
+-- schedule is written.
+ALTER TABLE schedules
+    ADD COLUMN queue                TEXT NOT NULL DEFAULT 'default',
+    ADD COLUMN priority             INT NOT NULL DEFAULT 0,
+    ADD COLUMN max_attempts         INT NOT NULL DEFAULT 3,
+    ADD COLUMN backoff_base_seconds INT NOT NULL DEFAULT 10,
+    ADD COLUMN backoff_max_seconds  INT NOT NULL DEFAULT 3600,
+    ADD COLUMN backoff_jitter       DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- Existing schedules take their job type's default policy.
+UPDATE schedules SET
+    max_attempts = 3, backoff_base_seconds = 2, backoff_max_seconds = 30, backoff_jitter = 0.2
+WHERE job_type = 'sms.send';
+UPDATE schedules SET
+    max_attempts = 8, backoff_base_seconds = 30, backoff_max_seconds = 3600, backoff_jitter = 0.2
+WHERE job_type IN ('email.send', 'email.send_template');
@@ -0,0 +1,44 @@
+-- name: AuthenticateAPIKey :one
+-- Returns the active (unrevoked, unexpired) key with the given hash and
+-- records its use. last_used_at is only written once a minute per key, so
+-- busy keys do not turn every request into a write.
+WITH found AS (
+    SELECT * FROM api_keys
+    WHERE key_hash = $1
+      AND revoked_at IS NULL
+      AND (expires_at IS NULL OR expires_at > NOW())
+), touched AS (
+    UPDATE api_keys k SET last_used_at = NOW()
+    FROM found
+    WHERE k.id = found.id
+      AND (found.last_used_at IS NULL OR found.last_used_at < NOW() - INTERVAL '1 minute')
+)
+SELECT * FROM found;
+
+-- name: CreateAPIKey :one
+-- A NULL expires_at never expires.
+INSERT INTO api_keys (tenant_id, name, key_hash, expires_at, scopes)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: ListAPIKeys :many
+SELECT * FROM api_keys
+WHERE tenant_id = $1
+ORDER BY created_at, id;
+
+-- name: GetAPIKey :one
+SELECT * FROM api_keys
+WHERE id = $1 AND tenant_id = $2;
+
+-- name: RevokeAPIKey :execrows
+-- Revokes an active key unless it is the tenant's last active one, which
+-- would lock the tenant out.
+UPDATE api_keys SET revoked_at = NOW()
+WHERE id = $1 AND tenant_id = $2
+  AND revoked_at IS NULL
+  AND EXISTS (
+      SELECT 1 FROM api_keys o
+      WHERE o.tenant_id = $2 AND o.id <> $1
+        AND o.revoked_at IS NULL
+        AND (o.expires_at IS NULL OR o.expires_at > NOW())
+  );
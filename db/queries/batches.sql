@@ -0,0 +1,38 @@
+-- name: CreateBatch :one
+-- Inserts the batch and queues its jobs, given as a JSON array of objects with
+-- the fields named below: a bulk CreateJob. A NULL run_at queues the job to
+-- run immediately. Either every job is queued or none is.
+WITH batch AS (
+    INSERT INTO batches (tenant_id, total)
+    VALUES (sqlc.arg(tenant_id), jsonb_array_length(sqlc.arg(jobs)::jsonb))
+    RETURNING *
+), queued AS (
+    INSERT INTO jobs (
+        id, tenant_id, job_type, payload, provider, queue, priority,
+        max_attempts, backoff_base_seconds, backoff_max_seconds, backoff_jitter,
+        run_at, batch_id
+    )
+    SELECT j.id, b.tenant_id, j.job_type, j.payload, j.provider, j.queue, j.priority,
+        j.max_attempts, j.backoff_base_seconds, j.backoff_max_seconds, j.backoff_jitter,
+        COALESCE(j.run_at, NOW()), b.id
+    FROM batch b, jsonb_to_recordset(sqlc.arg(jobs)::jsonb) AS j(
+        id UUID, job_type TEXT, payload JSONB, provider TEXT, queue TEXT, priority INT,
+        max_attempts INT, backoff_base_seconds INT, backoff_max_seconds INT, backoff_jitter DOUBLE PRECISION,
+        run_at TIMESTAMPTZ
+    )
+)
+SELECT * FROM batch;
+
+-- name: GetBatch :one
+-- Returns the batch with how many of its jobs are in each status. Jobs purged
+-- past the tenant's retention window are no longer counted.
+SELECT b.id, b.tenant_id, b.total, b.created_at,
+    COUNT(j.id) FILTER (WHERE j.status = 'pending')   AS pending,
+    COUNT(j.id) FILTER (WHERE j.status = 'running')   AS running,
+    COUNT(j.id) FILTER (WHERE j.status = 'completed') AS completed,
+    COUNT(j.id) FILTER (WHERE j.status = 'failed')    AS failed,
+    COUNT(j.id) FILTER (WHERE j.status = 'cancelled') AS cancelled
+FROM batches b
+LEFT JOIN jobs j ON j.batch_id = b.id
+WHERE b.id = sqlc.arg(id) AND b.tenant_id = sqlc.arg(tenant_id)
+GROUP BY b.id;
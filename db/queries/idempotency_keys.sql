@@ -0,0 +1,44 @@
+-- name: ReserveIdempotencyKey :one
+-- Claims key for a new request. Returns no rows if the key is already held by
+-- a live entry; an expired entry, or one whose request has been in progress
+-- for longer than lock_seconds (e.g. the server crashed), is taken over.
+INSERT INTO idempotency_keys (tenant_id, key, request_hash, expires_at)
+VALUES (
+    sqlc.arg(tenant_id), sqlc.arg(key), sqlc.arg(request_hash),
+    NOW() + sqlc.arg(ttl_seconds)::int * INTERVAL '1 second'
+)
+ON CONFLICT (tenant_id, key) DO UPDATE SET
+    request_hash = EXCLUDED.request_hash,
+    status_code = NULL,
+    response = NULL,
+    created_at = NOW(),
+    expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at <= NOW()
+   OR (idempotency_keys.status_code IS NULL
+       AND idempotency_keys.created_at < NOW() - sqlc.arg(lock_seconds)::int * INTERVAL '1 second')
+RETURNING *;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE tenant_id = $1 AND key = $2;
+
+-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys SET
+    status_code = $3,
+    response = $4
+WHERE tenant_id = $1 AND key = $2;
+
+-- name: ReleaseIdempotencyKey :exec
+-- Frees a key whose request failed so the client can retry it.
+DELETE FROM idempotency_keys
+WHERE tenant_id = $1 AND key = $2 AND status_code IS NULL;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+-- Deletes up to batch_size expired keys, skipping any a request has locked.
+DELETE FROM idempotency_keys
+WHERE (tenant_id, key) IN (
+    SELECT tenant_id, key FROM idempotency_keys
+    WHERE expires_at < NOW()
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+);
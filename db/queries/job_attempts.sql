@@ -0,0 +1,4 @@
+-- name: ListJobAttempts :many
+SELECT * FROM job_attempts
+WHERE job_id = ANY(sqlc.arg(job_ids)::uuid[])
+ORDER BY job_id, id;
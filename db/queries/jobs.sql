@@ -1,31 +1,276 @@
 -- name: CreateJob :one
-INSERT INTO jobs (tenant_id, job_type, payload)
-VALUES ($1, $2, $3)
+-- A NULL run_at queues the job to run immediately.
+INSERT INTO jobs (
+    tenant_id, job_type, payload, provider, queue, priority,
+    max_attempts, backoff_base_seconds, backoff_max_seconds, backoff_jitter,
+    run_at
+)
+VALUES (
+    sqlc.arg(tenant_id), sqlc.arg(job_type), sqlc.arg(payload), sqlc.arg(provider), sqlc.arg(queue), sqlc.arg(priority),
+    sqlc.arg(max_attempts), sqlc.arg(backoff_base_seconds), sqlc.arg(backoff_max_seconds), sqlc.arg(backoff_jitter),
+    COALESCE(sqlc.narg(run_at)::timestamptz, NOW())
+)
 RETURNING *;
 
 -- name: ClaimNextJob :one
-UPDATE jobs SET
-    status = 'running',
-    started_at = NOW(),
-    attempt = attempt + 1
-WHERE id = (
-    SELECT id FROM jobs
-    WHERE status = 'pending' AND run_at <= NOW()
-    ORDER BY run_at ASC
+-- Claims a due job in one of queues (NULL for any queue) from the first tenant
+-- after after_tenant_id (in id order, wrapping around) that has one, so tenants
+-- are served round-robin rather than strictly by run_at: a tenant with a large
+-- backlog cannot starve the others. Within the tenant, the highest-priority
+-- job is claimed first, oldest first among equals. Tenants already running their max_concurrent_jobs, or
+-- default_tenant_limit if that is NULL, are skipped; a NULL limit is uncapped.
+-- Suspended tenants are skipped too.
+-- Jobs for a provider whose circuit is open, or whose rate limit bucket holds
+-- less than one token, are skipped too; claiming takes a token from the bucket.
+-- Also opens the job_attempts row for the new attempt.
+WITH busy AS (
+    SELECT r.tenant_id
+    FROM jobs r
+    JOIN tenants t ON t.id = r.tenant_id
+    WHERE r.status = 'running'
+    GROUP BY r.tenant_id, t.max_concurrent_jobs
+    HAVING COUNT(*) >= COALESCE(t.max_concurrent_jobs, sqlc.narg(default_tenant_limit)::int)
+), blocked AS (
+    SELECT tenant_id, provider FROM provider_circuits WHERE open_until > NOW()
+    UNION
+    SELECT tenant_id, provider FROM provider_rate_limits
+    WHERE LEAST(burst, tokens + rate_per_second * EXTRACT(EPOCH FROM NOW() - updated_at)) < 1
+), next AS (
+    -- The lateral probe runs tenant by tenant in the order below and stops at
+    -- the first job it can lock, so only that job is locked.
+    SELECT j.id
+    FROM (
+        SELECT id FROM tenants
+        WHERE id NOT IN (SELECT tenant_id FROM busy) AND suspended_at IS NULL
+        ORDER BY id <= sqlc.arg(after_tenant_id)::uuid, id
+    ) t
+    CROSS JOIN LATERAL (
+        SELECT id FROM jobs
+        WHERE tenant_id = t.id AND status = 'pending' AND run_at <= NOW()
+          AND (sqlc.narg(queues)::text[] IS NULL OR queue = ANY(sqlc.narg(queues)::text[]))
+          AND NOT EXISTS (SELECT 1 FROM blocked b WHERE b.tenant_id = t.id AND b.provider = jobs.provider)
+        ORDER BY priority DESC, run_at ASC
+        LIMIT 1
+        FOR UPDATE SKIP LOCKED
+    ) j
     LIMIT 1
-    FOR UPDATE SKIP LOCKED
+), claimed AS (
+    UPDATE jobs SET
+        status = 'running',
+        started_at = NOW(),
+        attempt = attempt + 1,
+        lease_expires_at = NOW() + sqlc.arg(lease_seconds)::int * INTERVAL '1 second'
+    WHERE id = (SELECT id FROM next)
+    RETURNING *
+), opened AS (
+    INSERT INTO job_attempts (job_id, attempt, worker_id, started_at)
+    SELECT id, attempt, sqlc.arg(worker_id), started_at FROM claimed
+), taken AS (
+    -- Concurrent claims may each see the last token, leaving the bucket
+    -- slightly negative; it then simply takes longer to refill.
+    UPDATE provider_rate_limits l SET
+        tokens = LEAST(l.burst, l.tokens + l.rate_per_second * EXTRACT(EPOCH FROM NOW() - l.updated_at)) - 1,
+        updated_at = NOW()
+    FROM claimed c
+    WHERE l.tenant_id = c.tenant_id AND l.provider = c.provider
 )
-RETURNING *;
+SELECT * FROM claimed;
+
+-- name: ExtendJobLease :one
+-- Reports whether cancellation of the job has been requested. Returns no rows
+-- once the job is no longer ours: it finished, or the reaper reclaimed it and it
+-- may already be running elsewhere.
+UPDATE jobs SET
+    lease_expires_at = NOW() + sqlc.arg(lease_seconds)::int * INTERVAL '1 second'
+WHERE id = sqlc.arg(id) AND status = 'running' AND attempt = sqlc.arg(attempt)
+RETURNING cancel_requested;
 
 -- name: UpdateJobStatus :one
+-- The status/attempt guard fences out a worker whose lease was reaped.
+-- Also closes the attempt's job_attempts row; an attempt that left the job
+-- pending for a retry is recorded as failed.
+WITH updated AS (
+    UPDATE jobs SET
+        status = $2,
+        error = $3,
+        completed_at = $4,
+        run_at = $5,
+        lease_expires_at = NULL
+    WHERE id = $1 AND status = 'running' AND attempt = $6
+    RETURNING *
+), finished AS (
+    UPDATE job_attempts a SET
+        outcome = CASE WHEN u.status = 'pending' THEN 'failed' ELSE u.status END,
+        error = u.error,
+        finished_at = NOW(),
+        duration_ms = (EXTRACT(EPOCH FROM NOW() - a.started_at) * 1000)::bigint
+    FROM updated u
+    WHERE a.job_id = u.id AND a.attempt = u.attempt AND a.finished_at IS NULL
+)
+SELECT * FROM updated;
+
+-- name: RequeueJob :execrows
+-- Returns a job interrupted by worker shutdown to the queue without counting
+-- the interrupted attempt against max_attempts. A job whose cancellation was
+-- requested is cancelled instead.
+WITH finished AS (
+    UPDATE job_attempts SET
+        outcome = 'interrupted',
+        finished_at = NOW(),
+        duration_ms = (EXTRACT(EPOCH FROM NOW() - started_at) * 1000)::bigint
+    WHERE job_id = $1 AND attempt = $2 AND finished_at IS NULL
+)
 UPDATE jobs SET
-    status = $2,
-    error = $3,
-    completed_at = $4,
-    run_at = $5
-WHERE id = $1
-RETURNING *;
+    status = CASE WHEN cancel_requested THEN 'cancelled' ELSE 'pending' END,
+    attempt = attempt - 1,
+    run_at = NOW(),
+    started_at = NULL,
+    lease_expires_at = NULL
+WHERE id = $1 AND status = 'running' AND attempt = $2;
+
+-- name: ReapExpiredJobs :many
+-- Returns jobs abandoned by a crashed worker to the queue. The attempt was
+-- already counted when the job was claimed.
+WITH reaped AS (
+    UPDATE jobs SET
+        status = CASE
+            WHEN cancel_requested THEN 'cancelled'
+            WHEN attempt >= max_attempts THEN 'failed'
+            ELSE 'pending'
+        END,
+        error = 'lease expired: worker stopped responding',
+        run_at = NOW(),
+        completed_at = CASE WHEN cancel_requested OR attempt >= max_attempts THEN NOW() END,
+        lease_expires_at = NULL
+    WHERE id IN (
+        SELECT id FROM jobs
+        WHERE status = 'running' AND lease_expires_at < NOW()
+        LIMIT 100
+        FOR UPDATE SKIP LOCKED
+    )
+    RETURNING *
+), finished AS (
+    UPDATE job_attempts a SET
+        outcome = 'lease_expired',
+        error = r.error,
+        finished_at = NOW(),
+        duration_ms = (EXTRACT(EPOCH FROM NOW() - a.started_at) * 1000)::bigint
+    FROM reaped r
+    WHERE a.job_id = r.id AND a.attempt = r.attempt AND a.finished_at IS NULL
+)
+SELECT * FROM reaped;
 
 -- name: GetJob :one
 SELECT * FROM jobs
 WHERE id = $1 AND tenant_id = $2;
+
+-- name: CancelJob :one
+-- Cancels a job that has not started yet. Returns no rows if the job does not
+-- exist or has already left the pending state.
+UPDATE jobs SET
+    status = 'cancelled',
+    completed_at = NOW()
+WHERE id = $1 AND tenant_id = $2 AND status = 'pending'
+RETURNING *;
+
+-- name: RequestJobCancel :one
+-- Flags a running job for cooperative cancellation by its worker.
+UPDATE jobs SET
+    cancel_requested = TRUE
+WHERE id = $1 AND tenant_id = $2 AND status = 'running'
+RETURNING *;
+
+-- name: ListJobs :many
+-- Keyset pagination, newest first: pass the created_at and id of the last job
+-- on the previous page as the cursor. NULL filters match everything.
+SELECT * FROM jobs
+WHERE tenant_id = sqlc.arg(tenant_id)
+  AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status))
+  AND (sqlc.narg(job_type)::text IS NULL OR job_type = sqlc.narg(job_type))
+  AND (sqlc.narg(created_after)::timestamptz IS NULL OR created_at >= sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamptz IS NULL OR created_at < sqlc.narg(created_before))
+  AND (sqlc.narg(cursor_created_at)::timestamptz IS NULL
+       OR (created_at, id) < (sqlc.narg(cursor_created_at), sqlc.arg(cursor_id)::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: CountJobsByStatus :many
+SELECT status, COUNT(*) AS count FROM jobs
+WHERE tenant_id = sqlc.arg(tenant_id)
+  AND (sqlc.narg(job_type)::text IS NULL OR job_type = sqlc.narg(job_type))
+  AND (sqlc.narg(created_after)::timestamptz IS NULL OR created_at >= sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamptz IS NULL OR created_at < sqlc.narg(created_before))
+GROUP BY status;
+
+-- name: RetryJob :one
+-- Gives a dead-lettered job a fresh set of attempts. Its attempt history is kept.
+-- Workflow steps are not retried: a step's outcome is decided once, and its
+-- workflow has already moved on.
+UPDATE jobs SET
+    status = 'pending',
+    attempt = 0,
+    error = NULL,
+    output = NULL,
+    cancel_requested = FALSE,
+    run_at = NOW(),
+    started_at = NULL,
+    completed_at = NULL
+WHERE id = $1 AND tenant_id = $2 AND status = 'failed' AND workflow_id IS NULL
+RETURNING *;
+
+-- name: ReplayFailedJobs :execrows
+-- Bulk RetryJob for failed jobs created in [created_after, created_before),
+-- optionally limited to one job type. Replays at most max_jobs per call.
+UPDATE jobs SET
+    status = 'pending',
+    attempt = 0,
+    error = NULL,
+    output = NULL,
+    cancel_requested = FALSE,
+    run_at = NOW(),
+    started_at = NULL,
+    completed_at = NULL
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE tenant_id = sqlc.arg(tenant_id)
+      AND status = 'failed'
+      AND workflow_id IS NULL
+      AND (sqlc.narg(job_type)::text IS NULL OR job_type = sqlc.narg(job_type))
+      AND created_at >= sqlc.arg(created_after)
+      AND created_at < sqlc.arg(created_before)
+    ORDER BY created_at
+    LIMIT sqlc.arg(max_jobs)
+    FOR UPDATE SKIP LOCKED
+);
+
+-- name: SetJobOutput :exec
+-- Records the result an executor reports for a running job.
+UPDATE jobs SET output = $2
+WHERE id = $1 AND status = 'running';
+
+-- name: ListPlaintextJobPayloads :many
+-- Jobs whose payload predates payload encryption. webhook.deliver payloads
+-- only hold a delivery id and are left in the clear.
+SELECT id, tenant_id, payload FROM jobs
+WHERE NOT payload ? 'ciphertext' AND job_type <> 'webhook.deliver'
+LIMIT $1;
+
+-- name: SealJobPayload :execrows
+UPDATE jobs SET payload = $2
+WHERE id = $1 AND NOT payload ? 'ciphertext';
+
+-- name: PurgeFinishedJobs :execrows
+-- Deletes up to batch_size jobs that finished longer ago than their tenant's
+-- retention window, or default_days for tenants without one; with neither,
+-- jobs are kept. Attempts, code execution results and webhook deliveries go
+-- with them. Only finished jobs are read and locked, skipping any a retry has
+-- locked, so a purge never blocks claims of pending jobs.
+DELETE FROM jobs
+WHERE id IN (
+    SELECT j.id FROM jobs j
+    JOIN tenants t ON t.id = j.tenant_id
+    WHERE j.status IN ('completed', 'failed', 'cancelled')
+      AND j.completed_at < NOW() - make_interval(days => COALESCE(t.job_retention_days, sqlc.narg(default_days)::int))
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE OF j SKIP LOCKED
+);
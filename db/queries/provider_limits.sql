@@ -0,0 +1,75 @@
+-- name: UpsertProviderRateLimit :one
+-- Changing an existing limit keeps the tokens already accrued, capped at the
+-- new burst.
+INSERT INTO provider_rate_limits (tenant_id, provider, rate_per_second, burst, tokens)
+VALUES (sqlc.arg(tenant_id), sqlc.arg(provider), sqlc.arg(rate_per_second), sqlc.arg(burst), sqlc.arg(burst))
+ON CONFLICT (tenant_id, provider) DO UPDATE SET
+    tokens = LEAST(
+        EXCLUDED.burst,
+        provider_rate_limits.burst,
+        provider_rate_limits.tokens + provider_rate_limits.rate_per_second * EXTRACT(EPOCH FROM NOW() - provider_rate_limits.updated_at)
+    ),
+    rate_per_second = EXCLUDED.rate_per_second,
+    burst = EXCLUDED.burst,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: ListProviderRateLimits :many
+SELECT * FROM provider_rate_limits
+WHERE tenant_id = $1
+ORDER BY provider;
+
+-- name: DeleteProviderRateLimit :execrows
+DELETE FROM provider_rate_limits
+WHERE tenant_id = $1 AND provider = $2;
+
+-- name: RecordProviderFailure :one
+-- Counts a retryable failure against the provider's circuit and opens it for
+-- open_seconds once failure_threshold failures have happened in a row. A
+-- retry_after_seconds hint from the provider holds the circuit open at least
+-- that long regardless of the count.
+INSERT INTO provider_circuits AS c (
+    tenant_id, provider, consecutive_failures, open_until, last_error, last_failure_at
+)
+VALUES (
+    sqlc.arg(tenant_id), sqlc.arg(provider), 1,
+    GREATEST(
+        CASE WHEN sqlc.arg(failure_threshold)::int <= 1
+            THEN NOW() + sqlc.arg(open_seconds)::int * INTERVAL '1 second' END,
+        CASE WHEN sqlc.arg(retry_after_seconds)::int > 0
+            THEN NOW() + sqlc.arg(retry_after_seconds)::int * INTERVAL '1 second' END
+    ),
+    sqlc.arg(error), NOW()
+)
+ON CONFLICT (tenant_id, provider) DO UPDATE SET
+    consecutive_failures = c.consecutive_failures + 1,
+    open_until = GREATEST(
+        c.open_until,
+        CASE WHEN c.consecutive_failures + 1 >= sqlc.arg(failure_threshold)::int
+            THEN NOW() + sqlc.arg(open_seconds)::int * INTERVAL '1 second' END,
+        CASE WHEN sqlc.arg(retry_after_seconds)::int > 0
+            THEN NOW() + sqlc.arg(retry_after_seconds)::int * INTERVAL '1 second' END
+    ),
+    last_error = EXCLUDED.last_error,
+    last_failure_at = NOW(),
+    updated_at = NOW()
+RETURNING *;
+
+-- name: RecordProviderSuccess :exec
+-- Closes the provider's circuit.
+UPDATE provider_circuits SET
+    consecutive_failures = 0,
+    open_until = NULL,
+    updated_at = NOW()
+WHERE tenant_id = $1 AND provider = $2 AND consecutive_failures > 0;
+
+-- name: ListProviderCircuits :many
+SELECT * FROM provider_circuits
+ORDER BY tenant_id, provider;
+
+-- name: ResetProviderCircuit :execrows
+UPDATE provider_circuits SET
+    consecutive_failures = 0,
+    open_until = NULL,
+    updated_at = NOW()
+WHERE tenant_id = $1 AND provider = $2;
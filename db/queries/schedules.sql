@@ -0,0 +1,90 @@
+-- name: CreateSchedule :one
+INSERT INTO schedules (
+    tenant_id, name, cron_expr, timezone, job_type, payload, next_run_at, queue, priority,
+    max_attempts, backoff_base_seconds, backoff_max_seconds, backoff_jitter
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+RETURNING *;
+
+-- name: GetSchedule :one
+SELECT * FROM schedules
+WHERE id = $1 AND tenant_id = $2;
+
+-- name: ListSchedules :many
+SELECT * FROM schedules
+WHERE tenant_id = $1
+ORDER BY name;
+
+-- name: UpdateSchedule :one
+UPDATE schedules
+SET cron_expr            = $3,
+    timezone             = $4,
+    job_type             = $5,
+    payload              = $6,
+    next_run_at          = $7,
+    queue                = $8,
+    priority             = $9,
+    max_attempts         = $10,
+    backoff_base_seconds = $11,
+    backoff_max_seconds  = $12,
+    backoff_jitter       = $13,
+    updated_at           = NOW()
+WHERE id = $1 AND tenant_id = $2
+RETURNING *;
+
+-- name: PauseSchedule :one
+UPDATE schedules
+SET paused = TRUE, updated_at = NOW()
+WHERE id = $1 AND tenant_id = $2
+RETURNING *;
+
+-- name: ResumeSchedule :one
+-- next_run_at is recomputed by the caller so runs missed while paused are skipped.
+UPDATE schedules
+SET paused = FALSE, next_run_at = $3, updated_at = NOW()
+WHERE id = $1 AND tenant_id = $2
+RETURNING *;
+
+-- name: DeleteSchedule :execrows
+DELETE FROM schedules
+WHERE id = $1 AND tenant_id = $2;
+
+-- name: ListDueSchedules :many
+-- Schedules of suspended tenants are held until the tenant is reactivated.
+SELECT * FROM schedules
+WHERE NOT paused AND next_run_at <= NOW()
+  AND NOT EXISTS (SELECT 1 FROM tenants t WHERE t.id = schedules.tenant_id AND t.suspended_at IS NOT NULL)
+ORDER BY next_run_at
+LIMIT $1;
+
+-- name: FireSchedule :one
+-- Advances a due schedule and enqueues its job in a single statement. The
+-- update only matches while next_run_at still equals due_at, so when several
+-- workers race on the same run exactly one inserts a job; the rest get no rows.
+WITH fired AS (
+    UPDATE schedules
+    SET next_run_at = sqlc.arg(next_run_at),
+        last_run_at = sqlc.arg(due_at),
+        updated_at  = NOW()
+    WHERE id = sqlc.arg(id) AND next_run_at = sqlc.arg(due_at) AND NOT paused
+    RETURNING tenant_id, job_type, payload, queue, priority,
+        max_attempts, backoff_base_seconds, backoff_max_seconds, backoff_jitter
+)
+INSERT INTO jobs (
+    tenant_id, job_type, payload, provider, run_at, queue, priority,
+    max_attempts, backoff_base_seconds, backoff_max_seconds, backoff_jitter
+)
+SELECT tenant_id, job_type, payload, COALESCE(payload->>'provider', ''), sqlc.arg(due_at), queue, priority,
+    max_attempts, backoff_base_seconds, backoff_max_seconds, backoff_jitter
+FROM fired
+RETURNING *;
+
+-- name: ListPlaintextSchedulePayloads :many
+-- Schedules whose payload predates payload encryption.
+SELECT id, tenant_id, payload FROM schedules
+WHERE NOT payload ? 'ciphertext'
+LIMIT $1;
+
+-- name: SealSchedulePayload :execrows
+UPDATE schedules SET payload = $2
+WHERE id = $1 AND NOT payload ? 'ciphertext';
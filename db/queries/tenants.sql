@@ -1,12 +1,45 @@
 -- name: CreateTenant :one
-INSERT INTO tenants (api_key_hash, encrypted_data_key)
-VALUES ($1, $2)
-RETURNING *;
-
--- name: GetTenantByAPIKeyHash :one
-SELECT * FROM tenants
-WHERE api_key_hash = $1;
+-- Inserts the tenant with its first API key, named "default".
+WITH tenant AS (
+    INSERT INTO tenants (name, encrypted_data_key)
+    VALUES (sqlc.arg(name), sqlc.arg(encrypted_data_key))
+    RETURNING *
+), first_key AS (
+    INSERT INTO api_keys (tenant_id, name, key_hash)
+    SELECT id, 'default', sqlc.arg(key_hash) FROM tenant
+)
+SELECT * FROM tenant;
 
 -- name: GetTenantByID :one
 SELECT * FROM tenants
 WHERE id = $1;
+
+-- name: SetTenantJobRetention :one
+UPDATE tenants SET job_retention_days = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: ListTenants :many
+-- Oldest first, starting after the tenant after_id if given.
+SELECT * FROM tenants
+WHERE sqlc.narg(after_id)::uuid IS NULL
+   OR (created_at, id) > (SELECT a.created_at, a.id FROM tenants a WHERE a.id = sqlc.narg(after_id)::uuid)
+ORDER BY created_at, id
+LIMIT sqlc.arg(max_results);
+
+-- name: SuspendTenant :one
+-- Suspending a suspended tenant keeps its original suspended_at.
+UPDATE tenants SET suspended_at = COALESCE(suspended_at, NOW())
+WHERE id = $1
+RETURNING *;
+
+-- name: ReactivateTenant :one
+UPDATE tenants SET suspended_at = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteTenant :execrows
+-- Deletes a suspended tenant with everything it owns. Active tenants must be
+-- suspended first.
+DELETE FROM tenants
+WHERE id = $1 AND suspended_at IS NOT NULL;
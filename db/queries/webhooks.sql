@@ -0,0 +1,72 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (tenant_id, url, events, encrypted_secret)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1 AND tenant_id = $2;
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE tenant_id = $1
+ORDER BY created_at;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1 AND tenant_id = $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+-- Records a delivery of event for the finished job to each of its tenant's
+-- endpoints subscribed to it, and queues a webhook.deliver job to send each.
+-- Webhook jobs themselves raise no events.
+WITH deliveries AS (
+    INSERT INTO webhook_deliveries (endpoint_id, tenant_id, job_id, event, data, max_attempts)
+    SELECT e.id, j.tenant_id, j.id, sqlc.arg(event)::text,
+        jsonb_build_object(
+            'job_id', j.id,
+            'job_type', j.job_type,
+            'status', j.status,
+            'attempt', j.attempt,
+            'error', j.error,
+            'completed_at', j.completed_at
+        ),
+        sqlc.arg(max_attempts)::int
+    FROM jobs j
+    JOIN webhook_endpoints e ON e.tenant_id = j.tenant_id
+    WHERE j.id = sqlc.arg(job_id) AND j.job_type <> 'webhook.deliver'
+      AND sqlc.arg(event)::text = ANY(e.events)
+    RETURNING id, tenant_id
+)
+INSERT INTO jobs (tenant_id, job_type, payload, queue, max_attempts, backoff_base_seconds, backoff_max_seconds, backoff_jitter)
+SELECT tenant_id, 'webhook.deliver', jsonb_build_object('delivery_id', id), 'webhooks',
+    sqlc.arg(max_attempts)::int, sqlc.arg(backoff_base_seconds)::int, sqlc.arg(backoff_max_seconds)::int, 0.2
+FROM deliveries;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1 AND tenant_id = $2;
+
+-- name: RecordWebhookAttempt :one
+-- Logs the outcome of one delivery attempt. A delivery that fails its last
+-- attempt, or fails with final set because retrying cannot help, is marked
+-- failed.
+UPDATE webhook_deliveries SET
+    attempts = attempts + 1,
+    status = CASE
+        WHEN sqlc.arg(succeeded)::bool THEN 'succeeded'
+        WHEN sqlc.arg(final)::bool OR attempts + 1 >= max_attempts THEN 'failed'
+        ELSE 'pending'
+    END,
+    response_status = sqlc.narg(response_status),
+    last_error = sqlc.narg(last_error),
+    delivered_at = CASE WHEN sqlc.arg(succeeded)::bool THEN NOW() END
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: ListWebhookDeliveries :many
+-- Newest first.
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1 AND tenant_id = $2
+ORDER BY created_at DESC, id
+LIMIT $3;
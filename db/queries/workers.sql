@@ -0,0 +1,53 @@
+-- name: RegisterWorker :one
+-- Records a worker starting up. A worker restarting under the same id takes
+-- over its previous row.
+INSERT INTO workers (id, hostname, queues, concurrency)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (id) DO UPDATE SET
+    hostname = EXCLUDED.hostname,
+    queues = EXCLUDED.queues,
+    concurrency = EXCLUDED.concurrency,
+    started_at = NOW(),
+    last_heartbeat_at = NOW(),
+    stopped_at = NULL
+RETURNING *;
+
+-- name: HeartbeatWorker :execrows
+-- Returns 0 if the worker's row has been pruned and it must register again.
+UPDATE workers SET last_heartbeat_at = NOW()
+WHERE id = $1 AND stopped_at IS NULL;
+
+-- name: StopWorker :exec
+UPDATE workers SET stopped_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteStaleWorkers :execrows
+-- Prunes workers that stopped, or stopped heartbeating, long ago.
+DELETE FROM workers
+WHERE last_heartbeat_at < NOW() - sqlc.arg(older_than_seconds)::int * INTERVAL '1 second';
+
+-- name: ListWorkers :many
+SELECT * FROM workers
+ORDER BY started_at DESC, id;
+
+-- name: ListRunningJobs :many
+-- The running jobs of every tenant with the worker running each one.
+SELECT a.worker_id, j.id, j.tenant_id, j.job_type, j.queue, j.attempt, a.started_at
+FROM jobs j
+JOIN job_attempts a ON a.job_id = j.id AND a.attempt = j.attempt
+WHERE j.status = 'running' AND a.finished_at IS NULL
+ORDER BY a.started_at;
+
+-- name: GetQueueStats :many
+-- Per-queue depth across all tenants. pending counts jobs due now; scheduled
+-- counts jobs waiting for a future run_at or a retry backoff.
+SELECT
+    queue,
+    COUNT(*) FILTER (WHERE status = 'pending' AND run_at <= NOW()) AS pending,
+    COUNT(*) FILTER (WHERE status = 'pending' AND run_at > NOW()) AS scheduled,
+    COUNT(*) FILTER (WHERE status = 'running') AS running,
+    MIN(run_at) FILTER (WHERE status = 'pending' AND run_at <= NOW())::timestamptz AS oldest_pending_run_at
+FROM jobs
+WHERE status IN ('pending', 'running')
+GROUP BY queue
+ORDER BY queue;
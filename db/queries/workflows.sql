@@ -0,0 +1,142 @@
+-- name: CreateWorkflow :one
+-- Inserts the workflow and its steps, given as a JSON array of objects with
+-- the fields named below, and queues a job for each step with queued set: the
+-- steps no edge leads to. Either everything is created or nothing is.
+WITH workflow AS (
+    INSERT INTO workflows (tenant_id, name)
+    VALUES (sqlc.arg(tenant_id), sqlc.arg(name))
+    RETURNING *
+), steps AS (
+    INSERT INTO workflow_steps (
+        workflow_id, name, position, job_type, provider, payload, queue, priority,
+        max_attempts, backoff_base_seconds, backoff_max_seconds, backoff_jitter,
+        on_success, on_failure, status, job_id
+    )
+    SELECT w.id, s.name, s.position, s.job_type, s.provider, s.payload, s.queue, s.priority,
+        s.max_attempts, s.backoff_base_seconds, s.backoff_max_seconds, s.backoff_jitter,
+        s.on_success, s.on_failure,
+        CASE WHEN s.queued THEN 'queued' ELSE 'waiting' END,
+        CASE WHEN s.queued THEN gen_random_uuid() END
+    FROM workflow w, jsonb_to_recordset(sqlc.arg(steps)::jsonb) AS s(
+        name TEXT, position INT, job_type TEXT, provider TEXT, payload JSONB, queue TEXT, priority INT,
+        max_attempts INT, backoff_base_seconds INT, backoff_max_seconds INT, backoff_jitter DOUBLE PRECISION,
+        on_success TEXT[], on_failure TEXT[], queued BOOL
+    )
+    RETURNING workflow_id, job_id, job_type, provider, payload, queue, priority,
+        max_attempts, backoff_base_seconds, backoff_max_seconds, backoff_jitter
+), queued AS (
+    INSERT INTO jobs (
+        id, tenant_id, job_type, payload, provider, queue, priority,
+        max_attempts, backoff_base_seconds, backoff_max_seconds, backoff_jitter, workflow_id
+    )
+    SELECT s.job_id, w.tenant_id, s.job_type, s.payload, s.provider, s.queue, s.priority,
+        s.max_attempts, s.backoff_base_seconds, s.backoff_max_seconds, s.backoff_jitter, s.workflow_id
+    FROM steps s JOIN workflow w ON w.id = s.workflow_id
+    WHERE s.job_id IS NOT NULL
+)
+SELECT * FROM workflow;
+
+-- name: GetWorkflow :one
+SELECT * FROM workflows
+WHERE id = $1 AND tenant_id = $2;
+
+-- name: ListWorkflows :many
+-- Newest first.
+SELECT * FROM workflows
+WHERE tenant_id = $1
+ORDER BY created_at DESC, id
+LIMIT $2;
+
+-- name: ListWorkflowSteps :many
+-- In the order they were submitted.
+SELECT * FROM workflow_steps
+WHERE workflow_id = $1
+ORDER BY position;
+
+-- name: QueueWorkflowStep :one
+-- Queues the job for a waiting step with its rendered payload. Returns no rows
+-- if the step is no longer waiting, e.g. because the worker that finished
+-- another of its upstream steps queued it first.
+WITH step AS (
+    UPDATE workflow_steps SET
+        status = 'queued',
+        job_id = gen_random_uuid()
+    WHERE workflow_id = sqlc.arg(workflow_id) AND name = sqlc.arg(name) AND status = 'waiting'
+    RETURNING workflow_id, job_id, job_type, provider, queue, priority,
+        max_attempts, backoff_base_seconds, backoff_max_seconds, backoff_jitter
+)
+INSERT INTO jobs (
+    id, tenant_id, job_type, payload, provider, queue, priority,
+    max_attempts, backoff_base_seconds, backoff_max_seconds, backoff_jitter, workflow_id
+)
+SELECT s.job_id, w.tenant_id, s.job_type, sqlc.arg(payload), s.provider, s.queue, s.priority,
+    s.max_attempts, s.backoff_base_seconds, s.backoff_max_seconds, s.backoff_jitter, s.workflow_id
+FROM step s JOIN workflows w ON w.id = s.workflow_id
+RETURNING *;
+
+-- name: SkipWorkflowStep :execrows
+-- Marks a waiting step that none of its upstream steps triggered.
+UPDATE workflow_steps SET status = 'skipped'
+WHERE workflow_id = $1 AND name = $2 AND status = 'waiting';
+
+-- name: LockJobWorkflow :one
+-- Locks the workflow of job_id's step until the end of the transaction, so
+-- that the steps of a workflow advance one after another. Returns no rows if
+-- the job is not a workflow step's.
+SELECT w.id FROM workflows w
+JOIN workflow_steps s ON s.workflow_id = w.id
+WHERE s.job_id = $1
+FOR UPDATE OF w;
+
+-- name: FinishWorkflowStep :one
+-- Copies the outcome of a step's finished job onto the step. Returns no rows
+-- if the job is not a queued step's: a step's outcome is decided once.
+UPDATE workflow_steps s SET
+    status = j.status,
+    output = j.output,
+    error = j.error
+FROM jobs j
+WHERE s.job_id = sqlc.arg(job_id) AND j.id = s.job_id AND s.status = 'queued'
+  AND j.status IN ('completed', 'failed', 'cancelled')
+RETURNING s.*;
+
+-- name: FinishWorkflow :execrows
+UPDATE workflows SET
+    status = $2,
+    completed_at = NOW()
+WHERE id = $1 AND status = 'running';
+
+-- name: ListStalledWorkflowSteps :many
+-- Jobs of queued steps that finished over a minute ago without their outcome
+-- being recorded on the step, because advancing the workflow failed. Jobs
+-- finished without a completion time count from their last start.
+SELECT j.id, j.tenant_id FROM workflow_steps s
+JOIN jobs j ON j.id = s.job_id
+WHERE s.status = 'queued' AND j.status IN ('completed', 'failed', 'cancelled')
+  AND COALESCE(j.completed_at, j.started_at, j.run_at) < NOW() - INTERVAL '1 minute'
+LIMIT $1;
+
+-- name: ListPlaintextWorkflowStepPayloads :many
+-- Workflow steps whose payload predates payload encryption.
+SELECT s.workflow_id, s.name, w.tenant_id, s.payload
+FROM workflow_steps s
+JOIN workflows w ON w.id = s.workflow_id
+WHERE NOT s.payload ? 'ciphertext'
+LIMIT $1;
+
+-- name: SealWorkflowStepPayload :execrows
+UPDATE workflow_steps SET payload = $3
+WHERE workflow_id = $1 AND name = $2 AND NOT payload ? 'ciphertext';
+
+-- name: PurgeFinishedWorkflows :execrows
+-- Deletes up to batch_size finished workflows, with their steps, under the
+-- same retention windows as PurgeFinishedJobs.
+DELETE FROM workflows
+WHERE id IN (
+    SELECT w.id FROM workflows w
+    JOIN tenants t ON t.id = w.tenant_id
+    WHERE w.status <> 'running'
+      AND w.completed_at < NOW() - make_interval(days => COALESCE(t.job_retention_days, sqlc.narg(default_days)::int))
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE OF w SKIP LOCKED
+);
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/gsarma/tusker/internal/store"
+)
+
+// AdminAuth admits requests bearing the operator's admin key. Tenant API keys
+// are not accepted.
+func AdminAuth(adminKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing admin key"})
+			return
+		}
+		rawKey := strings.TrimPrefix(header, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(rawKey), []byte(adminKey)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin key"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// circuitResponse is the admin view of a tenant's circuit breaker for a
+// provider. State is "open" while claims for the provider are paused, and
+// "closed" otherwise; a closed circuit may still be counting failures.
+type circuitResponse struct {
+	TenantID            uuid.UUID  `json:"tenant_id"`
+	Provider            string     `json:"provider"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	OpenUntil           *time.Time `json:"open_until"`
+	LastError           *string    `json:"last_error"`
+	LastFailureAt       *time.Time `json:"last_failure_at"`
+}
+
+func toCircuitResponse(p store.ProviderCircuit, now time.Time) circuitResponse {
+	r := circuitResponse{
+		TenantID:            p.TenantID,
+		Provider:            p.Provider,
+		State:               "closed",
+		ConsecutiveFailures: p.ConsecutiveFailures,
+		LastFailureAt:       p.LastFailureAt,
+	}
+	if p.OpenUntil != nil && p.OpenUntil.After(now) {
+		r.State = "open"
+		r.OpenUntil = p.OpenUntil
+	}
+	if p.LastError.Valid {
+		r.LastError = &p.LastError.String
+	}
+	return r
+}
+
+// ListCircuits returns the circuit breaker state of every tenant and provider
+// that has recorded a failure.
+func (h *Handler) ListCircuits(c *gin.Context) {
+	rows, err := h.queries.ListProviderCircuits(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list circuits"})
+		return
+	}
+	now := time.Now()
+	result := make([]circuitResponse, 0, len(rows))
+	for _, p := range rows {
+		result = append(result, toCircuitResponse(p, now))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ResetCircuit closes a tenant's circuit for a provider, resuming claims of
+// its jobs immediately, e.g. once the provider's status page reports recovery.
+func (h *Handler) ResetCircuit(c *gin.Context) {
+	tenantID, err := uuid.Parse(c.Param("tenant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant id"})
+		return
+	}
+	n, err := h.queries.ResetProviderCircuit(c.Request.Context(), store.ResetProviderCircuitParams{
+		TenantID: tenantID,
+		Provider: c.Param("provider"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset circuit"})
+		return
+	}
+	if n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "circuit not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "closed"})
+}
+
+// workerLostAfter is how long a worker may go without a heartbeat before it
+// is reported lost; workers heartbeat every 15s by default.
+const workerLostAfter = time.Minute
+
+// runningJobResponse is a job a worker is executing.
+type runningJobResponse struct {
+	JobID     uuid.UUID `json:"job_id"`
+	TenantID  uuid.UUID `json:"tenant_id"`
+	JobType   string    `json:"job_type"`
+	Queue     string    `json:"queue"`
+	Attempt   int32     `json:"attempt"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// workerResponse is the admin view of a registered worker. State is "alive"
+// while it heartbeats, "lost" once it has missed heartbeats for
+// workerLostAfter without shutting down, and "stopped" after a clean
+// shutdown. Queues holds "*" for a pool serving every queue.
+type workerResponse struct {
+	ID              string               `json:"id"`
+	Hostname        string               `json:"hostname"`
+	Queues          []string             `json:"queues"`
+	Concurrency     int32                `json:"concurrency"`
+	State           string               `json:"state"`
+	StartedAt       time.Time            `json:"started_at"`
+	LastHeartbeatAt time.Time            `json:"last_heartbeat_at"`
+	StoppedAt       *time.Time           `json:"stopped_at"`
+	RunningJobs     []runningJobResponse `json:"running_jobs"`
+}
+
+// queueStatsResponse is the depth of a queue across all tenants.
+type queueStatsResponse struct {
+	Queue                   string   `json:"queue"`
+	Pending                 int64    `json:"pending"`
+	Scheduled               int64    `json:"scheduled"`
+	Running                 int64    `json:"running"`
+	OldestPendingAgeSeconds *float64 `json:"oldest_pending_age_seconds"`
+}
+
+func toWorkerResponse(w store.Worker, now time.Time) workerResponse {
+	r := workerResponse{
+		ID:              w.ID,
+		Hostname:        w.Hostname,
+		Queues:          w.Queues,
+		Concurrency:     w.Concurrency,
+		State:           "alive",
+		StartedAt:       w.StartedAt,
+		LastHeartbeatAt: w.LastHeartbeatAt,
+		StoppedAt:       w.StoppedAt,
+		RunningJobs:     []runningJobResponse{},
+	}
+	switch {
+	case w.StoppedAt != nil:
+		r.State = "stopped"
+	case now.Sub(w.LastHeartbeatAt) > workerLostAfter:
+		r.State = "lost"
+	}
+	return r
+}
+
+// Status reports the registered workers with the jobs each is running, and
+// the pending, scheduled and running depth of every queue.
+func (h *Handler) Status(c *gin.Context) {
+	ctx := c.Request.Context()
+	workers, err := h.queries.ListWorkers(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list workers"})
+		return
+	}
+	running, err := h.queries.ListRunningJobs(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list running jobs"})
+		return
+	}
+	queues, err := h.queries.GetQueueStats(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get queue stats"})
+		return
+	}
+
+	now := time.Now()
+	workerResult := make([]workerResponse, 0, len(workers))
+	byID := make(map[string]int, len(workers))
+	for _, w := range workers {
+		byID[w.ID] = len(workerResult)
+		workerResult = append(workerResult, toWorkerResponse(w, now))
+	}
+	for _, j := range running {
+		i, ok := byID[j.WorkerID]
+		if !ok {
+			continue
+		}
+		workerResult[i].RunningJobs = append(workerResult[i].RunningJobs, runningJobResponse{
+			JobID:     j.ID,
+			TenantID:  j.TenantID,
+			JobType:   j.JobType,
+			Queue:     j.Queue,
+			Attempt:   j.Attempt,
+			StartedAt: j.StartedAt,
+		})
+	}
+
+	queueResult := make([]queueStatsResponse, 0, len(queues))
+	for _, q := range queues {
+		r := queueStatsResponse{
+			Queue:     q.Queue,
+			Pending:   q.Pending,
+			Scheduled: q.Scheduled,
+			Running:   q.Running,
+		}
+		if q.OldestPendingRunAt != nil {
+			age := now.Sub(*q.OldestPendingRunAt).Seconds()
+			r.OldestPendingAgeSeconds = &age
+		}
+		queueResult = append(queueResult, r)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"workers": workerResult, "queues": queueResult})
+}
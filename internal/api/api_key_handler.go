@@ -0,0 +1,178 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/gsarma/tusker/internal/store"
+	"github.com/gsarma/tusker/internal/tenant"
+)
+
+const maxAPIKeyNameLength = 100
+
+// apiKeyResponse describes one of the tenant's API keys. Status is "active",
+// "expired" or "revoked". Key holds the raw key only in the response that
+// creates it.
+type apiKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Key        string     `json:"api_key,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+func toAPIKeyResponse(k store.ApiKey, now time.Time) apiKeyResponse {
+	r := apiKeyResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		Scopes:     k.Scopes,
+		Status:     "active",
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+		ExpiresAt:  k.ExpiresAt,
+		RevokedAt:  k.RevokedAt,
+	}
+	switch {
+	case k.RevokedAt != nil:
+		r.Status = "revoked"
+	case k.ExpiresAt != nil && !k.ExpiresAt.After(now):
+		r.Status = "expired"
+	}
+	return r
+}
+
+// CreateAPIKey issues a new API key for the tenant, e.g. to replace a leaked
+// one before revoking it, or to give a service only the access it needs. The
+// raw key is shown only in this response.
+//
+// Request body:
+//
+//	{ "name": "billing-service", "scopes": ["email:send"], "expires_at": "2027-01-01T00:00:00Z" }
+//
+// scopes lists the routes the key may call ("*" for all of them); a key can
+// only grant scopes it holds itself. expires_at is optional; without it the
+// key is valid until revoked.
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	t := tenant.FromContext(c)
+
+	var body struct {
+		Name      string     `json:"name" binding:"required"`
+		Scopes    []string   `json:"scopes" binding:"required"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(body.Name) > maxAPIKeyNameLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be at most 100 characters"})
+		return
+	}
+	if body.ExpiresAt != nil && !body.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+	if len(body.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scopes must not be empty"})
+		return
+	}
+	for _, sc := range body.Scopes {
+		if !tenant.ValidScope(sc) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown scope %q", sc)})
+			return
+		}
+	}
+	for _, sc := range body.Scopes {
+		if !tenant.HasScope(c, sc) {
+			tenant.AbortMissingScope(c, sc)
+			return
+		}
+	}
+
+	rawKey, keyHash, err := tenant.NewAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate API key"})
+		return
+	}
+	k, err := h.queries.CreateAPIKey(c.Request.Context(), store.CreateAPIKeyParams{
+		TenantID:  t.ID,
+		Name:      body.Name,
+		KeyHash:   keyHash,
+		ExpiresAt: body.ExpiresAt,
+		Scopes:    body.Scopes,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create API key"})
+		return
+	}
+	resp := toAPIKeyResponse(k, time.Now())
+	resp.Key = rawKey
+	c.JSON(http.StatusCreated, resp)
+}
+
+// ListAPIKeys returns the tenant's API keys, oldest first, including expired
+// and revoked ones. Keys themselves are never returned.
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	t := tenant.FromContext(c)
+
+	keys, err := h.queries.ListAPIKeys(c.Request.Context(), t.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list API keys"})
+		return
+	}
+	now := time.Now()
+	result := make([]apiKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, toAPIKeyResponse(k, now))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// RevokeAPIKey revokes one of the tenant's API keys; requests using it are
+// rejected from then on. The tenant's last active key cannot be revoked (409):
+// create its replacement first.
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	t := tenant.FromContext(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid API key id"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	n, err := h.queries.RevokeAPIKey(ctx, store.RevokeAPIKeyParams{ID: id, TenantID: t.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke API key"})
+		return
+	}
+	if n > 0 {
+		c.JSON(http.StatusOK, gin.H{"id": id, "status": "revoked"})
+		return
+	}
+
+	// Nothing revoked: find out why.
+	k, err := h.queries.GetAPIKey(ctx, store.GetAPIKeyParams{ID: id, TenantID: t.ID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke API key"})
+		return
+	}
+	if k.RevokedAt != nil {
+		c.JSON(http.StatusOK, gin.H{"id": id, "status": "revoked"})
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "cannot revoke the last active API key; create another first"})
+}
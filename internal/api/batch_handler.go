@@ -0,0 +1,243 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+
+	"github.com/gsarma/tusker/internal/store"
+	"github.com/gsarma/tusker/internal/tenant"
+)
+
+// maxBatchItems caps how many jobs one batch request may queue.
+const maxBatchItems = 10000
+
+// batchItemBody is one job of a batch submission: a job of any type a send or
+// execute endpoint queues, with the same payload a schedule takes.
+type batchItemBody struct {
+	JobType string          `json:"job_type"`
+	Payload json.RawMessage `json:"payload"`
+	jobOptions
+}
+
+// batchJobRow is a job in the form CreateBatch inserts it.
+type batchJobRow struct {
+	ID                 uuid.UUID       `json:"id"`
+	JobType            string          `json:"job_type"`
+	Provider           string          `json:"provider"`
+	Payload            json.RawMessage `json:"payload"`
+	Queue              string          `json:"queue"`
+	Priority           int32           `json:"priority"`
+	MaxAttempts        int32           `json:"max_attempts"`
+	BackoffBaseSeconds int32           `json:"backoff_base_seconds"`
+	BackoffMaxSeconds  int32           `json:"backoff_max_seconds"`
+	BackoffJitter      float64         `json:"backoff_jitter"`
+	RunAt              *time.Time      `json:"run_at"`
+}
+
+// CreateBatch queues many jobs in one request, e.g. a campaign's emails, all
+// or none of them. Each item takes the job options of the send and execute
+// endpoints, send_at included, and needs the API key scope of its job type's
+// endpoint. The response lists the queued job ids in item order;
+// GET /batches/:id reports the batch's progress.
+//
+// Request body:
+//
+//	{
+//	  "items": [
+//	    { "job_type": "email.send_template",
+//	      "payload": { "provider": "sendgrid", "template": "welcome", "to": ["a@example.com"], "from": "...", "variables": { "name": "Ada" } } },
+//	    { "job_type": "sms.send", "queue": "bulk",
+//	      "payload": { "provider": "twilio", "from": "...", "to": "...", "body": "Hi" } }
+//	  ]
+//	}
+func (h *Handler) CreateBatch(c *gin.Context) {
+	t := tenant.FromContext(c)
+
+	var body struct {
+		Items []batchItemBody `json:"items" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(body.Items) == 0 || len(body.Items) > maxBatchItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("items must hold between 1 and %d jobs", maxBatchItems)})
+		return
+	}
+
+	rows := make([]batchJobRow, len(body.Items))
+	jobTypes := map[string]bool{}
+	for i, item := range body.Items {
+		row, err := h.batchJobRow(item)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("item %d: %v", i, err)})
+			return
+		}
+		rows[i] = row
+		jobTypes[row.JobType] = true
+	}
+	for jobType := range jobTypes {
+		if !requireJobScopes(c, jobType) {
+			return
+		}
+	}
+
+	// Unwrap the data key once rather than per item.
+	dataKey, err := h.tenantSvc.DataKey(t)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption error"})
+		return
+	}
+	jobIDs := make([]uuid.UUID, len(rows))
+	for i := range rows {
+		if rows[i].Payload, err = sealWithDataKey(dataKey, rows[i].Payload); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption error"})
+			return
+		}
+		jobIDs[i] = rows[i].ID
+	}
+	jobsJSON, err := json.Marshal(rows)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode batch jobs"})
+		return
+	}
+
+	b, err := h.queries.CreateBatch(c.Request.Context(), store.CreateBatchParams{
+		TenantID: t.ID,
+		Jobs:     jobsJSON,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue batch"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"batch_id": b.ID,
+		"total":    b.Total,
+		"job_ids":  jobIDs,
+		"status":   "queued",
+	})
+}
+
+// jobPayloadBodies holds, for each job type batches and schedules may queue, a
+// constructor for the request body of the endpoint that queues one such job.
+// A job type missing here can only be queued by its endpoint.
+var jobPayloadBodies = map[string]func() any{
+	"email.send":          func() any { return new(sendEmailBody) },
+	"email.send_template": func() any { return new(sendTemplateBody) },
+	"sms.send":            func() any { return new(sendSMSBody) },
+	"code.execute":        func() any { return new(executeCodeBody) },
+}
+
+// bindJobPayload checks a jobType job's payload as the endpoint queueing such
+// jobs checks its request body, and returns the payload's provider.
+func bindJobPayload(jobType string, payload json.RawMessage) (string, error) {
+	if p := bytes.TrimSpace(payload); len(p) == 0 || p[0] != '{' {
+		return "", errors.New("payload must be a JSON object")
+	}
+	var p struct {
+		Provider string `json:"provider"`
+	}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return "", fmt.Errorf("invalid payload: %w", err)
+	}
+	if p.Provider == "" {
+		return "", errors.New("payload.provider is required")
+	}
+	body := jobPayloadBodies[jobType]()
+	if err := json.Unmarshal(payload, body); err != nil {
+		return "", fmt.Errorf("invalid payload: %w", err)
+	}
+	if err := binding.Validator.ValidateStruct(body); err != nil {
+		return "", err
+	}
+	return p.Provider, nil
+}
+
+// batchJobRow checks an item's job type, payload and options and resolves
+// them as enqueueJob would.
+func (h *Handler) batchJobRow(item batchItemBody) (batchJobRow, error) {
+	if _, ok := jobPayloadBodies[item.JobType]; !ok || !h.userJobType(item.JobType) {
+		return batchJobRow{}, fmt.Errorf("unknown job_type %q", item.JobType)
+	}
+	provider, err := bindJobPayload(item.JobType, item.Payload)
+	if err != nil {
+		return batchJobRow{}, err
+	}
+	if err := item.jobOptions.validate(false); err != nil {
+		return batchJobRow{}, err
+	}
+	policy, err := retryPolicyFor(item.JobType, item.Retry)
+	if err != nil {
+		return batchJobRow{}, err
+	}
+	return batchJobRow{
+		ID:                 uuid.New(),
+		JobType:            item.JobType,
+		Provider:           provider,
+		Payload:            item.Payload,
+		Queue:              item.queue(),
+		Priority:           item.priority(),
+		MaxAttempts:        policy.MaxAttempts,
+		BackoffBaseSeconds: int32(policy.BaseDelay / time.Second),
+		BackoffMaxSeconds:  int32(policy.MaxDelay / time.Second),
+		BackoffJitter:      policy.Jitter,
+		RunAt:              item.SendAt,
+	}, nil
+}
+
+// batchResponse is a batch's progress. Status is "running" while any of its
+// jobs is pending or running, and "finished" once all of them are done.
+type batchResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Status    string    `json:"status"`
+	Total     int32     `json:"total"`
+	Pending   int64     `json:"pending"`
+	Running   int64     `json:"running"`
+	Completed int64     `json:"completed"`
+	Failed    int64     `json:"failed"`
+	Cancelled int64     `json:"cancelled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func toBatchResponse(b store.GetBatchRow) batchResponse {
+	r := batchResponse{
+		ID:        b.ID,
+		Status:    "finished",
+		Total:     b.Total,
+		Pending:   b.Pending,
+		Running:   b.Running,
+		Completed: b.Completed,
+		Failed:    b.Failed,
+		Cancelled: b.Cancelled,
+		CreatedAt: b.CreatedAt,
+	}
+	if b.Pending > 0 || b.Running > 0 {
+		r.Status = "running"
+	}
+	return r
+}
+
+// GetBatch returns how many of a batch's jobs are pending, running,
+// completed, failed and cancelled.
+func (h *Handler) GetBatch(c *gin.Context) {
+	t := tenant.FromContext(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid batch id"})
+		return
+	}
+	b, err := h.queries.GetBatch(c.Request.Context(), store.GetBatchParams{ID: id, TenantID: t.ID})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "batch not found"})
+		return
+	}
+	c.JSON(http.StatusOK, toBatchResponse(b))
+}
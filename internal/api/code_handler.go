@@ -59,6 +59,14 @@ func (h *Handler) SetCodeProviderConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
+// executeCodeBody is the submission ExecuteCode takes: a code.execute job's
+// payload less its provider.
+type executeCodeBody struct {
+	SourceCode string `json:"source_code" binding:"required"`
+	LanguageID int    `json:"language_id" binding:"required"`
+	Stdin      string `json:"stdin"`
+}
+
 // ExecuteCode queues a code execution job (async by default) or runs immediately with ?sync=true.
 //
 // Request body:
@@ -70,6 +78,7 @@ func (h *Handler) SetCodeProviderConfig(c *gin.Context) {
 //	}
 //
 // Async (default): returns 202 {"job_id": "...", "status": "queued"}.
+// An optional "send_at" (RFC3339) schedules the execution for a future time.
 // Sync (?sync=true): returns 200 with the execution result directly.
 // After async completion, retrieve results via GET /code/executions/:job_id.
 func (h *Handler) ExecuteCode(c *gin.Context) {
@@ -77,32 +86,26 @@ func (h *Handler) ExecuteCode(c *gin.Context) {
 	providerName := c.Param("provider")
 
 	var body struct {
-		SourceCode string `json:"source_code" binding:"required"`
-		LanguageID int    `json:"language_id" binding:"required"`
-		Stdin      string `json:"stdin"`
+		executeCodeBody
+		jobOptions
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	sync := c.Query("sync") == "true"
+	if err := body.jobOptions.validate(sync); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if c.Query("sync") != "true" {
-		payloadJSON, _ := json.Marshal(code.JobPayload{
+	if !sync {
+		h.enqueueJob(c, t, "code.execute", providerName, code.JobPayload{
 			Provider:   providerName,
 			SourceCode: body.SourceCode,
 			LanguageID: body.LanguageID,
 			Stdin:      body.Stdin,
-		})
-		job, err := h.queries.CreateJob(c.Request.Context(), store.CreateJobParams{
-			TenantID: t.ID,
-			JobType:  "code.execute",
-			Payload:  payloadJSON,
-		})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue job"})
-			return
-		}
-		c.JSON(http.StatusAccepted, gin.H{"job_id": job.ID, "status": "queued"})
+		}, body.jobOptions)
 		return
 	}
 
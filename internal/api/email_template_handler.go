@@ -11,6 +11,7 @@ import (
 	"github.com/gsarma/tusker/internal/email"
 	"github.com/gsarma/tusker/internal/store"
 	"github.com/gsarma/tusker/internal/tenant"
+	"github.com/gsarma/tusker/internal/worker"
 )
 
 // UpsertEmailTemplate creates or replaces a named email template for the tenant.
@@ -134,23 +135,55 @@ func (h *Handler) DeleteEmailTemplate(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
 }
 
+// sendTemplateBody is the message SendEmailWithTemplate takes: an
+// email.send_template job's payload less its provider.
+type sendTemplateBody struct {
+	Template  string         `json:"template" binding:"required"`
+	To        []string       `json:"to" binding:"required"`
+	From      string         `json:"from" binding:"required"`
+	Variables map[string]any `json:"variables"`
+}
+
 // SendEmailWithTemplate resolves a named template, renders it with the provided
-// variables, then sends it via the specified email provider.
+// variables, then sends it via the specified email provider. With ?async=true,
+// or any of send_at, retry, queue or priority, the email is queued instead
+// (202 + job_id); an optional send_at (RFC3339) schedules it for a future time.
+// The template is rendered up front so unknown templates and render errors are
+// reported to the caller; queued jobs render again when they run, picking up any
+// template edits made in the meantime.
 func (h *Handler) SendEmailWithTemplate(c *gin.Context) {
 	t := tenant.FromContext(c)
 	providerName := c.Param("provider")
 
 	var body struct {
-		Template  string         `json:"template" binding:"required"`
-		To        []string       `json:"to" binding:"required"`
-		From      string         `json:"from" binding:"required"`
-		Variables map[string]any `json:"variables"`
+		sendTemplateBody
+		jobOptions
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	sync := c.Query("sync") == "true"
+	if err := body.jobOptions.validate(sync); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	async := c.Query("async") == "true"
+	if sync && async {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sync=true cannot be combined with async=true"})
+		return
+	}
+	// Unlike the other send endpoints, send-template has always sent inline, so
+	// it only queues when asked to.
+	queued := async || body.jobOptions.set()
 
+	payload := email.TemplateJobPayload{
+		Provider:  providerName,
+		Template:  body.Template,
+		To:        body.To,
+		From:      body.From,
+		Variables: body.Variables,
+	}
 	def, err := h.resolveTemplate(c.Request.Context(), t, body.Template)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "template not found: " + body.Template})
@@ -163,15 +196,46 @@ func (h *Handler) SendEmailWithTemplate(c *gin.Context) {
 		return
 	}
 
+	if queued {
+		h.enqueueJob(c, t, "email.send_template", providerName, payload, body.jobOptions)
+		return
+	}
+
 	p, err := h.buildEmailProvider(c.Request.Context(), t, providerName)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := p.Send(c.Request.Context(), templateMessage(payload, rendered)); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to send email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "sent"})
+}
+
+// renderTemplateMessage resolves and renders the template named in p into a
+// ready-to-send message. Render errors, from a broken template or missing
+// variables, are permanent: rendering again gives the same result.
+func (h *Handler) renderTemplateMessage(ctx context.Context, t *store.Tenant, p email.TemplateJobPayload) (email.Message, error) {
+	def, err := h.resolveTemplate(ctx, t, p.Template)
+	if err != nil {
+		return email.Message{}, err
+	}
+	rendered, err := email.RenderTemplate(def, p.Variables)
+	if err != nil {
+		return email.Message{}, worker.Permanent(err)
+	}
+	return templateMessage(p, rendered), nil
+}
+
+// templateMessage builds the outgoing message for a rendered template,
+// preferring the HTML body when the template defines one.
+func templateMessage(p email.TemplateJobPayload, rendered email.RenderedTemplate) email.Message {
 	msg := email.Message{
-		To:      body.To,
-		From:    body.From,
+		To:      p.To,
+		From:    p.From,
 		Subject: rendered.Subject,
 		Body:    rendered.Body,
 		HTML:    rendered.HTML != "",
@@ -179,13 +243,7 @@ func (h *Handler) SendEmailWithTemplate(c *gin.Context) {
 	if rendered.HTML != "" {
 		msg.Body = rendered.HTML
 	}
-
-	if err := p.Send(c.Request.Context(), msg); err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to send email"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"status": "sent"})
+	return msg
 }
 
 // resolveTemplate looks up a template by name: DB first, then built-in defaults.
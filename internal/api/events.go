@@ -0,0 +1,247 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/gsarma/tusker/internal/store"
+	"github.com/gsarma/tusker/internal/tenant"
+	"github.com/gsarma/tusker/internal/worker"
+)
+
+const (
+	// sseKeepAlive is how often an idle stream sends a comment line, so
+	// proxies do not time the connection out.
+	sseKeepAlive = 15 * time.Second
+	// subscriberBuffer is how many events a slow stream may fall behind by
+	// before it is closed; clients reconnect and re-read the job's status.
+	subscriberBuffer = 64
+	listenRetryDelay = time.Second
+)
+
+// jobEvent is a job status transition, as published on
+// worker.JobEventsChannel and streamed to clients.
+type jobEvent struct {
+	JobID    uuid.UUID `json:"job_id"`
+	TenantID uuid.UUID `json:"-"`
+	JobType  string    `json:"job_type"`
+	Status   string    `json:"status"`
+	Attempt  int32     `json:"attempt"`
+	Error    *string   `json:"error"`
+	At       time.Time `json:"at"`
+}
+
+// UnmarshalJSON reads tenant_id, which is not sent on to clients.
+func (e *jobEvent) UnmarshalJSON(b []byte) error {
+	type plain jobEvent
+	var v struct {
+		plain
+		TenantID uuid.UUID `json:"tenant_id"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*e = jobEvent(v.plain)
+	e.TenantID = v.TenantID
+	return nil
+}
+
+func isTerminalStatus(status string) bool {
+	return status == "completed" || status == "failed" || status == "cancelled"
+}
+
+// jobEventHub fans job events from a single LISTEN connection out to the
+// streams open on this API server.
+type jobEventHub struct {
+	mu     sync.Mutex
+	subs   map[*jobSubscriber]struct{}
+	closed bool
+}
+
+// jobSubscriber receives the events of one tenant, optionally narrowed to a
+// single job. ch is closed if the subscriber falls too far behind.
+type jobSubscriber struct {
+	tenantID uuid.UUID
+	jobID    uuid.UUID // uuid.Nil for every job
+	ch       chan jobEvent
+}
+
+func newJobEventHub() *jobEventHub {
+	return &jobEventHub{subs: make(map[*jobSubscriber]struct{})}
+}
+
+func (hub *jobEventHub) subscribe(tenantID, jobID uuid.UUID) *jobSubscriber {
+	s := &jobSubscriber{tenantID: tenantID, jobID: jobID, ch: make(chan jobEvent, subscriberBuffer)}
+	hub.mu.Lock()
+	if hub.closed {
+		close(s.ch)
+	} else {
+		hub.subs[s] = struct{}{}
+	}
+	hub.mu.Unlock()
+	return s
+}
+
+func (hub *jobEventHub) unsubscribe(s *jobSubscriber) {
+	hub.mu.Lock()
+	if _, ok := hub.subs[s]; ok {
+		delete(hub.subs, s)
+		close(s.ch)
+	}
+	hub.mu.Unlock()
+}
+
+// close ends every open stream, and any opened later, so that server
+// shutdown is not held up by long-lived connections.
+func (hub *jobEventHub) close() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.closed = true
+	for s := range hub.subs {
+		delete(hub.subs, s)
+		close(s.ch)
+	}
+}
+
+// publish delivers a notification payload to matching subscribers.
+func (hub *jobEventHub) publish(payload string) {
+	var ev jobEvent
+	if err := json.Unmarshal([]byte(payload), &ev); err != nil {
+		log.Printf("events: malformed job event %q: %v", payload, err)
+		return
+	}
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for s := range hub.subs {
+		if s.tenantID != ev.TenantID || (s.jobID != uuid.Nil && s.jobID != ev.JobID) {
+			continue
+		}
+		select {
+		case s.ch <- ev:
+		default:
+			delete(hub.subs, s)
+			close(s.ch)
+		}
+	}
+}
+
+// ListenJobEvents feeds the SSE endpoints from l, which should listen on
+// worker.JobEventsChannel, reconnecting until ctx is cancelled; open streams
+// are then closed. Without it the streams only send each job's current status.
+func (h *Handler) ListenJobEvents(ctx context.Context, l worker.Listener) {
+	defer h.jobEvents.close()
+	for {
+		err := l.Listen(ctx, h.jobEvents.publish)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("events: listen error: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+// StreamJobEvents streams a job's status transitions as Server-Sent Events.
+// The first event is the job's current status; the stream ends after the job
+// reaches completed, failed or cancelled. Each event is sent as:
+//
+//	event: status
+//	data: {"job_id":"...","job_type":"code.execute","status":"running","attempt":1,"error":null,"at":"..."}
+func (h *Handler) StreamJobEvents(c *gin.Context) {
+	t := tenant.FromContext(c)
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+	// Subscribe before reading the job so no transition falls in between.
+	sub := h.jobEvents.subscribe(t.ID, jobID)
+	defer h.jobEvents.unsubscribe(sub)
+
+	job, err := h.queries.GetJob(c.Request.Context(), store.GetJobParams{ID: jobID, TenantID: t.ID})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	current := jobEvent{
+		JobID:   job.ID,
+		JobType: job.JobType,
+		Status:  job.Status,
+		Attempt: job.Attempt,
+		At:      time.Now().UTC(),
+	}
+	if job.Error.Valid {
+		current.Error = &job.Error.String
+	}
+
+	startSSE(c)
+	writeJobEvent(c.Writer, current)
+	if isTerminalStatus(job.Status) {
+		return
+	}
+	streamEvents(c, sub, true)
+}
+
+// StreamTenantJobEvents streams status transitions of all the tenant's jobs
+// as Server-Sent Events, in the same format as StreamJobEvents, until the
+// client disconnects.
+func (h *Handler) StreamTenantJobEvents(c *gin.Context) {
+	t := tenant.FromContext(c)
+	sub := h.jobEvents.subscribe(t.ID, uuid.Nil)
+	defer h.jobEvents.unsubscribe(sub)
+
+	startSSE(c)
+	streamEvents(c, sub, false)
+}
+
+func startSSE(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // disable nginx response buffering
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+}
+
+// streamEvents writes sub's events until the client goes away, the
+// subscriber is dropped for falling behind, or, if untilTerminal is set, a
+// job reaches a terminal status.
+func streamEvents(c *gin.Context, sub *jobSubscriber, untilTerminal bool) {
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case ev, ok := <-sub.ch:
+			if !ok {
+				return
+			}
+			writeJobEvent(c.Writer, ev)
+			if untilTerminal && isTerminalStatus(ev.Status) {
+				return
+			}
+		case <-keepAlive.C:
+			io.WriteString(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeJobEvent(w gin.ResponseWriter, ev jobEvent) {
+	data, _ := json.Marshal(ev)
+	fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+	w.Flush()
+}
@@ -3,30 +3,49 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 
 	"github.com/gsarma/tusker/internal/code"
+	"github.com/gsarma/tusker/internal/crypto"
 	"github.com/gsarma/tusker/internal/email"
 	"github.com/gsarma/tusker/internal/sms"
 	"github.com/gsarma/tusker/internal/store"
+	"github.com/gsarma/tusker/internal/webhook"
+	"github.com/gsarma/tusker/internal/worker"
 )
 
 // Executor handles async execution of a specific job type.
 // Implement this interface and add an instance to registerExecutors to support a new async provider.
+// Wrap errors that retrying cannot fix with worker.Permanent. Executors whose
+// jobs produce a result record it with setJobOutput so workflow steps can use it.
 type Executor interface {
 	JobType() string
 	Execute(ctx context.Context, jobID uuid.UUID, t *store.Tenant, payload json.RawMessage) error
 }
 
+// Cancellable is implemented by executors whose jobs can safely be abandoned
+// part-way through when their context is cancelled. Running jobs of other
+// types cannot be cancelled: interrupting a provider call mid-flight (e.g. an
+// email send) leaves it unknown whether the side effect happened.
+type Cancellable interface {
+	Cancellable() bool
+}
+
 // registerExecutors builds the handler's job-type dispatch table.
 // To add a new async provider, implement Executor and append it here.
 func (h *Handler) registerExecutors() {
 	execs := []Executor{
 		&emailExecutor{h},
+		&emailTemplateExecutor{h},
 		&smsExecutor{h},
 		&codeExecutor{h},
+		&webhookExecutor{h},
 	}
 	h.executors = make(map[string]Executor, len(execs))
 	for _, e := range execs {
@@ -34,7 +53,20 @@ func (h *Handler) registerExecutors() {
 	}
 }
 
-// ExecuteJob implements worker.JobExecutor by dispatching to the registered Executor for the job type.
+// internalJobTypes are queued by the server itself, never by tenants.
+var internalJobTypes = map[string]bool{
+	"webhook.deliver": true,
+}
+
+// userJobType reports whether tenants may queue jobs of jobType through the
+// endpoints that take any type: batches, workflows and schedules.
+func (h *Handler) userJobType(jobType string) bool {
+	_, ok := h.executors[jobType]
+	return ok && !internalJobTypes[jobType]
+}
+
+// ExecuteJob implements worker.JobExecutor by opening the job's sealed payload
+// and dispatching to the registered Executor for the job type.
 func (h *Handler) ExecuteJob(ctx context.Context, jobID uuid.UUID, tenantID uuid.UUID, jobType string, payload json.RawMessage) error {
 	t, err := h.queries.GetTenantByID(ctx, tenantID)
 	if err != nil {
@@ -42,9 +74,26 @@ func (h *Handler) ExecuteJob(ctx context.Context, jobID uuid.UUID, tenantID uuid
 	}
 	exec, ok := h.executors[jobType]
 	if !ok {
-		return fmt.Errorf("unknown job type: %s", jobType)
+		return worker.Permanent(fmt.Errorf("unknown job type: %s", jobType))
+	}
+	plaintext, err := h.openPayload(&t, payload)
+	if err != nil {
+		return worker.Permanent(fmt.Errorf("decrypt job payload: %w", err))
+	}
+	return exec.Execute(ctx, jobID, &t, plaintext)
+}
+
+// setJobOutput records v as the running job's output, which later workflow
+// steps can reference. The job's side effect has already happened, so a
+// failure is logged rather than failing the job.
+func (h *Handler) setJobOutput(ctx context.Context, jobID uuid.UUID, v any) {
+	output, err := json.Marshal(v)
+	if err == nil {
+		err = h.queries.SetJobOutput(context.WithoutCancel(ctx), store.SetJobOutputParams{ID: jobID, Output: output})
+	}
+	if err != nil {
+		log.Printf("executor: failed to record output of job %s: %v", jobID, err)
 	}
-	return exec.Execute(ctx, jobID, &t, payload)
 }
 
 // emailExecutor handles email.send jobs.
@@ -55,7 +104,7 @@ func (e *emailExecutor) JobType() string { return "email.send" }
 func (e *emailExecutor) Execute(ctx context.Context, _ uuid.UUID, t *store.Tenant, raw json.RawMessage) error {
 	var p email.JobPayload
 	if err := json.Unmarshal(raw, &p); err != nil {
-		return fmt.Errorf("invalid email job payload: %w", err)
+		return worker.Permanent(fmt.Errorf("invalid email job payload: %w", err))
 	}
 	provider, err := e.h.buildEmailProvider(ctx, t, p.Provider)
 	if err != nil {
@@ -64,22 +113,47 @@ func (e *emailExecutor) Execute(ctx context.Context, _ uuid.UUID, t *store.Tenan
 	return provider.Send(ctx, p.Message)
 }
 
+// emailTemplateExecutor handles email.send_template jobs.
+type emailTemplateExecutor struct{ h *Handler }
+
+func (e *emailTemplateExecutor) JobType() string { return "email.send_template" }
+
+func (e *emailTemplateExecutor) Execute(ctx context.Context, _ uuid.UUID, t *store.Tenant, raw json.RawMessage) error {
+	var p email.TemplateJobPayload
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return worker.Permanent(fmt.Errorf("invalid email template job payload: %w", err))
+	}
+	msg, err := e.h.renderTemplateMessage(ctx, t, p)
+	if err != nil {
+		return fmt.Errorf("render template %q: %w", p.Template, err)
+	}
+	provider, err := e.h.buildEmailProvider(ctx, t, p.Provider)
+	if err != nil {
+		return err
+	}
+	return provider.Send(ctx, msg)
+}
+
 // smsExecutor handles sms.send jobs.
 type smsExecutor struct{ h *Handler }
 
 func (e *smsExecutor) JobType() string { return "sms.send" }
 
-func (e *smsExecutor) Execute(ctx context.Context, _ uuid.UUID, t *store.Tenant, raw json.RawMessage) error {
+func (e *smsExecutor) Execute(ctx context.Context, jobID uuid.UUID, t *store.Tenant, raw json.RawMessage) error {
 	var p sms.JobPayload
 	if err := json.Unmarshal(raw, &p); err != nil {
-		return fmt.Errorf("invalid sms job payload: %w", err)
+		return worker.Permanent(fmt.Errorf("invalid sms job payload: %w", err))
 	}
 	provider, err := e.h.buildSMSProvider(ctx, t, p.Provider)
 	if err != nil {
 		return err
 	}
-	_, err = provider.Send(ctx, p.From, p.To, p.Body)
-	return err
+	msg, err := provider.Send(ctx, p.From, p.To, p.Body)
+	if err != nil {
+		return err
+	}
+	e.h.setJobOutput(ctx, jobID, map[string]any{"message_sid": msg.SID, "status": msg.Status})
+	return nil
 }
 
 // codeExecutor handles code.execute jobs.
@@ -87,10 +161,13 @@ type codeExecutor struct{ h *Handler }
 
 func (e *codeExecutor) JobType() string { return "code.execute" }
 
+// Cancellable reports true: abandoning a submission only discards its result.
+func (e *codeExecutor) Cancellable() bool { return true }
+
 func (e *codeExecutor) Execute(ctx context.Context, jobID uuid.UUID, t *store.Tenant, raw json.RawMessage) error {
 	var p code.JobPayload
 	if err := json.Unmarshal(raw, &p); err != nil {
-		return fmt.Errorf("invalid code job payload: %w", err)
+		return worker.Permanent(fmt.Errorf("invalid code job payload: %w", err))
 	}
 	provider, err := e.h.buildCodeProvider(ctx, t, p.Provider)
 	if err != nil {
@@ -110,5 +187,74 @@ func (e *codeExecutor) Execute(ctx context.Context, jobID uuid.UUID, t *store.Te
 		ExecTime:      result.Time,
 		Memory:        int32(result.Memory),
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	e.h.setJobOutput(ctx, jobID, map[string]any{
+		"stdout":         result.Stdout,
+		"stderr":         result.Stderr,
+		"compile_output": result.CompileOutput,
+		"status":         result.Status,
+		"time":           result.Time,
+		"memory":         result.Memory,
+	})
+	return nil
+}
+
+// webhookExecutor handles webhook.deliver jobs, queued by the worker when a
+// job completes or fails. Each attempt is recorded in the delivery log.
+type webhookExecutor struct{ h *Handler }
+
+func (e *webhookExecutor) JobType() string { return "webhook.deliver" }
+
+func (e *webhookExecutor) Execute(ctx context.Context, _ uuid.UUID, t *store.Tenant, raw json.RawMessage) error {
+	var p struct {
+		DeliveryID uuid.UUID `json:"delivery_id"`
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return worker.Permanent(fmt.Errorf("invalid webhook job payload: %w", err))
+	}
+	d, err := e.h.queries.GetWebhookDelivery(ctx, store.GetWebhookDeliveryParams{ID: p.DeliveryID, TenantID: t.ID})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// The endpoint was deleted, taking its deliveries with it.
+			return worker.Permanent(fmt.Errorf("webhook delivery %s not found", p.DeliveryID))
+		}
+		return err
+	}
+	ep, err := e.h.queries.GetWebhookEndpoint(ctx, store.GetWebhookEndpointParams{ID: d.EndpointID, TenantID: t.ID})
+	if err != nil {
+		return err
+	}
+	dataKey, err := e.h.tenantSvc.DataKey(t)
+	if err != nil {
+		return fmt.Errorf("encryption error")
+	}
+	secret, err := crypto.DecryptWithDataKey(dataKey, ep.EncryptedSecret)
+	if err != nil {
+		return worker.Permanent(fmt.Errorf("failed to decrypt webhook secret"))
+	}
+
+	status, sendErr := e.h.webhooks.Send(ctx, ep.URL, string(secret), webhook.Event{
+		ID:        d.ID,
+		Type:      d.Event,
+		CreatedAt: d.CreatedAt,
+		Data:      json.RawMessage(d.Data),
+	})
+	attempt := store.RecordWebhookAttemptParams{
+		ID:             d.ID,
+		Succeeded:      sendErr == nil,
+		ResponseStatus: pgtype.Int4{Int32: int32(status), Valid: status != 0},
+	}
+	if sendErr != nil {
+		attempt.LastError = pgtype.Text{String: sendErr.Error(), Valid: true}
+		if worker.IsPermanent(sendErr) {
+			// No further attempts will be made.
+			attempt.Final = true
+		}
+	}
+	if _, err := e.h.queries.RecordWebhookAttempt(context.WithoutCancel(ctx), attempt); err != nil {
+		log.Printf("webhook: failed to record attempt for delivery %s: %v", d.ID, err)
+	}
+	return sendErr
 }
@@ -17,6 +17,8 @@ import (
 	"github.com/gsarma/tusker/internal/oauth"
 	"github.com/gsarma/tusker/internal/store"
 	"github.com/gsarma/tusker/internal/tenant"
+	"github.com/gsarma/tusker/internal/webhook"
+	"github.com/gsarma/tusker/internal/workflow"
 )
 
 type Handler struct {
@@ -24,20 +26,12 @@ type Handler struct {
 	tenantSvc *tenant.Service
 	enc       *crypto.Encryptor
 	executors map[string]Executor
-}
+	webhooks  *webhook.Sender
+	jobEvents *jobEventHub
 
-// CreateTenant provisions a new tenant and returns the API key (shown once).
-func (h *Handler) CreateTenant(c *gin.Context) {
-	apiKey, tenantID, err := h.tenantSvc.Create(c.Request.Context())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create tenant"})
-		return
-	}
-	c.JSON(http.StatusCreated, gin.H{
-		"tenant_id": tenantID,
-		"api_key":   apiKey,
-		"note":      "Store this API key — it will not be shown again.",
-	})
+	// workflowTx runs the transactions that advance workflows; nil advances
+	// them on queries directly.
+	workflowTx workflow.Transactor
 }
 
 // SetProviderConfig stores a tenant's OAuth client credentials for a provider.
@@ -129,6 +123,10 @@ func (h *Handler) Callback(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown tenant"})
 		return
 	}
+	if t.SuspendedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "tenant is suspended"})
+		return
+	}
 
 	p, err := h.buildProvider(ctx, &t, providerName)
 	if err != nil {
@@ -390,25 +388,38 @@ func (h *Handler) SetEmailProviderConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
+// sendEmailBody is the message SendEmail takes: an email.send job's payload
+// less its provider.
+type sendEmailBody struct {
+	To      []string `json:"to" binding:"required"`
+	From    string   `json:"from" binding:"required"`
+	Subject string   `json:"subject" binding:"required"`
+	Body    string   `json:"body" binding:"required"`
+	HTML    bool     `json:"html"`
+}
+
 // SendEmail queues an email job (async by default) or sends immediately with ?sync=true.
+// An optional send_at (RFC3339) schedules the email for a future time.
 func (h *Handler) SendEmail(c *gin.Context) {
 	t := tenant.FromContext(c)
 	providerName := c.Param("provider")
 
 	var body struct {
-		To      []string `json:"to" binding:"required"`
-		From    string   `json:"from" binding:"required"`
-		Subject string   `json:"subject" binding:"required"`
-		Body    string   `json:"body" binding:"required"`
-		HTML    bool     `json:"html"`
+		sendEmailBody
+		jobOptions
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	sync := c.Query("sync") == "true"
+	if err := body.jobOptions.validate(sync); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if c.Query("sync") != "true" {
-		payloadJSON, _ := json.Marshal(email.JobPayload{
+	if !sync {
+		h.enqueueJob(c, t, "email.send", providerName, email.JobPayload{
 			Provider: providerName,
 			Message: email.Message{
 				To:      body.To,
@@ -417,17 +428,7 @@ func (h *Handler) SendEmail(c *gin.Context) {
 				Body:    body.Body,
 				HTML:    body.HTML,
 			},
-		})
-		job, err := h.queries.CreateJob(c.Request.Context(), store.CreateJobParams{
-			TenantID: t.ID,
-			JobType:  "email.send",
-			Payload:  payloadJSON,
-		})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue job"})
-			return
-		}
-		c.JSON(http.StatusAccepted, gin.H{"job_id": job.ID, "status": "queued"})
+		}, body.jobOptions)
 		return
 	}
 
@@ -453,7 +454,9 @@ func (h *Handler) SendEmail(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "sent"})
 }
 
-// GetJob returns the status of a background job scoped to the current tenant.
+// GetJob returns the status of a background job scoped to the current tenant,
+// with a timeline of its attempts: which worker ran each one, when, for how
+// long, and how it ended.
 func (h *Handler) GetJob(c *gin.Context) {
 	t := tenant.FromContext(c)
 	jobID, err := uuid.Parse(c.Param("id"))
@@ -461,7 +464,8 @@ func (h *Handler) GetJob(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
 		return
 	}
-	job, err := h.queries.GetJob(c.Request.Context(), store.GetJobParams{
+	ctx := c.Request.Context()
+	job, err := h.queries.GetJob(ctx, store.GetJobParams{
 		ID:       jobID,
 		TenantID: t.ID,
 	})
@@ -469,7 +473,12 @@ func (h *Handler) GetJob(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
 		return
 	}
-	c.JSON(http.StatusOK, job)
+	detail, err := h.withAttempts(ctx, []store.Job{job})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load attempt history"})
+		return
+	}
+	c.JSON(http.StatusOK, detail[0])
 }
 
 // buildEmailProvider loads the tenant's email provider credentials and constructs the provider.
@@ -3,7 +3,10 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -15,7 +18,12 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 
+	"github.com/gsarma/tusker/internal/crypto"
+	"github.com/gsarma/tusker/internal/email"
 	"github.com/gsarma/tusker/internal/store"
+	"github.com/gsarma/tusker/internal/tenant"
+	"github.com/gsarma/tusker/internal/webhook"
+	"github.com/gsarma/tusker/internal/worker"
 )
 
 func init() {
@@ -28,6 +36,48 @@ type stubQuerier struct {
 	createJobFn      func(ctx context.Context, arg store.CreateJobParams) (store.Job, error)
 	getJobFn         func(ctx context.Context, arg store.GetJobParams) (store.Job, error)
 	getTenantByIDFn  func(ctx context.Context, id uuid.UUID) (store.Tenant, error)
+	listJobsFn       func(ctx context.Context, arg store.ListJobsParams) ([]store.Job, error)
+	countJobsFn      func(ctx context.Context, arg store.CountJobsByStatusParams) ([]store.CountJobsByStatusRow, error)
+	listAttemptsFn   func(ctx context.Context, jobIds []uuid.UUID) ([]store.JobAttempt, error)
+	retryJobFn       func(ctx context.Context, arg store.RetryJobParams) (store.Job, error)
+	replayFn         func(ctx context.Context, arg store.ReplayFailedJobsParams) (int64, error)
+	cancelJobFn      func(ctx context.Context, arg store.CancelJobParams) (store.Job, error)
+	requestCancelFn  func(ctx context.Context, arg store.RequestJobCancelParams) (store.Job, error)
+	createScheduleFn func(ctx context.Context, arg store.CreateScheduleParams) (store.Schedule, error)
+	getScheduleFn    func(ctx context.Context, arg store.GetScheduleParams) (store.Schedule, error)
+	resumeScheduleFn func(ctx context.Context, arg store.ResumeScheduleParams) (store.Schedule, error)
+	reserveKeyFn     func(ctx context.Context, arg store.ReserveIdempotencyKeyParams) (store.IdempotencyKey, error)
+	getKeyFn         func(ctx context.Context, arg store.GetIdempotencyKeyParams) (store.IdempotencyKey, error)
+	completeKeyFn    func(ctx context.Context, arg store.CompleteIdempotencyKeyParams) error
+	releaseKeyFn     func(ctx context.Context, arg store.ReleaseIdempotencyKeyParams) error
+	upsertLimitFn    func(ctx context.Context, arg store.UpsertProviderRateLimitParams) (store.ProviderRateLimit, error)
+	listCircuitsFn   func(ctx context.Context) ([]store.ProviderCircuit, error)
+	createWebhookFn  func(ctx context.Context, arg store.CreateWebhookEndpointParams) (store.WebhookEndpoint, error)
+	getWebhookFn     func(ctx context.Context, arg store.GetWebhookEndpointParams) (store.WebhookEndpoint, error)
+	getDeliveryFn    func(ctx context.Context, arg store.GetWebhookDeliveryParams) (store.WebhookDelivery, error)
+	recordAttemptFn  func(ctx context.Context, arg store.RecordWebhookAttemptParams) (store.WebhookDelivery, error)
+	setJobOutputFn   func(ctx context.Context, arg store.SetJobOutputParams) error
+	createWorkflowFn func(ctx context.Context, arg store.CreateWorkflowParams) (store.Workflow, error)
+	getWorkflowFn    func(ctx context.Context, arg store.GetWorkflowParams) (store.Workflow, error)
+	listStepsFn      func(ctx context.Context, workflowID uuid.UUID) ([]store.WorkflowStep, error)
+	finishStepFn     func(ctx context.Context, jobID uuid.UUID) (store.WorkflowStep, error)
+	listPlainJobsFn  func(ctx context.Context, limit int32) ([]store.ListPlaintextJobPayloadsRow, error)
+	sealJobFn        func(ctx context.Context, arg store.SealJobPayloadParams) (int64, error)
+	setRetentionFn   func(ctx context.Context, arg store.SetTenantJobRetentionParams) (store.Tenant, error)
+	listWorkersFn    func(ctx context.Context) ([]store.Worker, error)
+	runningJobsFn    func(ctx context.Context) ([]store.ListRunningJobsRow, error)
+	queueStatsFn     func(ctx context.Context) ([]store.GetQueueStatsRow, error)
+	createBatchFn    func(ctx context.Context, arg store.CreateBatchParams) (store.Batch, error)
+	getBatchFn       func(ctx context.Context, arg store.GetBatchParams) (store.GetBatchRow, error)
+	createAPIKeyFn   func(ctx context.Context, arg store.CreateAPIKeyParams) (store.ApiKey, error)
+	listAPIKeysFn    func(ctx context.Context, tenantID uuid.UUID) ([]store.ApiKey, error)
+	getAPIKeyFn      func(ctx context.Context, arg store.GetAPIKeyParams) (store.ApiKey, error)
+	revokeAPIKeyFn   func(ctx context.Context, arg store.RevokeAPIKeyParams) (int64, error)
+	listTenantsFn    func(ctx context.Context, arg store.ListTenantsParams) ([]store.Tenant, error)
+	suspendTenantFn  func(ctx context.Context, id uuid.UUID) (store.Tenant, error)
+	reactivateFn     func(ctx context.Context, id uuid.UUID) (store.Tenant, error)
+	deleteTenantFn   func(ctx context.Context, id uuid.UUID) (int64, error)
+	getTemplateFn    func(ctx context.Context, arg store.GetEmailTemplateParams) (store.EmailTemplate, error)
 }
 
 func (s *stubQuerier) CreateJob(ctx context.Context, arg store.CreateJobParams) (store.Job, error) {
@@ -42,9 +92,60 @@ func (s *stubQuerier) GetJob(ctx context.Context, arg store.GetJobParams) (store
 	}
 	return store.Job{}, pgx.ErrNoRows
 }
-func (s *stubQuerier) ClaimNextJob(ctx context.Context) (store.Job, error) {
+func (s *stubQuerier) ClaimNextJob(ctx context.Context, arg store.ClaimNextJobParams) (store.Job, error) {
 	return store.Job{}, nil
 }
+func (s *stubQuerier) ExtendJobLease(ctx context.Context, arg store.ExtendJobLeaseParams) (bool, error) {
+	return false, nil
+}
+func (s *stubQuerier) ListJobs(ctx context.Context, arg store.ListJobsParams) ([]store.Job, error) {
+	if s.listJobsFn != nil {
+		return s.listJobsFn(ctx, arg)
+	}
+	return nil, nil
+}
+func (s *stubQuerier) CountJobsByStatus(ctx context.Context, arg store.CountJobsByStatusParams) ([]store.CountJobsByStatusRow, error) {
+	if s.countJobsFn != nil {
+		return s.countJobsFn(ctx, arg)
+	}
+	return nil, nil
+}
+func (s *stubQuerier) ListJobAttempts(ctx context.Context, jobIds []uuid.UUID) ([]store.JobAttempt, error) {
+	if s.listAttemptsFn != nil {
+		return s.listAttemptsFn(ctx, jobIds)
+	}
+	return nil, nil
+}
+func (s *stubQuerier) RetryJob(ctx context.Context, arg store.RetryJobParams) (store.Job, error) {
+	if s.retryJobFn != nil {
+		return s.retryJobFn(ctx, arg)
+	}
+	return store.Job{}, pgx.ErrNoRows
+}
+func (s *stubQuerier) ReplayFailedJobs(ctx context.Context, arg store.ReplayFailedJobsParams) (int64, error) {
+	if s.replayFn != nil {
+		return s.replayFn(ctx, arg)
+	}
+	return 0, nil
+}
+func (s *stubQuerier) CancelJob(ctx context.Context, arg store.CancelJobParams) (store.Job, error) {
+	if s.cancelJobFn != nil {
+		return s.cancelJobFn(ctx, arg)
+	}
+	return store.Job{}, pgx.ErrNoRows
+}
+func (s *stubQuerier) RequestJobCancel(ctx context.Context, arg store.RequestJobCancelParams) (store.Job, error) {
+	if s.requestCancelFn != nil {
+		return s.requestCancelFn(ctx, arg)
+	}
+	return store.Job{}, pgx.ErrNoRows
+}
+func (s *stubQuerier) ReapExpiredJobs(ctx context.Context) ([]store.Job, error) {
+	return nil, nil
+}
+func (s *stubQuerier) RequeueJob(ctx context.Context, arg store.RequeueJobParams) (int64, error) {
+	return 0, nil
+}
 func (s *stubQuerier) UpdateJobStatus(ctx context.Context, arg store.UpdateJobStatusParams) (store.Job, error) {
 	return store.Job{}, nil
 }
@@ -63,8 +164,8 @@ func (s *stubQuerier) GetOAuthToken(ctx context.Context, arg store.GetOAuthToken
 func (s *stubQuerier) GetProviderConfig(ctx context.Context, arg store.GetProviderConfigParams) (store.OauthProviderConfig, error) {
 	return store.OauthProviderConfig{}, nil
 }
-func (s *stubQuerier) GetTenantByAPIKeyHash(ctx context.Context, apiKeyHash string) (store.Tenant, error) {
-	return store.Tenant{}, nil
+func (s *stubQuerier) AuthenticateAPIKey(ctx context.Context, keyHash string) (store.ApiKey, error) {
+	return store.ApiKey{}, nil
 }
 func (s *stubQuerier) GetTenantByID(ctx context.Context, id uuid.UUID) (store.Tenant, error) {
 	if s.getTenantByIDFn != nil {
@@ -85,6 +186,9 @@ func (s *stubQuerier) DeleteEmailTemplate(ctx context.Context, arg store.DeleteE
 	return nil
 }
 func (s *stubQuerier) GetEmailTemplate(ctx context.Context, arg store.GetEmailTemplateParams) (store.EmailTemplate, error) {
+	if s.getTemplateFn != nil {
+		return s.getTemplateFn(ctx, arg)
+	}
 	return store.EmailTemplate{}, nil
 }
 func (s *stubQuerier) ListEmailTemplates(ctx context.Context, tenantID uuid.UUID) ([]store.EmailTemplate, error) {
@@ -102,13 +206,332 @@ func (s *stubQuerier) UpsertCodeProviderConfig(ctx context.Context, arg store.Up
 func (s *stubQuerier) InsertCodeExecution(ctx context.Context, arg store.InsertCodeExecutionParams) (store.CodeExecution, error) {
 	return store.CodeExecution{}, nil
 }
+func (s *stubQuerier) CreateSchedule(ctx context.Context, arg store.CreateScheduleParams) (store.Schedule, error) {
+	if s.createScheduleFn != nil {
+		return s.createScheduleFn(ctx, arg)
+	}
+	return store.Schedule{}, nil
+}
+func (s *stubQuerier) GetSchedule(ctx context.Context, arg store.GetScheduleParams) (store.Schedule, error) {
+	if s.getScheduleFn != nil {
+		return s.getScheduleFn(ctx, arg)
+	}
+	return store.Schedule{}, pgx.ErrNoRows
+}
+func (s *stubQuerier) ListSchedules(ctx context.Context, tenantID uuid.UUID) ([]store.Schedule, error) {
+	return nil, nil
+}
+func (s *stubQuerier) UpdateSchedule(ctx context.Context, arg store.UpdateScheduleParams) (store.Schedule, error) {
+	return store.Schedule{}, nil
+}
+func (s *stubQuerier) PauseSchedule(ctx context.Context, arg store.PauseScheduleParams) (store.Schedule, error) {
+	return store.Schedule{}, nil
+}
+func (s *stubQuerier) ResumeSchedule(ctx context.Context, arg store.ResumeScheduleParams) (store.Schedule, error) {
+	if s.resumeScheduleFn != nil {
+		return s.resumeScheduleFn(ctx, arg)
+	}
+	return store.Schedule{}, nil
+}
+func (s *stubQuerier) DeleteSchedule(ctx context.Context, arg store.DeleteScheduleParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) ListDueSchedules(ctx context.Context, limit int32) ([]store.Schedule, error) {
+	return nil, nil
+}
+func (s *stubQuerier) FireSchedule(ctx context.Context, arg store.FireScheduleParams) (store.Job, error) {
+	return store.Job{}, nil
+}
 func (s *stubQuerier) GetCodeExecution(ctx context.Context, arg store.GetCodeExecutionParams) (store.CodeExecution, error) {
 	return store.CodeExecution{}, nil
 }
+func (s *stubQuerier) ReserveIdempotencyKey(ctx context.Context, arg store.ReserveIdempotencyKeyParams) (store.IdempotencyKey, error) {
+	if s.reserveKeyFn != nil {
+		return s.reserveKeyFn(ctx, arg)
+	}
+	return store.IdempotencyKey{}, nil
+}
+func (s *stubQuerier) GetIdempotencyKey(ctx context.Context, arg store.GetIdempotencyKeyParams) (store.IdempotencyKey, error) {
+	if s.getKeyFn != nil {
+		return s.getKeyFn(ctx, arg)
+	}
+	return store.IdempotencyKey{}, pgx.ErrNoRows
+}
+func (s *stubQuerier) CompleteIdempotencyKey(ctx context.Context, arg store.CompleteIdempotencyKeyParams) error {
+	if s.completeKeyFn != nil {
+		return s.completeKeyFn(ctx, arg)
+	}
+	return nil
+}
+func (s *stubQuerier) ReleaseIdempotencyKey(ctx context.Context, arg store.ReleaseIdempotencyKeyParams) error {
+	if s.releaseKeyFn != nil {
+		return s.releaseKeyFn(ctx, arg)
+	}
+	return nil
+}
+
+func (s *stubQuerier) UpsertProviderRateLimit(ctx context.Context, arg store.UpsertProviderRateLimitParams) (store.ProviderRateLimit, error) {
+	if s.upsertLimitFn != nil {
+		return s.upsertLimitFn(ctx, arg)
+	}
+	return store.ProviderRateLimit{}, nil
+}
+func (s *stubQuerier) ListProviderRateLimits(ctx context.Context, tenantID uuid.UUID) ([]store.ProviderRateLimit, error) {
+	return nil, nil
+}
+func (s *stubQuerier) DeleteProviderRateLimit(ctx context.Context, arg store.DeleteProviderRateLimitParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) RecordProviderFailure(ctx context.Context, arg store.RecordProviderFailureParams) (store.ProviderCircuit, error) {
+	return store.ProviderCircuit{}, nil
+}
+func (s *stubQuerier) RecordProviderSuccess(ctx context.Context, arg store.RecordProviderSuccessParams) error {
+	return nil
+}
+func (s *stubQuerier) ListProviderCircuits(ctx context.Context) ([]store.ProviderCircuit, error) {
+	if s.listCircuitsFn != nil {
+		return s.listCircuitsFn(ctx)
+	}
+	return nil, nil
+}
+func (s *stubQuerier) ResetProviderCircuit(ctx context.Context, arg store.ResetProviderCircuitParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) CreateWebhookEndpoint(ctx context.Context, arg store.CreateWebhookEndpointParams) (store.WebhookEndpoint, error) {
+	if s.createWebhookFn != nil {
+		return s.createWebhookFn(ctx, arg)
+	}
+	return store.WebhookEndpoint{}, nil
+}
+func (s *stubQuerier) GetWebhookEndpoint(ctx context.Context, arg store.GetWebhookEndpointParams) (store.WebhookEndpoint, error) {
+	if s.getWebhookFn != nil {
+		return s.getWebhookFn(ctx, arg)
+	}
+	return store.WebhookEndpoint{}, pgx.ErrNoRows
+}
+func (s *stubQuerier) ListWebhookEndpoints(ctx context.Context, tenantID uuid.UUID) ([]store.WebhookEndpoint, error) {
+	return nil, nil
+}
+func (s *stubQuerier) DeleteWebhookEndpoint(ctx context.Context, arg store.DeleteWebhookEndpointParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) EnqueueWebhookDeliveries(ctx context.Context, arg store.EnqueueWebhookDeliveriesParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) GetWebhookDelivery(ctx context.Context, arg store.GetWebhookDeliveryParams) (store.WebhookDelivery, error) {
+	if s.getDeliveryFn != nil {
+		return s.getDeliveryFn(ctx, arg)
+	}
+	return store.WebhookDelivery{}, pgx.ErrNoRows
+}
+func (s *stubQuerier) RecordWebhookAttempt(ctx context.Context, arg store.RecordWebhookAttemptParams) (store.WebhookDelivery, error) {
+	if s.recordAttemptFn != nil {
+		return s.recordAttemptFn(ctx, arg)
+	}
+	return store.WebhookDelivery{}, nil
+}
+func (s *stubQuerier) ListWebhookDeliveries(ctx context.Context, arg store.ListWebhookDeliveriesParams) ([]store.WebhookDelivery, error) {
+	return nil, nil
+}
+func (s *stubQuerier) SetJobOutput(ctx context.Context, arg store.SetJobOutputParams) error {
+	if s.setJobOutputFn != nil {
+		return s.setJobOutputFn(ctx, arg)
+	}
+	return nil
+}
+func (s *stubQuerier) CreateWorkflow(ctx context.Context, arg store.CreateWorkflowParams) (store.Workflow, error) {
+	if s.createWorkflowFn != nil {
+		return s.createWorkflowFn(ctx, arg)
+	}
+	return store.Workflow{}, nil
+}
+func (s *stubQuerier) GetWorkflow(ctx context.Context, arg store.GetWorkflowParams) (store.Workflow, error) {
+	if s.getWorkflowFn != nil {
+		return s.getWorkflowFn(ctx, arg)
+	}
+	return store.Workflow{}, pgx.ErrNoRows
+}
+func (s *stubQuerier) ListWorkflows(ctx context.Context, arg store.ListWorkflowsParams) ([]store.Workflow, error) {
+	return nil, nil
+}
+func (s *stubQuerier) ListWorkflowSteps(ctx context.Context, workflowID uuid.UUID) ([]store.WorkflowStep, error) {
+	if s.listStepsFn != nil {
+		return s.listStepsFn(ctx, workflowID)
+	}
+	return nil, nil
+}
+func (s *stubQuerier) QueueWorkflowStep(ctx context.Context, arg store.QueueWorkflowStepParams) (store.Job, error) {
+	return store.Job{}, nil
+}
+func (s *stubQuerier) SkipWorkflowStep(ctx context.Context, arg store.SkipWorkflowStepParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) LockJobWorkflow(ctx context.Context, jobID uuid.UUID) (uuid.UUID, error) {
+	return uuid.Nil, nil
+}
+func (s *stubQuerier) ListStalledWorkflowSteps(ctx context.Context, limit int32) ([]store.ListStalledWorkflowStepsRow, error) {
+	return nil, nil
+}
+func (s *stubQuerier) FinishWorkflowStep(ctx context.Context, jobID uuid.UUID) (store.WorkflowStep, error) {
+	if s.finishStepFn != nil {
+		return s.finishStepFn(ctx, jobID)
+	}
+	return store.WorkflowStep{}, pgx.ErrNoRows
+}
+func (s *stubQuerier) FinishWorkflow(ctx context.Context, arg store.FinishWorkflowParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) ListPlaintextJobPayloads(ctx context.Context, limit int32) ([]store.ListPlaintextJobPayloadsRow, error) {
+	if s.listPlainJobsFn != nil {
+		return s.listPlainJobsFn(ctx, limit)
+	}
+	return nil, nil
+}
+func (s *stubQuerier) SealJobPayload(ctx context.Context, arg store.SealJobPayloadParams) (int64, error) {
+	if s.sealJobFn != nil {
+		return s.sealJobFn(ctx, arg)
+	}
+	return 0, nil
+}
+func (s *stubQuerier) ListPlaintextSchedulePayloads(ctx context.Context, limit int32) ([]store.ListPlaintextSchedulePayloadsRow, error) {
+	return nil, nil
+}
+func (s *stubQuerier) SealSchedulePayload(ctx context.Context, arg store.SealSchedulePayloadParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) ListPlaintextWorkflowStepPayloads(ctx context.Context, limit int32) ([]store.ListPlaintextWorkflowStepPayloadsRow, error) {
+	return nil, nil
+}
+func (s *stubQuerier) SealWorkflowStepPayload(ctx context.Context, arg store.SealWorkflowStepPayloadParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) PurgeFinishedJobs(ctx context.Context, arg store.PurgeFinishedJobsParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) PurgeFinishedWorkflows(ctx context.Context, arg store.PurgeFinishedWorkflowsParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) SetTenantJobRetention(ctx context.Context, arg store.SetTenantJobRetentionParams) (store.Tenant, error) {
+	if s.setRetentionFn != nil {
+		return s.setRetentionFn(ctx, arg)
+	}
+	return store.Tenant{}, nil
+}
+func (s *stubQuerier) RegisterWorker(ctx context.Context, arg store.RegisterWorkerParams) (store.Worker, error) {
+	return store.Worker{}, nil
+}
+func (s *stubQuerier) HeartbeatWorker(ctx context.Context, id string) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) StopWorker(ctx context.Context, id string) error {
+	return nil
+}
+func (s *stubQuerier) DeleteStaleWorkers(ctx context.Context, olderThanSeconds int32) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) ListWorkers(ctx context.Context) ([]store.Worker, error) {
+	if s.listWorkersFn != nil {
+		return s.listWorkersFn(ctx)
+	}
+	return nil, nil
+}
+func (s *stubQuerier) ListRunningJobs(ctx context.Context) ([]store.ListRunningJobsRow, error) {
+	if s.runningJobsFn != nil {
+		return s.runningJobsFn(ctx)
+	}
+	return nil, nil
+}
+func (s *stubQuerier) GetQueueStats(ctx context.Context) ([]store.GetQueueStatsRow, error) {
+	if s.queueStatsFn != nil {
+		return s.queueStatsFn(ctx)
+	}
+	return nil, nil
+}
+func (s *stubQuerier) CreateBatch(ctx context.Context, arg store.CreateBatchParams) (store.Batch, error) {
+	if s.createBatchFn != nil {
+		return s.createBatchFn(ctx, arg)
+	}
+	return store.Batch{}, nil
+}
+func (s *stubQuerier) GetBatch(ctx context.Context, arg store.GetBatchParams) (store.GetBatchRow, error) {
+	if s.getBatchFn != nil {
+		return s.getBatchFn(ctx, arg)
+	}
+	return store.GetBatchRow{}, pgx.ErrNoRows
+}
+func (s *stubQuerier) CreateAPIKey(ctx context.Context, arg store.CreateAPIKeyParams) (store.ApiKey, error) {
+	if s.createAPIKeyFn != nil {
+		return s.createAPIKeyFn(ctx, arg)
+	}
+	return store.ApiKey{}, nil
+}
+func (s *stubQuerier) ListAPIKeys(ctx context.Context, tenantID uuid.UUID) ([]store.ApiKey, error) {
+	if s.listAPIKeysFn != nil {
+		return s.listAPIKeysFn(ctx, tenantID)
+	}
+	return nil, nil
+}
+func (s *stubQuerier) GetAPIKey(ctx context.Context, arg store.GetAPIKeyParams) (store.ApiKey, error) {
+	if s.getAPIKeyFn != nil {
+		return s.getAPIKeyFn(ctx, arg)
+	}
+	return store.ApiKey{}, pgx.ErrNoRows
+}
+func (s *stubQuerier) RevokeAPIKey(ctx context.Context, arg store.RevokeAPIKeyParams) (int64, error) {
+	if s.revokeAPIKeyFn != nil {
+		return s.revokeAPIKeyFn(ctx, arg)
+	}
+	return 0, nil
+}
+func (s *stubQuerier) DeleteExpiredIdempotencyKeys(ctx context.Context, batchSize int32) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) ListTenants(ctx context.Context, arg store.ListTenantsParams) ([]store.Tenant, error) {
+	if s.listTenantsFn != nil {
+		return s.listTenantsFn(ctx, arg)
+	}
+	return nil, nil
+}
+func (s *stubQuerier) SuspendTenant(ctx context.Context, id uuid.UUID) (store.Tenant, error) {
+	if s.suspendTenantFn != nil {
+		return s.suspendTenantFn(ctx, id)
+	}
+	return store.Tenant{}, nil
+}
+func (s *stubQuerier) ReactivateTenant(ctx context.Context, id uuid.UUID) (store.Tenant, error) {
+	if s.reactivateFn != nil {
+		return s.reactivateFn(ctx, id)
+	}
+	return store.Tenant{}, nil
+}
+func (s *stubQuerier) DeleteTenant(ctx context.Context, id uuid.UUID) (int64, error) {
+	if s.deleteTenantFn != nil {
+		return s.deleteTenantFn(ctx, id)
+	}
+	return 0, nil
+}
 
 // Compile-time interface check.
 var _ store.Querier = (*stubQuerier)(nil)
 
+// testEnc and testEncDataKey encrypt the payloads of the tenant ginCtx sets;
+// handlers that queue jobs need tenantSvc: testTenants.
+var (
+	testEnc, _           = crypto.NewEncryptor(strings.Repeat("ab", 32))
+	testTenants          = tenant.NewService(nil, testEnc)
+	_, testEncDataKey, _ = testEnc.GenerateDataKey()
+)
+
+// openTestPayload opens a payload sealed for a ginCtx tenant.
+func openTestPayload(t *testing.T, stored []byte) []byte {
+	t.Helper()
+	plaintext, err := (&Handler{tenantSvc: testTenants}).openPayload(&store.Tenant{EncryptedDataKey: testEncDataKey}, stored)
+	if err != nil {
+		t.Fatalf("open payload: %v", err)
+	}
+	return plaintext
+}
+
 // ginCtx builds a Gin test context with an authenticated tenant already set.
 func ginCtx(method, path string, body []byte, tenantID uuid.UUID, params gin.Params) (*gin.Context, *httptest.ResponseRecorder) {
 	w := httptest.NewRecorder()
@@ -117,7 +540,8 @@ func ginCtx(method, path string, body []byte, tenantID uuid.UUID, params gin.Par
 	req.Header.Set("Content-Type", "application/json")
 	c.Request = req
 	c.Params = params
-	c.Set("tenant", &store.Tenant{ID: tenantID})
+	c.Set("tenant", &store.Tenant{ID: tenantID, EncryptedDataKey: testEncDataKey})
+	c.Set("api_key", &store.ApiKey{TenantID: tenantID, Scopes: []string{tenant.ScopeAll}})
 	return c, w
 }
 
@@ -134,7 +558,7 @@ func TestSendEmail_Async_CreatesJobAndReturns202(t *testing.T) {
 			return createdJob, nil
 		},
 	}
-	h := &Handler{queries: q}
+	h := &Handler{queries: q, tenantSvc: testTenants}
 
 	body, _ := json.Marshal(map[string]interface{}{
 		"to": []string{"bob@example.com"}, "from": "alice@example.com",
@@ -168,7 +592,7 @@ func TestSendEmail_Async_StoreError_Returns500(t *testing.T) {
 			return store.Job{}, pgx.ErrTxClosed
 		},
 	}
-	h := &Handler{queries: q}
+	h := &Handler{queries: q, tenantSvc: testTenants}
 
 	body, _ := json.Marshal(map[string]interface{}{
 		"to": []string{"a@b.com"}, "from": "x@y.com", "subject": "s", "body": "b",
@@ -201,7 +625,7 @@ func TestSendEmail_PayloadContainsProvider(t *testing.T) {
 			return store.Job{ID: uuid.New()}, nil
 		},
 	}
-	h := &Handler{queries: q}
+	h := &Handler{queries: q, tenantSvc: testTenants}
 
 	body, _ := json.Marshal(map[string]interface{}{
 		"to": []string{"a@b.com"}, "from": "x@y.com", "subject": "s", "body": "b",
@@ -213,92 +637,512 @@ func TestSendEmail_PayloadContainsProvider(t *testing.T) {
 		t.Fatalf("expected 202, got %d", w.Code)
 	}
 	var payload map[string]interface{}
-	json.Unmarshal(gotParams.Payload, &payload)
+	json.Unmarshal(openTestPayload(t, gotParams.Payload), &payload)
 	if payload["provider"] != "sendgrid" {
 		t.Errorf("expected provider=sendgrid in payload, got %v", payload["provider"])
 	}
+	if gotParams.Provider != "sendgrid" {
+		t.Errorf("expected job provider=sendgrid for rate limiting, got %q", gotParams.Provider)
+	}
 }
 
-// --- SendSMS tests ---
-
-func TestSendSMS_Async_CreatesJobAndReturns202(t *testing.T) {
-	tenantID := uuid.New()
+func TestSendEmail_SendAt_SchedulesJob(t *testing.T) {
 	var gotParams store.CreateJobParams
 	q := &stubQuerier{
 		createJobFn: func(_ context.Context, arg store.CreateJobParams) (store.Job, error) {
 			gotParams = arg
-			return store.Job{ID: uuid.New(), Status: "pending"}, nil
+			return store.Job{ID: uuid.New(), RunAt: *arg.RunAt}, nil
 		},
 	}
-	h := &Handler{queries: q}
+	h := &Handler{queries: q, tenantSvc: testTenants}
 
-	body, _ := json.Marshal(map[string]string{"from": "+15550001111", "to": "+15559998888", "body": "Hello"})
-	c, w := ginCtx("POST", "/sms/twilio/send", body, tenantID, gin.Params{{Key: "provider", Value: "twilio"}})
-	h.SendSMS(c)
+	sendAt := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	body, _ := json.Marshal(map[string]interface{}{
+		"to": []string{"a@b.com"}, "from": "x@y.com", "subject": "s", "body": "b",
+		"send_at": sendAt.Format(time.RFC3339),
+	})
+	c, w := ginCtx("POST", "/email/smtp/send", body, uuid.New(), gin.Params{{Key: "provider", Value: "smtp"}})
+	h.SendEmail(c)
 
 	if w.Code != http.StatusAccepted {
-		t.Errorf("expected 202, got %d: %s", w.Code, w.Body.String())
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
 	}
-	if gotParams.JobType != "sms.send" {
-		t.Errorf("expected job_type=sms.send, got %s", gotParams.JobType)
+	if gotParams.RunAt == nil || !gotParams.RunAt.Equal(sendAt) {
+		t.Errorf("expected run_at=%s, got %v", sendAt, gotParams.RunAt)
+	}
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["run_at"] == nil {
+		t.Error("expected run_at in response for a scheduled job")
 	}
 }
 
-// --- GetJob tests ---
+func TestSendEmail_SendAt_BeyondHorizon_Returns400(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{}}
+	body, _ := json.Marshal(map[string]interface{}{
+		"to": []string{"a@b.com"}, "from": "x@y.com", "subject": "s", "body": "b",
+		"send_at": time.Now().Add(31 * 24 * time.Hour).Format(time.RFC3339),
+	})
+	c, w := ginCtx("POST", "/email/smtp/send", body, uuid.New(), gin.Params{{Key: "provider", Value: "smtp"}})
+	h.SendEmail(c)
 
-func TestGetJob_Found_Returns200(t *testing.T) {
-	tenantID := uuid.New()
-	jobID := uuid.New()
-	now := time.Now()
-	job := store.Job{
-		ID:        jobID,
-		TenantID:  tenantID,
-		JobType:   "email.send",
-		Status:    "completed",
-		Error:     pgtype.Text{Valid: false},
-		RunAt:     now,
-		CreatedAt: now,
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for send_at beyond the scheduling horizon, got %d", w.Code)
+	}
+}
+
+func TestSendEmail_SendAtWithSync_Returns400(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{}}
+	body, _ := json.Marshal(map[string]interface{}{
+		"to": []string{"a@b.com"}, "from": "x@y.com", "subject": "s", "body": "b",
+		"send_at": time.Now().Add(time.Hour).Format(time.RFC3339),
+	})
+	c, w := ginCtx("POST", "/email/smtp/send?sync=true", body, uuid.New(), gin.Params{{Key: "provider", Value: "smtp"}})
+	h.SendEmail(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for send_at combined with sync=true, got %d", w.Code)
 	}
+}
 
+func TestSendEmailWithTemplate_Async_CreatesJob(t *testing.T) {
+	var gotParams store.CreateJobParams
 	q := &stubQuerier{
-		getJobFn: func(_ context.Context, arg store.GetJobParams) (store.Job, error) {
-			if arg.ID != jobID || arg.TenantID != tenantID {
-				t.Errorf("unexpected GetJob params: %+v", arg)
-			}
-			return job, nil
+		createJobFn: func(_ context.Context, arg store.CreateJobParams) (store.Job, error) {
+			gotParams = arg
+			return store.Job{ID: uuid.New()}, nil
 		},
 	}
-	h := &Handler{queries: q}
+	h := &Handler{queries: q, tenantSvc: testTenants}
 
-	c, w := ginCtx("GET", "/jobs/"+jobID.String(), nil, tenantID,
-		gin.Params{{Key: "id", Value: jobID.String()}})
-	h.GetJob(c)
+	body, _ := json.Marshal(map[string]interface{}{
+		"template": "welcome", "to": []string{"a@b.com"}, "from": "x@y.com",
+		"variables": map[string]string{"UserName": "Ann"},
+	})
+	c, w := ginCtx("POST", "/email/smtp/send-template?async=true", body, uuid.New(), gin.Params{{Key: "provider", Value: "smtp"}})
+	h.SendEmailWithTemplate(c)
 
-	if w.Code != http.StatusOK {
-		t.Errorf("expected 200, got %d: %s", w.Code, w.Body.String())
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
 	}
-	var resp map[string]interface{}
-	json.Unmarshal(w.Body.Bytes(), &resp)
-	if resp["status"] != "completed" {
-		t.Errorf("expected status=completed, got %v", resp["status"])
+	if gotParams.JobType != "email.send_template" {
+		t.Errorf("expected job_type=email.send_template, got %s", gotParams.JobType)
+	}
+	var payload email.TemplateJobPayload
+	json.Unmarshal(openTestPayload(t, gotParams.Payload), &payload)
+	if payload.Template != "welcome" || payload.Provider != "smtp" {
+		t.Errorf("unexpected payload: %+v", payload)
 	}
 }
 
-func TestGetJob_NotFound_Returns404(t *testing.T) {
-	h := &Handler{queries: &stubQuerier{}} // getJobFn is nil → returns pgx.ErrNoRows
+// --- SendSMS tests ---
 
-	jobID := uuid.New()
-	c, w := ginCtx("GET", "/jobs/"+jobID.String(), nil, uuid.New(),
-		gin.Params{{Key: "id", Value: jobID.String()}})
-	h.GetJob(c)
+func TestSendEmailWithTemplate_SendsInlineByDefault(t *testing.T) {
+	q := &stubQuerier{
+		createJobFn: func(_ context.Context, arg store.CreateJobParams) (store.Job, error) {
+			t.Error("CreateJob should not be called without async=true or job options")
+			return store.Job{}, nil
+		},
+	}
+	h := &Handler{queries: q, tenantSvc: testTenants}
 
-	if w.Code != http.StatusNotFound {
-		t.Errorf("expected 404, got %d", w.Code)
+	body, _ := json.Marshal(map[string]interface{}{
+		"template": "welcome", "to": []string{"a@b.com"}, "from": "x@y.com",
+		"variables": map[string]string{"UserName": "Ann"},
+	})
+	c, w := ginCtx("POST", "/email/smtp/send-template", body, uuid.New(), gin.Params{{Key: "provider", Value: "smtp"}})
+	h.SendEmailWithTemplate(c)
+
+	// The stub has no provider config, so the inline send fails to build one.
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected the inline send to fail with 400, got %d: %s", w.Code, w.Body.String())
 	}
 }
 
-func TestGetJob_InvalidUUID_Returns400(t *testing.T) {
-	h := &Handler{queries: &stubQuerier{}}
+func TestSendSMS_Async_CreatesJobAndReturns202(t *testing.T) {
+	tenantID := uuid.New()
+	var gotParams store.CreateJobParams
+	q := &stubQuerier{
+		createJobFn: func(_ context.Context, arg store.CreateJobParams) (store.Job, error) {
+			gotParams = arg
+			return store.Job{ID: uuid.New(), Status: "pending"}, nil
+		},
+	}
+	h := &Handler{queries: q, tenantSvc: testTenants}
+
+	body, _ := json.Marshal(map[string]string{"from": "+15550001111", "to": "+15559998888", "body": "Hello"})
+	c, w := ginCtx("POST", "/sms/twilio/send", body, tenantID, gin.Params{{Key: "provider", Value: "twilio"}})
+	h.SendSMS(c)
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if gotParams.JobType != "sms.send" {
+		t.Errorf("expected job_type=sms.send, got %s", gotParams.JobType)
+	}
+}
+
+func TestSendSMS_RetryOverrides_StoredOnJob(t *testing.T) {
+	var gotParams store.CreateJobParams
+	q := &stubQuerier{
+		createJobFn: func(_ context.Context, arg store.CreateJobParams) (store.Job, error) {
+			gotParams = arg
+			return store.Job{ID: uuid.New()}, nil
+		},
+	}
+	h := &Handler{queries: q, tenantSvc: testTenants}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"from": "+15550001111", "to": "+15559998888", "body": "Your code is 123456",
+		"retry": map[string]interface{}{"max_attempts": 1, "jitter": 0},
+	})
+	c, w := ginCtx("POST", "/sms/twilio/send", body, uuid.New(), gin.Params{{Key: "provider", Value: "twilio"}})
+	h.SendSMS(c)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	// Overridden fields are applied; the rest keep the sms.send defaults.
+	def := retryDefaults["sms.send"]
+	if gotParams.MaxAttempts != 1 || gotParams.BackoffJitter != 0 ||
+		gotParams.BackoffBaseSeconds != int32(def.BaseDelay/time.Second) ||
+		gotParams.BackoffMaxSeconds != int32(def.MaxDelay/time.Second) {
+		t.Errorf("unexpected retry policy: %+v", gotParams)
+	}
+}
+
+func TestSendEmail_DefaultRetryPolicy(t *testing.T) {
+	var gotParams store.CreateJobParams
+	q := &stubQuerier{
+		createJobFn: func(_ context.Context, arg store.CreateJobParams) (store.Job, error) {
+			gotParams = arg
+			return store.Job{ID: uuid.New()}, nil
+		},
+	}
+	h := &Handler{queries: q, tenantSvc: testTenants}
+
+	body, _ := json.Marshal(map[string]interface{}{"to": []string{"a@b.com"}, "from": "x@y.com", "subject": "s", "body": "b"})
+	c, w := ginCtx("POST", "/email/smtp/send", body, uuid.New(), gin.Params{{Key: "provider", Value: "smtp"}})
+	h.SendEmail(c)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if gotParams.MaxAttempts != retryDefaults["email.send"].MaxAttempts {
+		t.Errorf("expected email.send default max_attempts, got %d", gotParams.MaxAttempts)
+	}
+}
+
+func TestSendEmail_InvalidRetry_Returns400(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		retry map[string]interface{}
+		query string
+	}{
+		{"zero attempts", map[string]interface{}{"max_attempts": 0}, ""},
+		{"jitter above 1", map[string]interface{}{"jitter": 1.5}, ""},
+		{"max below base", map[string]interface{}{"base_delay_seconds": 60, "max_delay_seconds": 10}, ""},
+		{"with sync", map[string]interface{}{"max_attempts": 2}, "?sync=true"},
+	} {
+		h := &Handler{queries: &stubQuerier{}}
+		body, _ := json.Marshal(map[string]interface{}{
+			"to": []string{"a@b.com"}, "from": "x@y.com", "subject": "s", "body": "b", "retry": tc.retry,
+		})
+		c, w := ginCtx("POST", "/email/smtp/send"+tc.query, body, uuid.New(), gin.Params{{Key: "provider", Value: "smtp"}})
+		h.SendEmail(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", tc.name, w.Code)
+		}
+	}
+}
+
+func TestSendEmailWithTemplate_QueueAndPriority(t *testing.T) {
+	var gotParams store.CreateJobParams
+	q := &stubQuerier{
+		createJobFn: func(_ context.Context, arg store.CreateJobParams) (store.Job, error) {
+			gotParams = arg
+			return store.Job{ID: uuid.New()}, nil
+		},
+	}
+	h := &Handler{queries: q, tenantSvc: testTenants}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"template": "password_reset", "to": []string{"a@b.com"}, "from": "x@y.com",
+		"variables": map[string]string{"ResetURL": "https://x"},
+		"queue":     "transactional", "priority": 10,
+	})
+	c, w := ginCtx("POST", "/email/smtp/send-template", body, uuid.New(), gin.Params{{Key: "provider", Value: "smtp"}})
+	h.SendEmailWithTemplate(c)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if gotParams.Queue != "transactional" || gotParams.Priority != 10 {
+		t.Errorf("expected queue=transactional priority=10, got %q %d", gotParams.Queue, gotParams.Priority)
+	}
+}
+
+func TestSendSMS_DefaultQueue(t *testing.T) {
+	var gotParams store.CreateJobParams
+	q := &stubQuerier{
+		createJobFn: func(_ context.Context, arg store.CreateJobParams) (store.Job, error) {
+			gotParams = arg
+			return store.Job{ID: uuid.New()}, nil
+		},
+	}
+	h := &Handler{queries: q, tenantSvc: testTenants}
+
+	body, _ := json.Marshal(map[string]string{"from": "+1", "to": "+2", "body": "hi"})
+	c, _ := ginCtx("POST", "/sms/twilio/send", body, uuid.New(), gin.Params{{Key: "provider", Value: "twilio"}})
+	h.SendSMS(c)
+
+	if gotParams.Queue != "default" || gotParams.Priority != 0 {
+		t.Errorf("expected default queue and priority 0, got %q %d", gotParams.Queue, gotParams.Priority)
+	}
+}
+
+func TestSendEmail_InvalidQueueOrPriority_Returns400(t *testing.T) {
+	for _, extra := range []map[string]interface{}{
+		{"queue": "Bulk Mail"},
+		{"priority": 101},
+		{"priority": -1},
+	} {
+		h := &Handler{queries: &stubQuerier{}}
+		req := map[string]interface{}{"to": []string{"a@b.com"}, "from": "x@y.com", "subject": "s", "body": "b"}
+		for k, v := range extra {
+			req[k] = v
+		}
+		body, _ := json.Marshal(req)
+		c, w := ginCtx("POST", "/email/smtp/send", body, uuid.New(), gin.Params{{Key: "provider", Value: "smtp"}})
+		h.SendEmail(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%v: expected 400, got %d", extra, w.Code)
+		}
+	}
+}
+
+// --- Idempotency tests ---
+
+// withIdempotencyStore backs q's idempotency key queries with an in-memory map.
+func withIdempotencyStore(q *stubQuerier) map[string]*store.IdempotencyKey {
+	keys := map[string]*store.IdempotencyKey{}
+	q.reserveKeyFn = func(_ context.Context, arg store.ReserveIdempotencyKeyParams) (store.IdempotencyKey, error) {
+		if _, ok := keys[arg.Key]; ok {
+			return store.IdempotencyKey{}, pgx.ErrNoRows
+		}
+		keys[arg.Key] = &store.IdempotencyKey{TenantID: arg.TenantID, Key: arg.Key, RequestHash: arg.RequestHash}
+		return *keys[arg.Key], nil
+	}
+	q.getKeyFn = func(_ context.Context, arg store.GetIdempotencyKeyParams) (store.IdempotencyKey, error) {
+		if k, ok := keys[arg.Key]; ok {
+			return *k, nil
+		}
+		return store.IdempotencyKey{}, pgx.ErrNoRows
+	}
+	q.completeKeyFn = func(_ context.Context, arg store.CompleteIdempotencyKeyParams) error {
+		keys[arg.Key].StatusCode, keys[arg.Key].Response = arg.StatusCode, arg.Response
+		return nil
+	}
+	q.releaseKeyFn = func(_ context.Context, arg store.ReleaseIdempotencyKeyParams) error {
+		delete(keys, arg.Key)
+		return nil
+	}
+	return keys
+}
+
+// idempotentRouter serves POST /email/:provider/send behind the Idempotent middleware.
+func idempotentRouter(h *Handler, tenantID uuid.UUID) *gin.Engine {
+	r := gin.New()
+	r.POST("/email/:provider/send", func(c *gin.Context) {
+		c.Set("tenant", &store.Tenant{ID: tenantID, EncryptedDataKey: testEncDataKey})
+	}, h.Idempotent(), h.SendEmail)
+	return r
+}
+
+func postWithKey(r *gin.Engine, key string, body []byte) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/email/smtp/send", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotent_ReplaysFirstResponse(t *testing.T) {
+	var created int
+	q := &stubQuerier{
+		createJobFn: func(_ context.Context, arg store.CreateJobParams) (store.Job, error) {
+			created++
+			return store.Job{ID: uuid.New()}, nil
+		},
+	}
+	withIdempotencyStore(q)
+	r := idempotentRouter(&Handler{queries: q, tenantSvc: testTenants}, uuid.New())
+
+	body, _ := json.Marshal(map[string]interface{}{"to": []string{"a@b.com"}, "from": "x@y.com", "subject": "s", "body": "b"})
+	first := postWithKey(r, "order-42", body)
+	second := postWithKey(r, "order-42", body)
+
+	if first.Code != http.StatusAccepted || second.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 twice, got %d and %d", first.Code, second.Code)
+	}
+	if created != 1 {
+		t.Errorf("expected 1 job to be created, got %d", created)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("expected replayed response %s, got %s", first.Body.String(), second.Body.String())
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("expected Idempotent-Replayed header on the replay")
+	}
+}
+
+func TestIdempotent_DifferentBody_Returns422(t *testing.T) {
+	q := &stubQuerier{
+		createJobFn: func(_ context.Context, arg store.CreateJobParams) (store.Job, error) {
+			return store.Job{ID: uuid.New()}, nil
+		},
+	}
+	withIdempotencyStore(q)
+	r := idempotentRouter(&Handler{queries: q, tenantSvc: testTenants}, uuid.New())
+
+	a, _ := json.Marshal(map[string]interface{}{"to": []string{"a@b.com"}, "from": "x@y.com", "subject": "s", "body": "b"})
+	b, _ := json.Marshal(map[string]interface{}{"to": []string{"c@d.com"}, "from": "x@y.com", "subject": "s", "body": "b"})
+	postWithKey(r, "k1", a)
+	w := postWithKey(r, "k1", b)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestIdempotent_FailedRequestReleasesKey(t *testing.T) {
+	q := &stubQuerier{}
+	keys := withIdempotencyStore(q)
+	r := idempotentRouter(&Handler{queries: q}, uuid.New())
+
+	w := postWithKey(r, "k1", []byte(`{"to": []}`))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	if _, ok := keys["k1"]; ok {
+		t.Error("expected the key to be released after a failed request")
+	}
+}
+
+func TestIdempotent_InProgress_Returns409(t *testing.T) {
+	q := &stubQuerier{}
+	keys := withIdempotencyStore(q)
+	r := idempotentRouter(&Handler{queries: q}, uuid.New())
+
+	body := []byte(`{"to": ["a@b.com"], "from": "x@y.com", "subject": "s", "body": "b"}`)
+	req := httptest.NewRequest("POST", "/email/smtp/send", bytes.NewReader(body))
+	keys["k1"] = &store.IdempotencyKey{Key: "k1", RequestHash: requestHash(req, body)}
+	w := postWithKey(r, "k1", body)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %d", w.Code)
+	}
+}
+
+// --- GetJob tests ---
+
+func TestGetJob_Found_Returns200(t *testing.T) {
+	tenantID := uuid.New()
+	jobID := uuid.New()
+	now := time.Now()
+	job := store.Job{
+		ID:        jobID,
+		TenantID:  tenantID,
+		JobType:   "email.send",
+		Status:    "completed",
+		Error:     pgtype.Text{Valid: false},
+		RunAt:     now,
+		CreatedAt: now,
+	}
+
+	q := &stubQuerier{
+		getJobFn: func(_ context.Context, arg store.GetJobParams) (store.Job, error) {
+			if arg.ID != jobID || arg.TenantID != tenantID {
+				t.Errorf("unexpected GetJob params: %+v", arg)
+			}
+			return job, nil
+		},
+	}
+	h := &Handler{queries: q}
+
+	c, w := ginCtx("GET", "/jobs/"+jobID.String(), nil, tenantID,
+		gin.Params{{Key: "id", Value: jobID.String()}})
+	h.GetJob(c)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["status"] != "completed" {
+		t.Errorf("expected status=completed, got %v", resp["status"])
+	}
+}
+
+func TestGetJob_IncludesAttemptTimeline(t *testing.T) {
+	jobID := uuid.New()
+	started := time.Now().Add(-time.Minute)
+	q := &stubQuerier{
+		getJobFn: func(_ context.Context, arg store.GetJobParams) (store.Job, error) {
+			return store.Job{ID: jobID, Status: "completed", Attempt: 2}, nil
+		},
+		listAttemptsFn: func(_ context.Context, ids []uuid.UUID) ([]store.JobAttempt, error) {
+			if len(ids) != 1 || ids[0] != jobID {
+				t.Errorf("unexpected job ids: %v", ids)
+			}
+			return []store.JobAttempt{
+				{JobID: jobID, Attempt: 1, WorkerID: "w1", Outcome: "lease_expired", StartedAt: started},
+				{JobID: jobID, Attempt: 2, WorkerID: "w2", Outcome: "completed", StartedAt: started.Add(30 * time.Second),
+					DurationMs: pgtype.Int8{Int64: 1200, Valid: true}},
+			}, nil
+		},
+	}
+	h := &Handler{queries: q}
+
+	c, w := ginCtx("GET", "/jobs/"+jobID.String(), nil, uuid.New(),
+		gin.Params{{Key: "id", Value: jobID.String()}})
+	h.GetJob(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Status   string             `json:"status"`
+		Attempts []store.JobAttempt `json:"attempts"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Status != "completed" || len(resp.Attempts) != 2 {
+		t.Fatalf("unexpected response: %s", w.Body.String())
+	}
+	if resp.Attempts[0].WorkerID != "w1" || resp.Attempts[1].Outcome != "completed" {
+		t.Errorf("unexpected attempts: %+v", resp.Attempts)
+	}
+}
+
+func TestGetJob_NotFound_Returns404(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{}} // getJobFn is nil → returns pgx.ErrNoRows
+
+	jobID := uuid.New()
+	c, w := ginCtx("GET", "/jobs/"+jobID.String(), nil, uuid.New(),
+		gin.Params{{Key: "id", Value: jobID.String()}})
+	h.GetJob(c)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestGetJob_InvalidUUID_Returns400(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{}}
 
 	c, w := ginCtx("GET", "/jobs/not-a-uuid", nil, uuid.New(),
 		gin.Params{{Key: "id", Value: "not-a-uuid"}})
@@ -334,39 +1178,471 @@ func TestGetJob_TenantScoped(t *testing.T) {
 	}
 }
 
-// --- Executor registry tests ---
+// --- Job event stream tests ---
 
-func TestExecutor_JobTypes(t *testing.T) {
-	// Each executor must self-report the job type string that keys the registry.
-	h := &Handler{}
-	cases := []struct {
-		exec    Executor
-		wantKey string
-	}{
-		{&emailExecutor{h}, "email.send"},
-		{&smsExecutor{h}, "sms.send"},
-		{&codeExecutor{h}, "code.execute"},
-	}
-	for _, tc := range cases {
-		if got := tc.exec.JobType(); got != tc.wantKey {
-			t.Errorf("expected JobType()=%q, got %q", tc.wantKey, got)
-		}
+func TestStreamJobEvents_SendsCurrentStatusThenTransitions(t *testing.T) {
+	tenantID := uuid.New()
+	jobID := uuid.New()
+	h := &Handler{jobEvents: newJobEventHub()}
+	h.queries = &stubQuerier{
+		getJobFn: func(_ context.Context, arg store.GetJobParams) (store.Job, error) {
+			// Published after the handler subscribed but before it read the
+			// job, so it must still be streamed.
+			h.jobEvents.publish(fmt.Sprintf(`{"job_id":%q,"tenant_id":%q,"job_type":"code.execute","status":"completed","attempt":1}`, jobID, tenantID))
+			return store.Job{ID: jobID, TenantID: tenantID, JobType: "code.execute", Status: "running", Attempt: 1}, nil
+		},
 	}
-}
 
-func TestRegisterExecutors_PopulatesAllTypes(t *testing.T) {
-	h := &Handler{queries: &stubQuerier{}}
-	h.registerExecutors()
+	c, w := ginCtx("GET", "/jobs/"+jobID.String()+"/events", nil, tenantID,
+		gin.Params{{Key: "id", Value: jobID.String()}})
+	h.StreamJobEvents(c) // returns once the job is completed
 
-	for _, jobType := range []string{"email.send", "sms.send", "code.execute"} {
-		if _, ok := h.executors[jobType]; !ok {
-			t.Errorf("executor for job type %q was not registered", jobType)
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %q", ct)
+	}
+	events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %q", w.Body.String())
+	}
+	for i, want := range []string{"running", "completed"} {
+		data, ok := strings.CutPrefix(events[i], "event: status\ndata: ")
+		if !ok {
+			t.Fatalf("malformed event %q", events[i])
+		}
+		var ev map[string]any
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			t.Fatalf("invalid event data %q: %v", data, err)
+		}
+		if ev["status"] != want || ev["job_id"] != jobID.String() {
+			t.Errorf("event %d: expected status %s, got %v", i, want, ev)
+		}
+		if _, ok := ev["tenant_id"]; ok {
+			t.Errorf("event %d: tenant_id should not be sent", i)
 		}
 	}
 }
 
-func TestExecuteJob_UnknownJobType_ReturnsError(t *testing.T) {
-	tenantID := uuid.New()
+func TestStreamJobEvents_TerminalJobEndsImmediately(t *testing.T) {
+	jobID := uuid.New()
+	q := &stubQuerier{
+		getJobFn: func(_ context.Context, arg store.GetJobParams) (store.Job, error) {
+			return store.Job{ID: jobID, Status: "failed", Error: pgtype.Text{String: "boom", Valid: true}}, nil
+		},
+	}
+	h := &Handler{queries: q, jobEvents: newJobEventHub()}
+
+	c, w := ginCtx("GET", "/jobs/"+jobID.String()+"/events", nil, uuid.New(),
+		gin.Params{{Key: "id", Value: jobID.String()}})
+	h.StreamJobEvents(c)
+
+	if !strings.Contains(w.Body.String(), `"status":"failed"`) || !strings.Contains(w.Body.String(), `"error":"boom"`) {
+		t.Errorf("unexpected stream: %q", w.Body.String())
+	}
+	if len(h.jobEvents.subs) != 0 {
+		t.Errorf("expected subscriber to be removed, got %d", len(h.jobEvents.subs))
+	}
+}
+
+func TestStreamJobEvents_NotFound_Returns404(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{}, jobEvents: newJobEventHub()}
+
+	jobID := uuid.New()
+	c, w := ginCtx("GET", "/jobs/"+jobID.String()+"/events", nil, uuid.New(),
+		gin.Params{{Key: "id", Value: jobID.String()}})
+	h.StreamJobEvents(c)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestJobEventHub_FiltersByTenantAndJob(t *testing.T) {
+	hub := newJobEventHub()
+	tenantA, tenantB := uuid.New(), uuid.New()
+	job1, job2 := uuid.New(), uuid.New()
+	all := hub.subscribe(tenantA, uuid.Nil)
+	one := hub.subscribe(tenantA, job1)
+	other := hub.subscribe(tenantB, uuid.Nil)
+
+	for _, job := range []uuid.UUID{job1, job2} {
+		hub.publish(fmt.Sprintf(`{"job_id":%q,"tenant_id":%q,"status":"running"}`, job, tenantA))
+	}
+	hub.publish("not json")
+
+	if len(all.ch) != 2 || len(one.ch) != 1 || len(other.ch) != 0 {
+		t.Fatalf("unexpected deliveries: all=%d one=%d other=%d", len(all.ch), len(one.ch), len(other.ch))
+	}
+	if ev := <-one.ch; ev.JobID != job1 || ev.TenantID != tenantA {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+
+	hub.close()
+	if _, ok := <-other.ch; ok {
+		t.Error("expected close to end open streams")
+	}
+	if _, ok := <-hub.subscribe(tenantA, uuid.Nil).ch; ok {
+		t.Error("expected subscriptions after close to be closed")
+	}
+}
+
+func TestJobEventHub_DropsSlowSubscriber(t *testing.T) {
+	hub := newJobEventHub()
+	tenantID := uuid.New()
+	sub := hub.subscribe(tenantID, uuid.Nil)
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		hub.publish(fmt.Sprintf(`{"job_id":%q,"tenant_id":%q,"status":"pending"}`, uuid.New(), tenantID))
+	}
+
+	if len(hub.subs) != 0 {
+		t.Fatal("expected slow subscriber to be dropped")
+	}
+	n := 0
+	for range sub.ch {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("expected %d buffered events before close, got %d", subscriberBuffer, n)
+	}
+}
+
+// --- ListJobs tests ---
+
+func TestListJobs_FiltersAndCounts(t *testing.T) {
+	tenantID := uuid.New()
+	var gotList store.ListJobsParams
+	var gotCount store.CountJobsByStatusParams
+	q := &stubQuerier{
+		listJobsFn: func(_ context.Context, arg store.ListJobsParams) ([]store.Job, error) {
+			gotList = arg
+			return []store.Job{{ID: uuid.New(), Status: "failed"}}, nil
+		},
+		countJobsFn: func(_ context.Context, arg store.CountJobsByStatusParams) ([]store.CountJobsByStatusRow, error) {
+			gotCount = arg
+			return []store.CountJobsByStatusRow{{Status: "failed", Count: 3}, {Status: "completed", Count: 7}}, nil
+		},
+	}
+	h := &Handler{queries: q}
+
+	c, w := ginCtx("GET", "/jobs?status=failed&job_type=email.send&created_after=2025-01-01T00:00:00Z&limit=10", nil, tenantID, nil)
+	h.ListJobs(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if gotList.TenantID != tenantID || gotList.Status.String != "failed" || gotList.JobType.String != "email.send" ||
+		gotList.CreatedAfter == nil || gotList.CreatedBefore != nil || gotList.PageSize != 10 {
+		t.Errorf("unexpected list params: %+v", gotList)
+	}
+	if gotCount.TenantID != tenantID || gotCount.JobType.String != "email.send" || gotCount.CreatedAfter == nil {
+		t.Errorf("counts should share the non-status filters, got %+v", gotCount)
+	}
+
+	var resp struct {
+		Jobs       []store.Job      `json:"jobs"`
+		Counts     map[string]int64 `json:"counts"`
+		NextCursor string           `json:"next_cursor"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Jobs) != 1 {
+		t.Errorf("expected 1 job, got %d", len(resp.Jobs))
+	}
+	if resp.Counts["failed"] != 3 || resp.Counts["completed"] != 7 || resp.Counts["pending"] != 0 {
+		t.Errorf("unexpected counts: %v", resp.Counts)
+	}
+	if resp.NextCursor != "" {
+		t.Error("a short page should not return next_cursor")
+	}
+}
+
+func TestListJobs_CursorRoundTrip(t *testing.T) {
+	last := store.Job{ID: uuid.New(), CreatedAt: time.Date(2025, 3, 1, 12, 0, 0, 123456000, time.UTC)}
+	var gotList store.ListJobsParams
+	q := &stubQuerier{
+		listJobsFn: func(_ context.Context, arg store.ListJobsParams) ([]store.Job, error) {
+			gotList = arg
+			return []store.Job{{ID: uuid.New()}, last}, nil
+		},
+	}
+	h := &Handler{queries: q}
+
+	c, w := ginCtx("GET", "/jobs?limit=2", nil, uuid.New(), nil)
+	h.ListJobs(c)
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	cursor, _ := resp["next_cursor"].(string)
+	if cursor == "" {
+		t.Fatal("expected next_cursor on a full page")
+	}
+
+	c, w = ginCtx("GET", "/jobs?limit=2&cursor="+cursor, nil, uuid.New(), nil)
+	h.ListJobs(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if gotList.CursorCreatedAt == nil || !gotList.CursorCreatedAt.Equal(last.CreatedAt) || gotList.CursorID != last.ID {
+		t.Errorf("cursor did not round-trip: %+v", gotList)
+	}
+}
+
+func TestListJobs_InvalidParams_Returns400(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{}}
+	for _, query := range []string{"status=bogus", "created_before=yesterday", "limit=0", "limit=1000", "cursor=%21%21"} {
+		c, w := ginCtx("GET", "/jobs?"+query, nil, uuid.New(), nil)
+		h.ListJobs(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+// --- Dead-letter tests ---
+
+func TestListDeadLetterJobs_IncludesAttempts(t *testing.T) {
+	jobA, jobB := uuid.New(), uuid.New()
+	var gotList store.ListJobsParams
+	q := &stubQuerier{
+		listJobsFn: func(_ context.Context, arg store.ListJobsParams) ([]store.Job, error) {
+			gotList = arg
+			return []store.Job{{ID: jobA, Status: "failed"}, {ID: jobB, Status: "failed"}}, nil
+		},
+		listAttemptsFn: func(_ context.Context, ids []uuid.UUID) ([]store.JobAttempt, error) {
+			if len(ids) != 2 {
+				t.Errorf("expected attempts for 2 jobs, got %d", len(ids))
+			}
+			return []store.JobAttempt{
+				{JobID: jobA, Attempt: 1, Outcome: "failed", Error: pgtype.Text{String: "timeout", Valid: true}},
+				{JobID: jobA, Attempt: 2, Outcome: "failed", Error: pgtype.Text{String: "connection refused", Valid: true}},
+			}, nil
+		},
+	}
+	h := &Handler{queries: q}
+
+	c, w := ginCtx("GET", "/jobs/dead-letter?job_type=sms.send", nil, uuid.New(), nil)
+	h.ListDeadLetterJobs(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if gotList.Status.String != "failed" || gotList.JobType.String != "sms.send" {
+		t.Errorf("expected failed sms.send filter, got %+v", gotList)
+	}
+	var resp struct {
+		Jobs []struct {
+			ID       uuid.UUID          `json:"id"`
+			Attempts []store.JobAttempt `json:"attempts"`
+		} `json:"jobs"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Jobs) != 2 || len(resp.Jobs[0].Attempts) != 2 || resp.Jobs[1].Attempts == nil {
+		t.Errorf("unexpected dead-letter response: %s", w.Body.String())
+	}
+}
+
+func TestRetryJob(t *testing.T) {
+	cases := []struct {
+		name     string
+		retryErr error
+		status   string
+		workflow bool
+		wantCode int
+	}{
+		{"failed job", nil, "failed", false, http.StatusAccepted},
+		{"completed job", pgx.ErrNoRows, "completed", false, http.StatusConflict},
+		{"failed workflow step", pgx.ErrNoRows, "failed", true, http.StatusConflict},
+	}
+	for _, tc := range cases {
+		jobID := uuid.New()
+		q := &stubQuerier{
+			retryJobFn: func(_ context.Context, arg store.RetryJobParams) (store.Job, error) {
+				return store.Job{ID: arg.ID, Status: "pending"}, tc.retryErr
+			},
+			getJobFn: func(_ context.Context, arg store.GetJobParams) (store.Job, error) {
+				return store.Job{ID: arg.ID, Status: tc.status, WorkflowID: pgtype.UUID{Bytes: uuid.New(), Valid: tc.workflow}}, nil
+			},
+		}
+		h := &Handler{queries: q}
+
+		c, w := ginCtx("POST", "/jobs/"+jobID.String()+"/retry", nil, uuid.New(), gin.Params{{Key: "id", Value: jobID.String()}})
+		h.RetryJob(c)
+		if w.Code != tc.wantCode {
+			t.Errorf("%s: expected %d, got %d: %s", tc.name, tc.wantCode, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestReplayDeadLetterJobs(t *testing.T) {
+	tenantID := uuid.New()
+	var got store.ReplayFailedJobsParams
+	q := &stubQuerier{
+		replayFn: func(_ context.Context, arg store.ReplayFailedJobsParams) (int64, error) {
+			got = arg
+			return 12, nil
+		},
+	}
+	h := &Handler{queries: q}
+
+	body, _ := json.Marshal(map[string]string{
+		"job_type": "email.send", "created_after": "2025-06-01T09:00:00Z", "created_before": "2025-06-01T11:00:00Z",
+	})
+	c, w := ginCtx("POST", "/jobs/dead-letter/replay", body, tenantID, nil)
+	h.ReplayDeadLetterJobs(c)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if got.TenantID != tenantID || got.JobType.String != "email.send" || got.CreatedBefore.Sub(got.CreatedAfter) != 2*time.Hour {
+		t.Errorf("unexpected replay params: %+v", got)
+	}
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["replayed"] != float64(12) || resp["more"] != false {
+		t.Errorf("unexpected response: %v", resp)
+	}
+}
+
+func TestReplayDeadLetterJobs_RequiresWindow(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{}}
+	for _, b := range []map[string]string{
+		{"job_type": "email.send"},
+		{"created_after": "2025-06-01T11:00:00Z", "created_before": "2025-06-01T09:00:00Z"},
+	} {
+		body, _ := json.Marshal(b)
+		c, w := ginCtx("POST", "/jobs/dead-letter/replay", body, uuid.New(), nil)
+		h.ReplayDeadLetterJobs(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%v: expected 400, got %d", b, w.Code)
+		}
+	}
+}
+
+// --- CancelJob tests ---
+
+func TestCancelJob_Pending_Returns200(t *testing.T) {
+	jobID := uuid.New()
+	q := &stubQuerier{
+		cancelJobFn: func(_ context.Context, arg store.CancelJobParams) (store.Job, error) {
+			return store.Job{ID: arg.ID, Status: "cancelled"}, nil
+		},
+	}
+	h := &Handler{queries: q}
+
+	c, w := ginCtx("DELETE", "/jobs/"+jobID.String(), nil, uuid.New(), gin.Params{{Key: "id", Value: jobID.String()}})
+	h.CancelJob(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["status"] != "cancelled" {
+		t.Errorf("expected status=cancelled, got %v", resp["status"])
+	}
+}
+
+func TestCancelJob_NotPending(t *testing.T) {
+	cases := []struct {
+		name        string
+		status      string
+		jobType     string
+		wantCode    int
+		wantFlagged bool
+	}{
+		{"completed", "completed", "email.send", http.StatusConflict, false},
+		{"running, not cancellable", "running", "email.send", http.StatusConflict, false},
+		{"running, cancellable", "running", "code.execute", http.StatusAccepted, true},
+	}
+	for _, tc := range cases {
+		jobID := uuid.New()
+		var flagged bool
+		q := &stubQuerier{
+			getJobFn: func(_ context.Context, arg store.GetJobParams) (store.Job, error) {
+				return store.Job{ID: arg.ID, TenantID: arg.TenantID, Status: tc.status, JobType: tc.jobType}, nil
+			},
+			requestCancelFn: func(_ context.Context, arg store.RequestJobCancelParams) (store.Job, error) {
+				flagged = true
+				return store.Job{ID: arg.ID, Status: "running", CancelRequested: true}, nil
+			},
+		}
+		h := &Handler{queries: q}
+		h.registerExecutors()
+
+		c, w := ginCtx("DELETE", "/jobs/"+jobID.String(), nil, uuid.New(), gin.Params{{Key: "id", Value: jobID.String()}})
+		h.CancelJob(c)
+
+		if w.Code != tc.wantCode {
+			t.Errorf("%s: expected %d, got %d: %s", tc.name, tc.wantCode, w.Code, w.Body.String())
+		}
+		if flagged != tc.wantFlagged {
+			t.Errorf("%s: expected cancel requested=%v, got %v", tc.name, tc.wantFlagged, flagged)
+		}
+	}
+}
+
+func TestCancelJob_NotFound_Returns404(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{}}
+	jobID := uuid.New().String()
+	c, w := ginCtx("DELETE", "/jobs/"+jobID, nil, uuid.New(), gin.Params{{Key: "id", Value: jobID}})
+	h.CancelJob(c)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+// --- Executor registry tests ---
+
+func TestExecutor_JobTypes(t *testing.T) {
+	// Each executor must self-report the job type string that keys the registry.
+	h := &Handler{}
+	cases := []struct {
+		exec    Executor
+		wantKey string
+	}{
+		{&emailExecutor{h}, "email.send"},
+		{&emailTemplateExecutor{h}, "email.send_template"},
+		{&smsExecutor{h}, "sms.send"},
+		{&codeExecutor{h}, "code.execute"},
+	}
+	for _, tc := range cases {
+		if got := tc.exec.JobType(); got != tc.wantKey {
+			t.Errorf("expected JobType()=%q, got %q", tc.wantKey, got)
+		}
+	}
+}
+
+func TestRegisterExecutors_PopulatesAllTypes(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{}}
+	h.registerExecutors()
+
+	for _, jobType := range []string{"email.send", "email.send_template", "sms.send", "code.execute"} {
+		if _, ok := h.executors[jobType]; !ok {
+			t.Errorf("executor for job type %q was not registered", jobType)
+		}
+	}
+}
+
+func TestEmailTemplateExecutor_RenderErrorIsPermanent(t *testing.T) {
+	cases := map[string]string{
+		"bad variable": "Hi {{.name.first}}",
+		"bad template": "Hi {{.name",
+	}
+	for name, body := range cases {
+		h := &Handler{queries: &stubQuerier{
+			getTemplateFn: func(_ context.Context, arg store.GetEmailTemplateParams) (store.EmailTemplate, error) {
+				return store.EmailTemplate{Name: arg.Name, Subject: "Welcome", Body: body}, nil
+			},
+		}}
+		payload := json.RawMessage(`{"provider":"smtp","template":"welcome","to":["a@example.com"],"from":"b@example.com","variables":{"name":"Ada"}}`)
+		err := (&emailTemplateExecutor{h}).Execute(context.Background(), uuid.New(), &store.Tenant{ID: uuid.New()}, payload)
+		if !worker.IsPermanent(err) {
+			t.Errorf("%s: expected a permanent error, got %v", name, err)
+		}
+	}
+}
+
+func TestExecuteJob_UnknownJobType_ReturnsError(t *testing.T) {
+	tenantID := uuid.New()
 	h := &Handler{
 		queries: &stubQuerier{
 			getTenantByIDFn: func(_ context.Context, id uuid.UUID) (store.Tenant, error) {
@@ -384,3 +1660,1140 @@ func TestExecuteJob_UnknownJobType_ReturnsError(t *testing.T) {
 		t.Errorf("expected 'unknown job type' in error, got: %v", err)
 	}
 }
+
+// payloadRecorder is an Executor that records the payload it is given.
+type payloadRecorder struct{ got json.RawMessage }
+
+func (r *payloadRecorder) JobType() string { return "test.record" }
+func (r *payloadRecorder) Execute(_ context.Context, _ uuid.UUID, _ *store.Tenant, payload json.RawMessage) error {
+	r.got = payload
+	return nil
+}
+
+func TestExecuteJob_OpensSealedPayload(t *testing.T) {
+	tn := store.Tenant{ID: uuid.New(), EncryptedDataKey: testEncDataKey}
+	rec := &payloadRecorder{}
+	h := &Handler{
+		queries: &stubQuerier{
+			getTenantByIDFn: func(_ context.Context, id uuid.UUID) (store.Tenant, error) { return tn, nil },
+		},
+		tenantSvc: testTenants,
+		executors: map[string]Executor{rec.JobType(): rec},
+	}
+	plaintext := []byte(`{"provider":"twilio","to":"+15550100","body":"secret"}`)
+	sealed, err := h.sealPayload(&tn, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("secret")) || bytes.Contains(sealed, []byte("+15550100")) {
+		t.Fatalf("sealed payload leaks plaintext: %s", sealed)
+	}
+	var meta map[string]any
+	json.Unmarshal(sealed, &meta)
+	if meta["provider"] != "twilio" {
+		t.Errorf("expected provider left in the clear, got %s", sealed)
+	}
+
+	if err := h.ExecuteJob(context.Background(), uuid.New(), tn.ID, rec.JobType(), sealed); err != nil {
+		t.Fatal(err)
+	}
+	if string(rec.got) != string(plaintext) {
+		t.Errorf("executor got %s, want %s", rec.got, plaintext)
+	}
+
+	// Payloads stored before encryption are passed through.
+	legacy := json.RawMessage(`{"provider":"twilio","body":"old"}`)
+	if err := h.ExecuteJob(context.Background(), uuid.New(), tn.ID, rec.JobType(), legacy); err != nil {
+		t.Fatal(err)
+	}
+	if string(rec.got) != string(legacy) {
+		t.Errorf("executor got %s, want %s", rec.got, legacy)
+	}
+
+	// A payload that fails to decrypt is not retried.
+	tampered := json.RawMessage(`{"provider":"twilio","ciphertext":"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}`)
+	if err := h.ExecuteJob(context.Background(), uuid.New(), tn.ID, rec.JobType(), tampered); !worker.IsPermanent(err) {
+		t.Errorf("expected a permanent error, got %v", err)
+	}
+}
+
+func TestEncryptPayloads_SealsPlaintextJobs(t *testing.T) {
+	tn := store.Tenant{ID: uuid.New(), EncryptedDataKey: testEncDataKey}
+	pending := []store.ListPlaintextJobPayloadsRow{
+		{ID: uuid.New(), TenantID: tn.ID, Payload: []byte(`{"provider":"smtp","body":"one"}`)},
+		{ID: uuid.New(), TenantID: tn.ID, Payload: []byte(`{"provider":"smtp","body":"two"}`)},
+	}
+	sealed := map[uuid.UUID][]byte{}
+	tenantLookups := 0
+	q := &stubQuerier{
+		getTenantByIDFn: func(_ context.Context, id uuid.UUID) (store.Tenant, error) {
+			tenantLookups++
+			return tn, nil
+		},
+		listPlainJobsFn: func(_ context.Context, limit int32) ([]store.ListPlaintextJobPayloadsRow, error) {
+			var rows []store.ListPlaintextJobPayloadsRow
+			for _, r := range pending {
+				if _, ok := sealed[r.ID]; !ok {
+					rows = append(rows, r)
+				}
+			}
+			return rows, nil
+		},
+		sealJobFn: func(_ context.Context, arg store.SealJobPayloadParams) (int64, error) {
+			sealed[arg.ID] = arg.Payload
+			return 1, nil
+		},
+	}
+	h := &Handler{queries: q, tenantSvc: testTenants}
+
+	n, err := h.EncryptPayloads(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || tenantLookups != 1 {
+		t.Errorf("expected 2 sealed with 1 tenant lookup, got %d and %d", n, tenantLookups)
+	}
+	for _, r := range pending {
+		if got := openTestPayload(t, sealed[r.ID]); string(got) != string(r.Payload) {
+			t.Errorf("job %s: sealed payload opens to %s, want %s", r.ID, got, r.Payload)
+		}
+	}
+}
+
+// --- Schedule tests ---
+
+func TestCreateSchedule_Valid_Returns201(t *testing.T) {
+	tenantID := uuid.New()
+	var gotParams store.CreateScheduleParams
+	q := &stubQuerier{
+		createScheduleFn: func(_ context.Context, arg store.CreateScheduleParams) (store.Schedule, error) {
+			gotParams = arg
+			return store.Schedule{ID: uuid.New(), Name: arg.Name, CronExpr: arg.CronExpr, Payload: arg.Payload, NextRunAt: arg.NextRunAt}, nil
+		},
+	}
+	h := &Handler{queries: q, tenantSvc: testTenants}
+	h.registerExecutors()
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"name": "nightly", "cron": "0 2 * * *", "job_type": "email.send_template",
+		"payload": map[string]interface{}{"provider": "smtp", "template": "welcome", "to": []string{"a@example.com"}, "from": "b@example.com"},
+	})
+	c, w := ginCtx("POST", "/schedules", body, tenantID, nil)
+	h.CreateSchedule(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if gotParams.TenantID != tenantID || gotParams.Timezone != "UTC" {
+		t.Errorf("unexpected params: %+v", gotParams)
+	}
+	if !gotParams.NextRunAt.After(time.Now()) || gotParams.NextRunAt.UTC().Hour() != 2 {
+		t.Errorf("expected next run at the next 02:00 UTC, got %s", gotParams.NextRunAt)
+	}
+	if bytes.Contains(gotParams.Payload, []byte("welcome")) {
+		t.Errorf("expected payload stored sealed, got %s", gotParams.Payload)
+	}
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if payload, ok := resp["payload"].(map[string]interface{}); !ok || payload["template"] != "welcome" {
+		t.Errorf("expected payload returned as JSON, got %v", resp["payload"])
+	}
+}
+
+func TestCreateSchedule_ResolvesJobOptions(t *testing.T) {
+	var gotParams store.CreateScheduleParams
+	q := &stubQuerier{
+		createScheduleFn: func(_ context.Context, arg store.CreateScheduleParams) (store.Schedule, error) {
+			gotParams = arg
+			return store.Schedule{ID: uuid.New(), Payload: arg.Payload, Queue: arg.Queue, MaxAttempts: arg.MaxAttempts}, nil
+		},
+	}
+	h := &Handler{queries: q, tenantSvc: testTenants}
+	h.registerExecutors()
+
+	// An OTP schedule keeps the sms.send defaults except for its overrides.
+	body, _ := json.Marshal(map[string]interface{}{
+		"name": "otp", "cron": "@hourly", "job_type": "sms.send",
+		"payload": map[string]interface{}{"provider": "twilio", "from": "+1", "to": "+2", "body": "hi"},
+		"retry":   map[string]interface{}{"max_attempts": 1}, "queue": "transactional", "priority": 50,
+	})
+	c, w := ginCtx("POST", "/schedules", body, uuid.New(), nil)
+	h.CreateSchedule(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	sms := retryDefaults["sms.send"]
+	if gotParams.MaxAttempts != 1 || gotParams.BackoffBaseSeconds != int32(sms.BaseDelay/time.Second) ||
+		gotParams.BackoffMaxSeconds != int32(sms.MaxDelay/time.Second) || gotParams.BackoffJitter != sms.Jitter {
+		t.Errorf("expected the sms.send policy with max_attempts 1, got %+v", gotParams)
+	}
+	if gotParams.Queue != "transactional" || gotParams.Priority != 50 {
+		t.Errorf("expected queue=transactional priority=50, got %q %d", gotParams.Queue, gotParams.Priority)
+	}
+}
+
+func TestCreateSchedule_Invalid_Returns400(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{}}
+	h.registerExecutors()
+
+	sms := map[string]string{"provider": "twilio", "from": "+1", "to": "+2", "body": "hi"}
+	cases := map[string]map[string]interface{}{
+		"bad cron":          {"name": "n", "cron": "61 * * * *", "job_type": "sms.send", "payload": sms},
+		"unknown job type":  {"name": "n", "cron": "@daily", "job_type": "push.send", "payload": sms},
+		"internal job type": {"name": "n", "cron": "@daily", "job_type": "webhook.deliver", "payload": map[string]string{"delivery_id": uuid.NewString()}},
+		"bad timezone":      {"name": "n", "cron": "@daily", "timezone": "Nowhere/Land", "job_type": "sms.send", "payload": sms},
+		"non-object":        {"name": "n", "cron": "@daily", "job_type": "sms.send", "payload": "hello"},
+		"null payload":      {"name": "n", "cron": "@daily", "job_type": "sms.send", "payload": nil},
+		"no provider":       {"name": "n", "cron": "@daily", "job_type": "sms.send", "payload": map[string]string{"from": "+1", "to": "+2", "body": "hi"}},
+		"missing field":     {"name": "n", "cron": "@daily", "job_type": "sms.send", "payload": map[string]string{"provider": "twilio", "from": "+1", "to": "+2"}},
+		"invalid email":     {"name": "n", "cron": "@daily", "job_type": "email.send", "payload": map[string]any{"provider": "smtp", "to": []string{"a@example.com"}, "from": "b@example.com", "subject": "Hi"}},
+		"send_at":           {"name": "n", "cron": "@daily", "job_type": "sms.send", "payload": sms, "send_at": time.Now().Add(time.Hour)},
+		"bad retry":         {"name": "n", "cron": "@daily", "job_type": "sms.send", "payload": sms, "retry": map[string]int{"max_attempts": 0}},
+		"bad queue":         {"name": "n", "cron": "@daily", "job_type": "sms.send", "payload": sms, "queue": "Bulk!"},
+	}
+	for name, b := range cases {
+		body, _ := json.Marshal(b)
+		c, w := ginCtx("POST", "/schedules", body, uuid.New(), nil)
+		h.CreateSchedule(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, w.Code)
+		}
+	}
+}
+
+func TestResumeSchedule_RecomputesNextRun(t *testing.T) {
+	id := uuid.New()
+	var gotParams store.ResumeScheduleParams
+	q := &stubQuerier{
+		getScheduleFn: func(_ context.Context, arg store.GetScheduleParams) (store.Schedule, error) {
+			// Paused long ago: its stored next_run_at is in the past.
+			return store.Schedule{ID: arg.ID, CronExpr: "@hourly", Timezone: "UTC", Paused: true,
+				NextRunAt: time.Now().Add(-48 * time.Hour)}, nil
+		},
+		resumeScheduleFn: func(_ context.Context, arg store.ResumeScheduleParams) (store.Schedule, error) {
+			gotParams = arg
+			return store.Schedule{ID: arg.ID, NextRunAt: arg.NextRunAt}, nil
+		},
+	}
+	h := &Handler{queries: q}
+
+	c, w := ginCtx("POST", "/schedules/"+id.String()+"/resume", nil, uuid.New(), gin.Params{{Key: "id", Value: id.String()}})
+	h.ResumeSchedule(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !gotParams.NextRunAt.After(time.Now()) || gotParams.NextRunAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("expected next run within the coming hour, got %s", gotParams.NextRunAt)
+	}
+}
+
+func TestGetSchedule_NotFound_Returns404(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{}}
+	id := uuid.New().String()
+	c, w := ginCtx("GET", "/schedules/"+id, nil, uuid.New(), gin.Params{{Key: "id", Value: id}})
+	h.GetSchedule(c)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+// --- Workflow tests ---
+
+func TestCreateWorkflow_QueuesRootSteps(t *testing.T) {
+	tenantID := uuid.New()
+	workflowID := uuid.New()
+	var gotParams store.CreateWorkflowParams
+	q := &stubQuerier{
+		createWorkflowFn: func(_ context.Context, arg store.CreateWorkflowParams) (store.Workflow, error) {
+			gotParams = arg
+			return store.Workflow{ID: workflowID, Name: arg.Name, Status: "running"}, nil
+		},
+		listStepsFn: func(_ context.Context, id uuid.UUID) ([]store.WorkflowStep, error) {
+			return []store.WorkflowStep{{WorkflowID: id, Name: "run", Status: "queued", JobID: pgtype.UUID{Bytes: uuid.New(), Valid: true}}}, nil
+		},
+	}
+	h := &Handler{queries: q, tenantSvc: testTenants}
+	h.registerExecutors()
+
+	body := []byte(`{"name": "run-and-report", "steps": [
+		{"name": "run", "job_type": "code.execute", "payload": {"provider": "judge0", "source_code": "print(42)", "language_id": 71},
+		 "on_success": ["report"], "priority": 5},
+		{"name": "report", "job_type": "email.send", "retry": {"max_attempts": 2},
+		 "payload": {"provider": "smtp", "to": ["a@example.com"], "from": "b@example.com", "subject": "Result", "body": "{{steps.run.output.stdout}}"}}
+	]}`)
+	c, w := ginCtx("POST", "/workflows", body, tenantID, nil)
+	h.CreateWorkflow(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if gotParams.TenantID != tenantID || gotParams.Name != "run-and-report" {
+		t.Errorf("unexpected params: %+v", gotParams)
+	}
+	var rows []workflowStepRow
+	if err := json.Unmarshal(gotParams.Steps, &rows); err != nil || len(rows) != 2 {
+		t.Fatalf("unexpected steps %s: %v", gotParams.Steps, err)
+	}
+	if !rows[0].Queued || rows[0].Provider != "judge0" || rows[0].Priority != 5 || rows[0].Queue != "default" ||
+		len(rows[0].OnSuccess) != 1 || rows[0].OnFailure == nil {
+		t.Errorf("unexpected root step: %+v", rows[0])
+	}
+	if rows[1].Queued || rows[1].Position != 1 || rows[1].MaxAttempts != 2 || rows[1].BackoffBaseSeconds != 30 {
+		t.Errorf("unexpected downstream step: %+v", rows[1])
+	}
+	if bytes.Contains(rows[1].Payload, []byte("a@example.com")) {
+		t.Errorf("expected step payload stored sealed, got %s", rows[1].Payload)
+	}
+	if tmpl := openTestPayload(t, rows[1].Payload); !bytes.Contains(tmpl, []byte(`{{steps.run.output.stdout}}`)) {
+		t.Errorf("unexpected step payload: %s", tmpl)
+	}
+	var resp workflowResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.ID != workflowID || len(resp.Steps) != 1 || resp.Steps[0].JobID == nil || string(resp.Steps[0].Output) != "null" {
+		t.Errorf("unexpected response: %s", w.Body.String())
+	}
+}
+
+func TestCreateWorkflow_Invalid_Returns400(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{
+		createWorkflowFn: func(_ context.Context, arg store.CreateWorkflowParams) (store.Workflow, error) {
+			t.Error("CreateWorkflow should not be called")
+			return store.Workflow{}, nil
+		},
+	}}
+	h.registerExecutors()
+
+	cases := map[string]string{
+		"no steps":         `{"steps": []}`,
+		"cycle":            `{"steps": [{"name": "a", "job_type": "sms.send", "payload": {}, "on_success": ["b"]}, {"name": "b", "job_type": "sms.send", "payload": {}, "on_success": ["a"]}]}`,
+		"unknown job type": `{"steps": [{"name": "a", "job_type": "push.send", "payload": {}}]}`,
+		"internal job":     `{"steps": [{"name": "a", "job_type": "webhook.deliver", "payload": {}}]}`,
+		"send_at":          `{"steps": [{"name": "a", "job_type": "sms.send", "payload": {}, "send_at": "2030-01-01T00:00:00Z"}]}`,
+		"bad retry":        `{"steps": [{"name": "a", "job_type": "sms.send", "payload": {}, "retry": {"max_attempts": 0}}]}`,
+		"bad reference":    `{"steps": [{"name": "a", "job_type": "sms.send", "payload": {"body": "{{steps.b.output}}"}}, {"name": "b", "job_type": "sms.send", "payload": {}}]}`,
+	}
+	for name, body := range cases {
+		c, w := ginCtx("POST", "/workflows", []byte(body), uuid.New(), nil)
+		h.CreateWorkflow(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestGetWorkflow_NotFound_Returns404(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{}}
+	id := uuid.New().String()
+
+	c, w := ginCtx("GET", "/workflows/"+id, nil, uuid.New(), gin.Params{{Key: "id", Value: id}})
+	h.GetWorkflow(c)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestCreateBatch_QueuesAllItems(t *testing.T) {
+	tenantID := uuid.New()
+	batchID := uuid.New()
+	var gotParams store.CreateBatchParams
+	q := &stubQuerier{
+		createBatchFn: func(_ context.Context, arg store.CreateBatchParams) (store.Batch, error) {
+			gotParams = arg
+			return store.Batch{ID: batchID, TenantID: arg.TenantID, Total: 2}, nil
+		},
+	}
+	h := &Handler{queries: q, tenantSvc: testTenants}
+	h.registerExecutors()
+
+	body := []byte(`{"items": [
+		{"job_type": "email.send", "payload": {"provider": "smtp", "to": ["a@example.com"], "from": "b@example.com", "subject": "Hi", "body": "Hello"}},
+		{"job_type": "sms.send", "queue": "bulk", "priority": 7, "send_at": "` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `",
+		 "payload": {"provider": "twilio", "from": "+15550001111", "to": "+15559998888", "body": "Hi"}}
+	]}`)
+	c, w := ginCtx("POST", "/batches", body, tenantID, nil)
+	h.CreateBatch(c)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if gotParams.TenantID != tenantID {
+		t.Errorf("unexpected tenant: %s", gotParams.TenantID)
+	}
+	var rows []batchJobRow
+	if err := json.Unmarshal(gotParams.Jobs, &rows); err != nil || len(rows) != 2 {
+		t.Fatalf("unexpected jobs %s: %v", gotParams.Jobs, err)
+	}
+	if rows[0].Provider != "smtp" || rows[0].Queue != "default" || rows[0].MaxAttempts != 8 || rows[0].RunAt != nil {
+		t.Errorf("unexpected email job: %+v", rows[0])
+	}
+	if rows[1].Provider != "twilio" || rows[1].Queue != "bulk" || rows[1].Priority != 7 || rows[1].RunAt == nil {
+		t.Errorf("unexpected sms job: %+v", rows[1])
+	}
+	if bytes.Contains(rows[0].Payload, []byte("a@example.com")) {
+		t.Errorf("expected job payload stored sealed, got %s", rows[0].Payload)
+	}
+	if p := openTestPayload(t, rows[0].Payload); !bytes.Contains(p, []byte("a@example.com")) {
+		t.Errorf("unexpected job payload: %s", p)
+	}
+	var resp struct {
+		BatchID uuid.UUID   `json:"batch_id"`
+		Total   int32       `json:"total"`
+		JobIDs  []uuid.UUID `json:"job_ids"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.BatchID != batchID || resp.Total != 2 || len(resp.JobIDs) != 2 || resp.JobIDs[0] != rows[0].ID || resp.JobIDs[1] != rows[1].ID {
+		t.Errorf("unexpected response: %s", w.Body.String())
+	}
+}
+
+func TestCreateBatch_Invalid_Returns400(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{
+		createBatchFn: func(_ context.Context, arg store.CreateBatchParams) (store.Batch, error) {
+			t.Error("CreateBatch should not be called")
+			return store.Batch{}, nil
+		},
+	}}
+	h.registerExecutors()
+
+	const smsPayload = `{"provider": "twilio", "from": "+15550001111", "to": "+15559998888", "body": "Hi"}`
+	cases := map[string]string{
+		"no items":         `{"items": []}`,
+		"unknown job type": `{"items": [{"job_type": "sms.send", "payload": {}}, {"job_type": "push.send", "payload": {}}]}`,
+		"internal job":     `{"items": [{"job_type": "webhook.deliver", "payload": {}}]}`,
+		"payload not obj":  `{"items": [{"job_type": "sms.send", "payload": [1]}]}`,
+		"null payload":     `{"items": [{"job_type": "sms.send", "payload": null}]}`,
+		"no payload":       `{"items": [{"job_type": "sms.send"}]}`,
+		"no provider":      `{"items": [{"job_type": "sms.send", "payload": {"from": "+15550001111", "to": "+15559998888", "body": "Hi"}}]}`,
+		"missing field":    `{"items": [{"job_type": "sms.send", "payload": {"provider": "twilio", "from": "+15550001111", "to": "+15559998888"}}]}`,
+		"bad field type":   `{"items": [{"job_type": "code.execute", "payload": {"provider": "judge0", "source_code": "print(1)", "language_id": "py"}}]}`,
+		"bad queue":        `{"items": [{"job_type": "sms.send", "payload": ` + smsPayload + `, "queue": "Bulk!"}]}`,
+		"too far ahead":    `{"items": [{"job_type": "sms.send", "payload": ` + smsPayload + `, "send_at": "2999-01-01T00:00:00Z"}]}`,
+	}
+	for name, body := range cases {
+		c, w := ginCtx("POST", "/batches", []byte(body), uuid.New(), nil)
+		h.CreateBatch(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestCreateBatch_ChecksItemPayloadsLikeTheirEndpoints(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{}}
+	h.registerExecutors()
+
+	cases := []struct {
+		item      string
+		wantField string
+	}{
+		{`{"job_type": "email.send", "payload": {"provider": "smtp", "to": ["a@example.com"], "from": "b@example.com", "body": "Hello"}}`, "Subject"},
+		{`{"job_type": "email.send_template", "payload": {"provider": "smtp", "to": ["a@example.com"], "from": "b@example.com"}}`, "Template"},
+		{`{"job_type": "sms.send", "payload": {"provider": "twilio", "to": "+15559998888", "body": "Hi"}}`, "From"},
+		{`{"job_type": "code.execute", "payload": {"provider": "judge0", "language_id": 71}}`, "SourceCode"},
+	}
+	valid := `{"job_type": "sms.send", "payload": {"provider": "twilio", "from": "+15550001111", "to": "+15559998888", "body": "Hi"}}`
+	for _, tc := range cases {
+		body := []byte(`{"items": [` + valid + `, ` + tc.item + `]}`)
+		c, w := ginCtx("POST", "/batches", body, uuid.New(), nil)
+		h.CreateBatch(c)
+
+		var resp struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != http.StatusBadRequest || !strings.HasPrefix(resp.Error, "item 1: ") || !strings.Contains(resp.Error, "'"+tc.wantField+"'") {
+			t.Errorf("%s: expected 400 for item 1 naming %s, got %d: %s", tc.item, tc.wantField, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestGetBatch_ReportsProgress(t *testing.T) {
+	tenantID := uuid.New()
+	id := uuid.New()
+	cases := []struct {
+		row    store.GetBatchRow
+		status string
+	}{
+		{store.GetBatchRow{ID: id, Total: 5, Pending: 2, Running: 1, Completed: 1, Failed: 1}, "running"},
+		{store.GetBatchRow{ID: id, Total: 5, Completed: 3, Failed: 1, Cancelled: 1}, "finished"},
+	}
+	for _, tc := range cases {
+		var got store.GetBatchParams
+		h := &Handler{queries: &stubQuerier{
+			getBatchFn: func(_ context.Context, arg store.GetBatchParams) (store.GetBatchRow, error) {
+				got = arg
+				return tc.row, nil
+			},
+		}}
+		c, w := ginCtx("GET", "/batches/"+id.String(), nil, tenantID, gin.Params{{Key: "id", Value: id.String()}})
+		h.GetBatch(c)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if got.ID != id || got.TenantID != tenantID {
+			t.Errorf("unexpected params: %+v", got)
+		}
+		var resp batchResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Status != tc.status || resp.Total != tc.row.Total || resp.Pending != tc.row.Pending || resp.Failed != tc.row.Failed {
+			t.Errorf("expected status %q, got %+v", tc.status, resp)
+		}
+	}
+}
+
+func TestGetBatch_NotFound_Returns404(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{}}
+	id := uuid.New().String()
+
+	c, w := ginCtx("GET", "/batches/"+id, nil, uuid.New(), gin.Params{{Key: "id", Value: id}})
+	h.GetBatch(c)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestCancelJob_WorkflowStep_AdvancesWorkflow(t *testing.T) {
+	jobID := uuid.New()
+	var finished uuid.UUID
+	q := &stubQuerier{
+		cancelJobFn: func(_ context.Context, arg store.CancelJobParams) (store.Job, error) {
+			return store.Job{ID: arg.ID, Status: "cancelled", WorkflowID: pgtype.UUID{Bytes: uuid.New(), Valid: true}}, nil
+		},
+		finishStepFn: func(_ context.Context, id uuid.UUID) (store.WorkflowStep, error) {
+			finished = id
+			return store.WorkflowStep{}, pgx.ErrNoRows
+		},
+	}
+	h := &Handler{queries: q}
+
+	c, w := ginCtx("DELETE", "/jobs/"+jobID.String(), nil, uuid.New(), gin.Params{{Key: "id", Value: jobID.String()}})
+	h.CancelJob(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if finished != jobID {
+		t.Errorf("expected the workflow step of job %s to be finished, got %s", jobID, finished)
+	}
+}
+
+// --- Rate limit and circuit breaker tests ---
+
+func TestSetRateLimit(t *testing.T) {
+	cases := []struct {
+		name      string
+		provider  string
+		body      string
+		wantCode  int
+		wantBurst int32
+	}{
+		{"explicit burst", "twilio", `{"rate_per_second": 10, "burst": 25}`, http.StatusOK, 25},
+		{"burst defaults to rate", "sendgrid", `{"rate_per_second": 2.5}`, http.StatusOK, 3},
+		{"unsupported provider", "mailchimp", `{"rate_per_second": 1}`, http.StatusBadRequest, 0},
+		{"negative rate", "twilio", `{"rate_per_second": -1}`, http.StatusBadRequest, 0},
+		{"burst too large", "twilio", `{"rate_per_second": 1, "burst": 20000}`, http.StatusBadRequest, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tenantID := uuid.New()
+			var got store.UpsertProviderRateLimitParams
+			q := &stubQuerier{
+				upsertLimitFn: func(_ context.Context, arg store.UpsertProviderRateLimitParams) (store.ProviderRateLimit, error) {
+					got = arg
+					return store.ProviderRateLimit{Provider: arg.Provider, RatePerSecond: arg.RatePerSecond, Burst: arg.Burst}, nil
+				},
+			}
+			h := &Handler{queries: q}
+			c, w := ginCtx("PUT", "/rate-limits/"+tc.provider, []byte(tc.body), tenantID, gin.Params{{Key: "provider", Value: tc.provider}})
+			h.SetRateLimit(c)
+
+			if w.Code != tc.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tc.wantCode, w.Code, w.Body.String())
+			}
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+			if got.TenantID != tenantID || got.Provider != tc.provider || got.Burst != tc.wantBurst {
+				t.Errorf("unexpected upsert params: %+v", got)
+			}
+		})
+	}
+}
+
+func TestSetRetention(t *testing.T) {
+	cases := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantDays string
+	}{
+		{"valid", `{"days": 30}`, http.StatusOK, "30"},
+		{"zero", `{"days": 0}`, http.StatusBadRequest, ""},
+		{"too long", `{"days": 4000}`, http.StatusBadRequest, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tenantID := uuid.New()
+			var got store.SetTenantJobRetentionParams
+			q := &stubQuerier{
+				setRetentionFn: func(_ context.Context, arg store.SetTenantJobRetentionParams) (store.Tenant, error) {
+					got = arg
+					return store.Tenant{ID: arg.ID, JobRetentionDays: arg.JobRetentionDays}, nil
+				},
+			}
+			h := &Handler{queries: q}
+			c, w := ginCtx("PUT", "/retention", []byte(tc.body), tenantID, nil)
+			h.SetRetention(c)
+
+			if w.Code != tc.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tc.wantCode, w.Code, w.Body.String())
+			}
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+			if got.ID != tenantID || got.JobRetentionDays.Int32 != 30 {
+				t.Errorf("unexpected params: %+v", got)
+			}
+			if want := `{"days":` + tc.wantDays + `}`; w.Body.String() != want {
+				t.Errorf("expected %s, got %s", want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeleteRetention_RevertsToDefault(t *testing.T) {
+	var got store.SetTenantJobRetentionParams
+	q := &stubQuerier{
+		setRetentionFn: func(_ context.Context, arg store.SetTenantJobRetentionParams) (store.Tenant, error) {
+			got = arg
+			return store.Tenant{ID: arg.ID}, nil
+		},
+	}
+	h := &Handler{queries: q}
+	c, w := ginCtx("DELETE", "/retention", nil, uuid.New(), nil)
+	h.DeleteRetention(c)
+
+	if w.Code != http.StatusOK || got.JobRetentionDays.Valid {
+		t.Fatalf("expected the window cleared, got %d %+v", w.Code, got)
+	}
+	if w.Body.String() != `{"days":null}` {
+		t.Errorf("unexpected body: %s", w.Body.String())
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	r := gin.New()
+	r.GET("/admin/circuits", AdminAuth("s3cret"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for header, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusOK,
+	} {
+		req := httptest.NewRequest("GET", "/admin/circuits", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("Authorization %q: expected %d, got %d", header, want, w.Code)
+		}
+	}
+}
+
+func TestListCircuits_ReportsState(t *testing.T) {
+	future := time.Now().Add(time.Minute)
+	past := time.Now().Add(-time.Minute)
+	q := &stubQuerier{
+		listCircuitsFn: func(_ context.Context) ([]store.ProviderCircuit, error) {
+			return []store.ProviderCircuit{
+				{Provider: "twilio", ConsecutiveFailures: 5, OpenUntil: &future, LastError: pgtype.Text{String: "503", Valid: true}},
+				{Provider: "sendgrid", ConsecutiveFailures: 5, OpenUntil: &past},
+			}, nil
+		},
+	}
+	h := &Handler{queries: q}
+	c, w := ginCtx("GET", "/admin/circuits", nil, uuid.Nil, nil)
+	h.ListCircuits(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var resp []circuitResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp) != 2 {
+		t.Fatalf("expected 2 circuits, got %d", len(resp))
+	}
+	if resp[0].State != "open" || resp[0].OpenUntil == nil || resp[0].LastError == nil || *resp[0].LastError != "503" {
+		t.Errorf("expected twilio circuit open with last error, got %+v", resp[0])
+	}
+	if resp[1].State != "closed" || resp[1].OpenUntil != nil {
+		t.Errorf("expected sendgrid circuit closed once open_until passed, got %+v", resp[1])
+	}
+}
+
+func TestStatus_ReportsWorkersAndQueues(t *testing.T) {
+	now := time.Now()
+	stopped := now.Add(-time.Hour)
+	oldest := now.Add(-90 * time.Second)
+	jobID := uuid.New()
+	q := &stubQuerier{
+		listWorkersFn: func(_ context.Context) ([]store.Worker, error) {
+			return []store.Worker{
+				{ID: "alive", Queues: []string{"*"}, Concurrency: 10, LastHeartbeatAt: now.Add(-5 * time.Second)},
+				{ID: "lost", Queues: []string{"email"}, Concurrency: 2, LastHeartbeatAt: now.Add(-5 * time.Minute)},
+				{ID: "stopped", LastHeartbeatAt: stopped, StoppedAt: &stopped},
+			}, nil
+		},
+		runningJobsFn: func(_ context.Context) ([]store.ListRunningJobsRow, error) {
+			return []store.ListRunningJobsRow{
+				{WorkerID: "alive", ID: jobID, JobType: "email.send", Queue: "default", Attempt: 1},
+				{WorkerID: "unregistered", ID: uuid.New()},
+			}, nil
+		},
+		queueStatsFn: func(_ context.Context) ([]store.GetQueueStatsRow, error) {
+			return []store.GetQueueStatsRow{
+				{Queue: "default", Pending: 3, Scheduled: 1, Running: 1, OldestPendingRunAt: &oldest},
+				{Queue: "email", Scheduled: 2},
+			}, nil
+		},
+	}
+	h := &Handler{queries: q}
+	c, w := ginCtx("GET", "/admin/status", nil, uuid.Nil, nil)
+	h.Status(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var resp struct {
+		Workers []workerResponse     `json:"workers"`
+		Queues  []queueStatsResponse `json:"queues"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Workers) != 3 {
+		t.Fatalf("expected 3 workers, got %d", len(resp.Workers))
+	}
+	for i, state := range []string{"alive", "lost", "stopped"} {
+		if resp.Workers[i].State != state {
+			t.Errorf("worker %s: expected state %q, got %q", resp.Workers[i].ID, state, resp.Workers[i].State)
+		}
+	}
+	if jobs := resp.Workers[0].RunningJobs; len(jobs) != 1 || jobs[0].JobID != jobID {
+		t.Errorf("expected alive worker running job %s, got %+v", jobID, jobs)
+	}
+	if len(resp.Workers[1].RunningJobs) != 0 {
+		t.Errorf("expected lost worker running nothing, got %+v", resp.Workers[1].RunningJobs)
+	}
+	if len(resp.Queues) != 2 {
+		t.Fatalf("expected 2 queues, got %d", len(resp.Queues))
+	}
+	if age := resp.Queues[0].OldestPendingAgeSeconds; age == nil || *age < 89 {
+		t.Errorf("expected oldest pending age of ~90s, got %v", age)
+	}
+	if resp.Queues[1].OldestPendingAgeSeconds != nil {
+		t.Errorf("expected no oldest pending age for queue without due jobs, got %v", *resp.Queues[1].OldestPendingAgeSeconds)
+	}
+}
+
+// --- Tenant admin tests ---
+
+func TestListTenants_PagesAndReportsStatus(t *testing.T) {
+	after := uuid.New()
+	suspended := time.Now().Add(-time.Hour)
+	var got store.ListTenantsParams
+	h := &Handler{queries: &stubQuerier{
+		listTenantsFn: func(_ context.Context, arg store.ListTenantsParams) ([]store.Tenant, error) {
+			got = arg
+			return []store.Tenant{
+				{ID: uuid.New(), Name: "acme"},
+				{ID: uuid.New(), Name: "globex", SuspendedAt: &suspended},
+			}, nil
+		},
+	}}
+	c, w := ginCtx("GET", "/admin/tenants?limit=2&after="+after.String(), nil, uuid.Nil, nil)
+	h.ListTenants(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got.MaxResults != 2 || !got.AfterID.Valid || got.AfterID.Bytes != after {
+		t.Errorf("unexpected params: %+v", got)
+	}
+	var resp []tenantResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp) != 2 || resp[0].Status != "active" || resp[1].Status != "suspended" || resp[1].SuspendedAt == nil {
+		t.Errorf("unexpected response: %s", w.Body.String())
+	}
+}
+
+func TestListTenants_InvalidParams_Returns400(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{}}
+	for _, query := range []string{"limit=0", "limit=1001", "limit=ten", "after=nope"} {
+		c, w := ginCtx("GET", "/admin/tenants?"+query, nil, uuid.Nil, nil)
+		h.ListTenants(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestSuspendTenant(t *testing.T) {
+	id := uuid.New()
+	now := time.Now()
+	h := &Handler{queries: &stubQuerier{
+		suspendTenantFn: func(_ context.Context, got uuid.UUID) (store.Tenant, error) {
+			if got != id {
+				return store.Tenant{}, pgx.ErrNoRows
+			}
+			return store.Tenant{ID: id, SuspendedAt: &now}, nil
+		},
+	}}
+	c, w := ginCtx("POST", "/admin/tenants/"+id.String()+"/suspend", nil, uuid.Nil, gin.Params{{Key: "tenant_id", Value: id.String()}})
+	h.SuspendTenant(c)
+	var resp tenantResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Status != "suspended" {
+		t.Errorf("expected 200 with the tenant suspended, got %d: %s", w.Code, w.Body.String())
+	}
+
+	other := uuid.New().String()
+	c, w = ginCtx("POST", "/admin/tenants/"+other+"/suspend", nil, uuid.Nil, gin.Params{{Key: "tenant_id", Value: other}})
+	h.SuspendTenant(c)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown tenant, got %d", w.Code)
+	}
+
+	c, w = ginCtx("POST", "/admin/tenants/nope/suspend", nil, uuid.Nil, gin.Params{{Key: "tenant_id", Value: "nope"}})
+	h.SuspendTenant(c)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid id, got %d", w.Code)
+	}
+}
+
+func TestDeleteTenant(t *testing.T) {
+	cases := []struct {
+		name    string
+		deleted int64
+		exists  bool
+		want    int
+	}{
+		{"deleted", 1, true, http.StatusOK},
+		{"not suspended", 0, true, http.StatusConflict},
+		{"not found", 0, false, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		id := uuid.New()
+		h := &Handler{queries: &stubQuerier{
+			deleteTenantFn: func(_ context.Context, got uuid.UUID) (int64, error) {
+				if got != id {
+					t.Errorf("%s: unexpected id %s", tc.name, got)
+				}
+				return tc.deleted, nil
+			},
+			getTenantByIDFn: func(_ context.Context, id uuid.UUID) (store.Tenant, error) {
+				if !tc.exists {
+					return store.Tenant{}, pgx.ErrNoRows
+				}
+				return store.Tenant{ID: id}, nil
+			},
+		}}
+		c, w := ginCtx("DELETE", "/admin/tenants/"+id.String(), nil, uuid.Nil, gin.Params{{Key: "tenant_id", Value: id.String()}})
+		h.DeleteTenant(c)
+		if w.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d: %s", tc.name, tc.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+// --- API key tests ---
+
+func TestCreateAPIKey_StoresHashAndReturnsKeyOnce(t *testing.T) {
+	tenantID := uuid.New()
+	expires := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	var got store.CreateAPIKeyParams
+	h := &Handler{queries: &stubQuerier{
+		createAPIKeyFn: func(_ context.Context, arg store.CreateAPIKeyParams) (store.ApiKey, error) {
+			got = arg
+			return store.ApiKey{ID: uuid.New(), TenantID: arg.TenantID, Name: arg.Name, KeyHash: arg.KeyHash, ExpiresAt: arg.ExpiresAt}, nil
+		},
+	}}
+	body := []byte(`{"name": "billing-service", "scopes": ["email:send"], "expires_at": "` + expires.Format(time.RFC3339) + `"}`)
+	c, w := ginCtx("POST", "/api-keys", body, tenantID, nil)
+	h.CreateAPIKey(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp apiKeyResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Key == "" || resp.Name != "billing-service" || resp.Status != "active" {
+		t.Fatalf("unexpected response: %s", w.Body.String())
+	}
+	sum := sha256.Sum256([]byte(resp.Key))
+	if got.KeyHash != hex.EncodeToString(sum[:]) {
+		t.Errorf("expected the key's SHA-256 hash to be stored, got %q", got.KeyHash)
+	}
+	if got.TenantID != tenantID || got.ExpiresAt == nil || !got.ExpiresAt.Equal(expires) || len(got.Scopes) != 1 || got.Scopes[0] != "email:send" {
+		t.Errorf("unexpected params: %+v", got)
+	}
+}
+
+func TestCreateAPIKey_Invalid_Returns400(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{
+		createAPIKeyFn: func(_ context.Context, arg store.CreateAPIKeyParams) (store.ApiKey, error) {
+			t.Error("CreateAPIKey should not be called")
+			return store.ApiKey{}, nil
+		},
+	}}
+	cases := map[string]string{
+		"no name":       `{"scopes": ["*"]}`,
+		"long name":     `{"name": "` + strings.Repeat("k", 101) + `", "scopes": ["*"]}`,
+		"past expiry":   `{"name": "old", "scopes": ["*"], "expires_at": "2020-01-01T00:00:00Z"}`,
+		"no scopes":     `{"name": "none", "scopes": []}`,
+		"unknown scope": `{"name": "push", "scopes": ["push:send"]}`,
+	}
+	for name, body := range cases {
+		c, w := ginCtx("POST", "/api-keys", []byte(body), uuid.New(), nil)
+		h.CreateAPIKey(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestListAPIKeys_ReportsStatusWithoutHashes(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	q := &stubQuerier{
+		listAPIKeysFn: func(_ context.Context, _ uuid.UUID) ([]store.ApiKey, error) {
+			return []store.ApiKey{
+				{Name: "default", KeyHash: "secret-hash"},
+				{Name: "old", KeyHash: "secret-hash", ExpiresAt: &past},
+				{Name: "leaked", KeyHash: "secret-hash", RevokedAt: &past},
+			}, nil
+		},
+	}
+	h := &Handler{queries: q}
+	c, w := ginCtx("GET", "/api-keys", nil, uuid.New(), nil)
+	h.ListAPIKeys(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "secret-hash") {
+		t.Errorf("expected no key hashes in response, got %s", w.Body.String())
+	}
+	var resp []apiKeyResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp) != 3 {
+		t.Fatalf("expected 3 keys, got %d", len(resp))
+	}
+	for i, status := range []string{"active", "expired", "revoked"} {
+		if resp[i].Status != status || resp[i].Key != "" {
+			t.Errorf("key %s: expected status %q, got %+v", resp[i].Name, status, resp[i])
+		}
+	}
+}
+
+// scopedCtx is ginCtx for a request authenticated with a key holding only
+// scopes.
+func scopedCtx(method, path string, body []byte, scopes ...string) (*gin.Context, *httptest.ResponseRecorder) {
+	c, w := ginCtx(method, path, body, uuid.New(), nil)
+	c.Set("api_key", &store.ApiKey{Scopes: scopes})
+	return c, w
+}
+
+func TestCreateAPIKey_CannotGrantScopesItLacks(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{
+		createAPIKeyFn: func(_ context.Context, arg store.CreateAPIKeyParams) (store.ApiKey, error) {
+			t.Error("CreateAPIKey should not be called")
+			return store.ApiKey{}, nil
+		},
+	}}
+	for _, scopes := range []string{`["*"]`, `["email:send", "oauth:token:read"]`} {
+		c, w := scopedCtx("POST", "/api-keys", []byte(`{"name": "k", "scopes": `+scopes+`}`), tenant.ScopeKeysManage, tenant.ScopeEmailSend)
+		h.CreateAPIKey(c)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d: %s", scopes, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRequireScope_RejectsKeyWithoutScope(t *testing.T) {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("api_key", &store.ApiKey{Scopes: []string{tenant.ScopeEmailSend}})
+	})
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.POST("/email/:provider/send", tenant.RequireScope(tenant.ScopeEmailSend), ok)
+	r.GET("/oauth/:provider/token", tenant.RequireScope(tenant.ScopeOAuthTokenRead), ok)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/email/smtp/send", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 for a granted scope, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/oauth/google/token?user_id=u1", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", w.Code)
+	}
+	var resp struct {
+		Error        string `json:"error"`
+		MissingScope string `json:"missing_scope"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.MissingScope != tenant.ScopeOAuthTokenRead || !strings.Contains(resp.Error, tenant.ScopeOAuthTokenRead) {
+		t.Errorf("expected 403 naming %s, got %s", tenant.ScopeOAuthTokenRead, w.Body.String())
+	}
+}
+
+func TestCreateBatch_RequiresScopeOfEachJobType(t *testing.T) {
+	h := &Handler{queries: &stubQuerier{
+		createBatchFn: func(_ context.Context, arg store.CreateBatchParams) (store.Batch, error) {
+			return store.Batch{}, nil
+		},
+	}, tenantSvc: testTenants}
+	h.registerExecutors()
+
+	body := []byte(`{"items": [
+		{"job_type": "email.send", "payload": {"provider": "smtp", "to": ["a@example.com"], "from": "b@example.com", "subject": "Hi", "body": "Hello"}},
+		{"job_type": "code.execute", "payload": {"provider": "judge0", "source_code": "print(1)", "language_id": 71}}
+	]}`)
+	c, w := scopedCtx("POST", "/batches", body, tenant.ScopeEmailSend)
+	h.CreateBatch(c)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), tenant.ScopeCodeExecute) {
+		t.Errorf("expected 403 naming %s, got %d: %s", tenant.ScopeCodeExecute, w.Code, w.Body.String())
+	}
+
+	c, w = scopedCtx("POST", "/batches", body, tenant.ScopeEmailSend, tenant.ScopeCodeExecute)
+	h.CreateBatch(c)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("expected 202 with both scopes, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name    string
+		revoked int64
+		key     *store.ApiKey
+		want    int
+	}{
+		{"revoked", 1, nil, http.StatusOK},
+		{"already revoked", 0, &store.ApiKey{RevokedAt: &now}, http.StatusOK},
+		{"last active key", 0, &store.ApiKey{}, http.StatusConflict},
+		{"not found", 0, nil, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		tenantID := uuid.New()
+		id := uuid.New()
+		var got store.RevokeAPIKeyParams
+		q := &stubQuerier{
+			revokeAPIKeyFn: func(_ context.Context, arg store.RevokeAPIKeyParams) (int64, error) {
+				got = arg
+				return tc.revoked, nil
+			},
+		}
+		if tc.key != nil {
+			q.getAPIKeyFn = func(_ context.Context, _ store.GetAPIKeyParams) (store.ApiKey, error) {
+				return *tc.key, nil
+			}
+		}
+		h := &Handler{queries: q}
+		c, w := ginCtx("DELETE", "/api-keys/"+id.String(), nil, tenantID, gin.Params{{Key: "id", Value: id.String()}})
+		h.RevokeAPIKey(c)
+
+		if w.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d: %s", tc.name, tc.want, w.Code, w.Body.String())
+		}
+		if got.ID != id || got.TenantID != tenantID {
+			t.Errorf("%s: unexpected params: %+v", tc.name, got)
+		}
+	}
+}
+
+// --- Webhook tests ---
+
+func TestCreateWebhook_Validation(t *testing.T) {
+	cases := map[string]string{
+		"relative url":  `{"url": "/hooks", "events": ["job.completed"]}`,
+		"ftp url":       `{"url": "ftp://example.com/hooks", "events": ["job.completed"]}`,
+		"no events":     `{"url": "https://example.com/hooks", "events": []}`,
+		"unknown event": `{"url": "https://example.com/hooks", "events": ["job.started"]}`,
+		"loopback url":  `{"url": "http://127.0.0.1:8080/hooks", "events": ["job.completed"]}`,
+		"metadata url":  `{"url": "http://169.254.169.254/latest/meta-data", "events": ["job.completed"]}`,
+		"localhost url": `{"url": "http://localhost/hooks", "events": ["job.completed"]}`,
+	}
+	for name, body := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{queries: &stubQuerier{}}
+			c, w := ginCtx("POST", "/webhooks", []byte(body), uuid.New(), nil)
+			h.CreateWebhook(c)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWebhookExecutor_SignsAndRecordsDelivery(t *testing.T) {
+	enc, err := crypto.NewEncryptor(strings.Repeat("ab", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataKey, encDataKey, _ := enc.GenerateDataKey()
+	encSecret, _ := crypto.EncryptWithDataKey(dataKey, []byte("whsec_test"))
+
+	var gotSig, gotEvent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig = r.Header.Get(webhook.SignatureHeader)
+		gotEvent = r.Header.Get(webhook.EventHeader)
+	}))
+	defer srv.Close()
+
+	tn := &store.Tenant{ID: uuid.New(), EncryptedDataKey: encDataKey}
+	delivery := store.WebhookDelivery{
+		ID:         uuid.New(),
+		EndpointID: uuid.New(),
+		TenantID:   tn.ID,
+		Event:      "job.completed",
+		Data:       []byte(`{"status":"completed"}`),
+	}
+	var recorded store.RecordWebhookAttemptParams
+	q := &stubQuerier{
+		getDeliveryFn: func(_ context.Context, arg store.GetWebhookDeliveryParams) (store.WebhookDelivery, error) {
+			if arg.ID != delivery.ID || arg.TenantID != tn.ID {
+				t.Errorf("unexpected delivery lookup: %+v", arg)
+			}
+			return delivery, nil
+		},
+		getWebhookFn: func(_ context.Context, arg store.GetWebhookEndpointParams) (store.WebhookEndpoint, error) {
+			return store.WebhookEndpoint{ID: arg.ID, URL: srv.URL, EncryptedSecret: encSecret}, nil
+		},
+		recordAttemptFn: func(_ context.Context, arg store.RecordWebhookAttemptParams) (store.WebhookDelivery, error) {
+			recorded = arg
+			return store.WebhookDelivery{}, nil
+		},
+	}
+	h := &Handler{queries: q, tenantSvc: tenant.NewService(nil, enc), webhooks: webhook.NewSender(webhook.WithPrivateNetworks())}
+
+	payload, _ := json.Marshal(map[string]any{"delivery_id": delivery.ID})
+	if err := (&webhookExecutor{h}).Execute(context.Background(), uuid.New(), tn, payload); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if gotEvent != "job.completed" || !strings.HasPrefix(gotSig, "t=") || !strings.Contains(gotSig, ",v1=") {
+		t.Errorf("unexpected delivery headers: event=%q signature=%q", gotEvent, gotSig)
+	}
+	if recorded.ID != delivery.ID || !recorded.Succeeded || recorded.ResponseStatus.Int32 != http.StatusOK {
+		t.Errorf("unexpected recorded attempt: %+v", recorded)
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/gsarma/tusker/internal/store"
+	"github.com/gsarma/tusker/internal/tenant"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	// idempotencyTTL is how long a key's stored response is replayed.
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyLockTimeout is how long a key stays locked by a request that
+	// never completed (e.g. the server crashed mid-request) before it may be
+	// reused.
+	idempotencyLockTimeout = time.Minute
+	maxIdempotencyKeyLen   = 255
+)
+
+// Idempotent makes the wrapped send, execute or workflow endpoint safe to
+// retry. When the request carries an Idempotency-Key header, the first
+// successful (2xx) response is stored for the tenant under that key for
+// idempotencyTTL and replayed verbatim, with an Idempotent-Replayed header, to
+// later requests with the same key, so a retried send returns the original
+// job_id instead of enqueueing again. Reusing a key with a different request returns 422, and a
+// retry that arrives while the original is still being handled returns 409.
+// Failed requests release the key so they can be retried.
+//
+// Requests without the header are passed through unchanged. It must run after
+// the tenant auth middleware.
+func (h *Handler) Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+		t := tenant.FromContext(c)
+		ctx := c.Request.Context()
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := requestHash(c.Request, body)
+
+		_, err = h.queries.ReserveIdempotencyKey(ctx, store.ReserveIdempotencyKeyParams{
+			TenantID:    t.ID,
+			Key:         key,
+			RequestHash: hash,
+			TTLSeconds:  int32(idempotencyTTL / time.Second),
+			LockSeconds: int32(idempotencyLockTimeout / time.Second),
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			h.replayIdempotent(c, t, key, hash)
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check idempotency key"})
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		// Store the outcome even if the client has gone away.
+		ctx = context.WithoutCancel(ctx)
+		if status := w.Status(); status >= 200 && status < 300 {
+			err = h.queries.CompleteIdempotencyKey(ctx, store.CompleteIdempotencyKeyParams{
+				TenantID:   t.ID,
+				Key:        key,
+				StatusCode: pgtype.Int4{Int32: int32(status), Valid: true},
+				Response:   w.body.Bytes(),
+			})
+		} else {
+			err = h.queries.ReleaseIdempotencyKey(ctx, store.ReleaseIdempotencyKeyParams{TenantID: t.ID, Key: key})
+		}
+		if err != nil {
+			log.Printf("idempotency: failed to record key %q for tenant %s: %v", key, t.ID, err)
+		}
+	}
+}
+
+// replayIdempotent answers a request whose key is already held.
+func (h *Handler) replayIdempotent(c *gin.Context, t *store.Tenant, key string, hash []byte) {
+	prev, err := h.queries.GetIdempotencyKey(c.Request.Context(), store.GetIdempotencyKeyParams{TenantID: t.ID, Key: key})
+	if err != nil {
+		// Released or expired between the reserve attempt and now.
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "request with this Idempotency-Key is in progress; retry shortly"})
+		return
+	}
+	if !bytes.Equal(prev.RequestHash, hash) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+		return
+	}
+	if !prev.StatusCode.Valid {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "request with this Idempotency-Key is in progress; retry shortly"})
+		return
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(int(prev.StatusCode.Int32), "application/json; charset=utf-8", prev.Response)
+	c.Abort()
+}
+
+// requestHash fingerprints the parts of a request that determine its effect:
+// method, path, query string and body.
+func requestHash(r *http.Request, body []byte) []byte {
+	sum := sha256.New()
+	io.WriteString(sum, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery+"\n")
+	sum.Write(body)
+	return sum.Sum(nil)
+}
+
+// recordingWriter keeps a copy of the response body so it can be stored.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
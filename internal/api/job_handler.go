@@ -0,0 +1,517 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/gsarma/tusker/internal/store"
+	"github.com/gsarma/tusker/internal/tenant"
+	"github.com/gsarma/tusker/internal/workflow"
+)
+
+// maxScheduleHorizon bounds how far in the future a job may be scheduled with send_at.
+const maxScheduleHorizon = 30 * 24 * time.Hour
+
+const (
+	defaultQueue   = "default"
+	maxJobPriority = 100
+)
+
+var queueNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// jobOptions holds the request body fields shared by every endpoint that queues
+// a job. Embed it in the endpoint's body struct and pass it to enqueueJob.
+type jobOptions struct {
+	// SendAt schedules the job for a future time (RFC3339). Omit to run as soon as possible.
+	SendAt *time.Time `json:"send_at"`
+	// Retry overrides the job type's default retry policy.
+	Retry *retryOverrides `json:"retry"`
+	// Queue routes the job to a named queue, so it can be served by dedicated
+	// workers. Defaults to "default".
+	Queue string `json:"queue"`
+	// Priority orders jobs within a tenant's queue: higher runs first. 0–100,
+	// default 0.
+	Priority *int32 `json:"priority"`
+}
+
+// validate checks the options against the request mode (?sync=true runs inline,
+// so there is nothing to schedule).
+func (o jobOptions) validate(sync bool) error {
+	if o.Retry != nil && sync {
+		return fmt.Errorf("retry cannot be combined with sync=true")
+	}
+	if o.Queue != "" {
+		if sync {
+			return fmt.Errorf("queue cannot be combined with sync=true")
+		}
+		if !queueNamePattern.MatchString(o.Queue) {
+			return fmt.Errorf("queue must be 1-64 lowercase letters, digits, '_' or '-'")
+		}
+	}
+	if o.Priority != nil {
+		if sync {
+			return fmt.Errorf("priority cannot be combined with sync=true")
+		}
+		if *o.Priority < 0 || *o.Priority > maxJobPriority {
+			return fmt.Errorf("priority must be between 0 and %d", maxJobPriority)
+		}
+	}
+	if o.SendAt == nil {
+		return nil
+	}
+	if sync {
+		return fmt.Errorf("send_at cannot be combined with sync=true")
+	}
+	if time.Until(*o.SendAt) > maxScheduleHorizon {
+		return fmt.Errorf("send_at must be within %d days", int(maxScheduleHorizon.Hours()/24))
+	}
+	return nil
+}
+
+// set reports whether any option was given.
+func (o jobOptions) set() bool {
+	return o.SendAt != nil || o.Retry != nil || o.Queue != "" || o.Priority != nil
+}
+
+func (o jobOptions) queue() string {
+	if o.Queue == "" {
+		return defaultQueue
+	}
+	return o.Queue
+}
+
+func (o jobOptions) priority() int32 {
+	if o.Priority == nil {
+		return 0
+	}
+	return *o.Priority
+}
+
+// enqueueJob queues a job of jobType, calling provider, for the tenant and
+// writes the 202 response. The payload is stored sealed with the tenant's data
+// key; ExecuteJob opens it.
+func (h *Handler) enqueueJob(c *gin.Context, t *store.Tenant, jobType, provider string, payload any, opts jobOptions) {
+	policy, err := retryPolicyFor(jobType, opts.Retry)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode job payload"})
+		return
+	}
+	sealed, err := h.sealPayload(t, payloadJSON)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption error"})
+		return
+	}
+
+	job, err := h.queries.CreateJob(c.Request.Context(), store.CreateJobParams{
+		TenantID:           t.ID,
+		JobType:            jobType,
+		Payload:            sealed,
+		Provider:           provider,
+		Queue:              opts.queue(),
+		Priority:           opts.priority(),
+		MaxAttempts:        policy.MaxAttempts,
+		BackoffBaseSeconds: int32(policy.BaseDelay / time.Second),
+		BackoffMaxSeconds:  int32(policy.MaxDelay / time.Second),
+		BackoffJitter:      policy.Jitter,
+		RunAt:              opts.SendAt,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue job"})
+		return
+	}
+
+	resp := gin.H{"job_id": job.ID, "status": "queued"}
+	if opts.SendAt != nil {
+		resp["run_at"] = job.RunAt
+	}
+	c.JSON(http.StatusAccepted, resp)
+}
+
+// CancelJob cancels a queued job.
+//
+// A pending or scheduled job is moved to cancelled atomically, so no worker can
+// claim it afterwards (200). A running job whose executor is Cancellable is
+// flagged for cancellation; its worker cancels the job's context on the next
+// heartbeat (202). Running jobs of other types, and jobs that have already
+// finished, cannot be cancelled (409).
+func (h *Handler) CancelJob(c *gin.Context) {
+	t := tenant.FromContext(c)
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	job, err := h.queries.CancelJob(ctx, store.CancelJobParams{ID: jobID, TenantID: t.ID})
+	if err == nil {
+		if job.WorkflowID.Valid {
+			// Skip the steps that depended on this one.
+			tx := h.workflowTx
+			if tx == nil {
+				tx = workflow.Direct(h.queries)
+			}
+			if err := workflow.Advance(context.WithoutCancel(ctx), tx, h, t.ID, job.ID); err != nil {
+				log.Printf("cancel: advance workflow error for job %s: %v", job.ID, err)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"job_id": job.ID, "status": job.Status})
+		return
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel job"})
+		return
+	}
+
+	// Not pending: find out why.
+	job, err = h.queries.GetJob(ctx, store.GetJobParams{ID: jobID, TenantID: t.ID})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	if job.Status != "running" {
+		c.JSON(http.StatusConflict, gin.H{"error": "job is already " + job.Status, "status": job.Status})
+		return
+	}
+	if ce, ok := h.executors[job.JobType].(Cancellable); !ok || !ce.Cancellable() {
+		c.JSON(http.StatusConflict, gin.H{"error": "running " + job.JobType + " jobs cannot be cancelled", "status": job.Status})
+		return
+	}
+
+	job, err = h.queries.RequestJobCancel(ctx, store.RequestJobCancelParams{ID: jobID, TenantID: t.ID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusConflict, gin.H{"error": "job finished before it could be cancelled"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel job"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"job_id": job.ID, "status": "cancelling"})
+}
+
+const (
+	defaultJobPageSize = 50
+	maxJobPageSize     = 200
+)
+
+// jobStatuses are the values accepted by the status filter on GET /jobs.
+var jobStatuses = map[string]bool{
+	"pending": true, "running": true, "completed": true, "failed": true, "cancelled": true,
+}
+
+// ListJobs returns the tenant's jobs, newest first, with per-status counts.
+//
+// Query parameters (all optional):
+//
+//	status          pending|running|completed|failed|cancelled
+//	job_type        e.g. email.send
+//	created_after   RFC3339, inclusive
+//	created_before  RFC3339, exclusive
+//	limit           page size, default 50, max 200
+//	cursor          next_cursor from the previous page
+//
+// counts honours the job_type and created-at filters but not status, so it
+// always covers every status.
+func (h *Handler) ListJobs(c *gin.Context) {
+	t := tenant.FromContext(c)
+
+	params, ok := parseJobListParams(c, t)
+	if !ok {
+		return
+	}
+	if v := c.Query("status"); v != "" {
+		if !jobStatuses[v] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status: " + v})
+			return
+		}
+		params.Status = pgtype.Text{String: v, Valid: true}
+	}
+
+	ctx := c.Request.Context()
+	jobs, err := h.queries.ListJobs(ctx, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list jobs"})
+		return
+	}
+	rows, err := h.queries.CountJobsByStatus(ctx, store.CountJobsByStatusParams{
+		TenantID:      t.ID,
+		JobType:       params.JobType,
+		CreatedAfter:  params.CreatedAfter,
+		CreatedBefore: params.CreatedBefore,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count jobs"})
+		return
+	}
+
+	counts := make(map[string]int64, len(jobStatuses))
+	for status := range jobStatuses {
+		counts[status] = 0
+	}
+	for _, r := range rows {
+		counts[r.Status] = r.Count
+	}
+
+	resp := gin.H{"jobs": jobs, "counts": counts}
+	if jobs == nil {
+		resp["jobs"] = []store.Job{}
+	}
+	if cursor := nextJobCursor(jobs, params.PageSize); cursor != "" {
+		resp["next_cursor"] = cursor
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// parseJobListParams reads the job_type, created_after, created_before, limit
+// and cursor query parameters shared by the job listing endpoints, writing a
+// 400 and returning false if any is invalid.
+func parseJobListParams(c *gin.Context, t *store.Tenant) (store.ListJobsParams, bool) {
+	params := store.ListJobsParams{TenantID: t.ID, PageSize: defaultJobPageSize}
+	if v := c.Query("job_type"); v != "" {
+		params.JobType = pgtype.Text{String: v, Valid: true}
+	}
+	var err error
+	if params.CreatedAfter, err = queryTime(c, "created_after"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return params, false
+	}
+	if params.CreatedBefore, err = queryTime(c, "created_before"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return params, false
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxJobPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxJobPageSize)})
+			return params, false
+		}
+		params.PageSize = int32(n)
+	}
+	if v := c.Query("cursor"); v != "" {
+		createdAt, id, err := decodeJobCursor(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return params, false
+		}
+		params.CursorCreatedAt = &createdAt
+		params.CursorID = id
+	}
+	return params, true
+}
+
+// nextJobCursor returns the cursor for the page after jobs, or "" if jobs is
+// the last page.
+func nextJobCursor(jobs []store.Job, pageSize int32) string {
+	if len(jobs) < int(pageSize) || len(jobs) == 0 {
+		return ""
+	}
+	last := jobs[len(jobs)-1]
+	return encodeJobCursor(last.CreatedAt, last.ID)
+}
+
+// queryTime parses an optional RFC3339 query parameter.
+func queryTime(c *gin.Context, name string) (*time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	ts, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC3339 timestamp", name)
+	}
+	return &ts, nil
+}
+
+// encodeJobCursor packs the keyset position of a job into an opaque token.
+func encodeJobCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "," + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeJobCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	tsPart, idPart, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+	ts, err := time.Parse(time.RFC3339Nano, tsPart)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	return ts, id, nil
+}
+
+// replayBatchSize caps how many jobs one bulk replay request re-enqueues.
+const replayBatchSize = 1000
+
+// jobDetail is a job together with its attempt timeline, oldest first.
+type jobDetail struct {
+	store.Job
+	Attempts []store.JobAttempt `json:"attempts"`
+}
+
+// withAttempts loads the attempt history of jobs in one query.
+func (h *Handler) withAttempts(ctx context.Context, jobs []store.Job) ([]jobDetail, error) {
+	ids := make([]uuid.UUID, len(jobs))
+	for i, j := range jobs {
+		ids[i] = j.ID
+	}
+	attempts, err := h.queries.ListJobAttempts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byJob := make(map[uuid.UUID][]store.JobAttempt, len(jobs))
+	for _, a := range attempts {
+		byJob[a.JobID] = append(byJob[a.JobID], a)
+	}
+
+	result := make([]jobDetail, len(jobs))
+	for i, j := range jobs {
+		result[i] = jobDetail{Job: j, Attempts: byJob[j.ID]}
+		if result[i].Attempts == nil {
+			result[i].Attempts = []store.JobAttempt{}
+		}
+	}
+	return result, nil
+}
+
+// ListDeadLetterJobs returns the dead-letter queue: jobs that exhausted their
+// attempts and are now failed, newest first, each with its attempt timeline.
+// It accepts the same job_type, created_after, created_before, limit and cursor
+// parameters as ListJobs.
+func (h *Handler) ListDeadLetterJobs(c *gin.Context) {
+	t := tenant.FromContext(c)
+
+	params, ok := parseJobListParams(c, t)
+	if !ok {
+		return
+	}
+	params.Status = pgtype.Text{String: "failed", Valid: true}
+
+	ctx := c.Request.Context()
+	jobs, err := h.queries.ListJobs(ctx, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list jobs"})
+		return
+	}
+
+	result, err := h.withAttempts(ctx, jobs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load attempt history"})
+		return
+	}
+
+	resp := gin.H{"jobs": result}
+	if cursor := nextJobCursor(jobs, params.PageSize); cursor != "" {
+		resp["next_cursor"] = cursor
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RetryJob re-enqueues a failed job with its attempt count reset, so it gets a
+// full set of retries again. Only failed jobs can be retried (409 otherwise),
+// and not those of workflow steps: their workflow has already acted on the
+// failure, so create the workflow again instead.
+func (h *Handler) RetryJob(c *gin.Context) {
+	t := tenant.FromContext(c)
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	job, err := h.queries.RetryJob(ctx, store.RetryJobParams{ID: jobID, TenantID: t.ID})
+	if err == nil {
+		c.JSON(http.StatusAccepted, gin.H{"job_id": job.ID, "status": "queued"})
+		return
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retry job"})
+		return
+	}
+
+	job, err = h.queries.GetJob(ctx, store.GetJobParams{ID: jobID, TenantID: t.ID})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	if job.Status == "failed" && job.WorkflowID.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "workflow step jobs cannot be retried; create the workflow again", "status": job.Status})
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "only failed jobs can be retried; job is " + job.Status, "status": job.Status})
+}
+
+// ReplayDeadLetterJobs re-enqueues failed jobs in bulk, e.g. after a provider
+// outage. created_after and created_before bound the window of job creation
+// times and are required; job_type is optional. At most 1000 jobs are replayed
+// per request: when "more" is true, repeat the request to replay the rest.
+//
+// Request body:
+//
+//	{
+//	  "job_type":       "email.send",
+//	  "created_after":  "2025-06-01T09:00:00Z",
+//	  "created_before": "2025-06-01T11:00:00Z"
+//	}
+func (h *Handler) ReplayDeadLetterJobs(c *gin.Context) {
+	t := tenant.FromContext(c)
+
+	var body struct {
+		JobType       string     `json:"job_type"`
+		CreatedAfter  *time.Time `json:"created_after" binding:"required"`
+		CreatedBefore *time.Time `json:"created_before" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !body.CreatedBefore.After(*body.CreatedAfter) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "created_before must be after created_after"})
+		return
+	}
+
+	params := store.ReplayFailedJobsParams{
+		TenantID:      t.ID,
+		CreatedAfter:  *body.CreatedAfter,
+		CreatedBefore: *body.CreatedBefore,
+		MaxJobs:       replayBatchSize,
+	}
+	if body.JobType != "" {
+		params.JobType = pgtype.Text{String: body.JobType, Valid: true}
+	}
+	n, err := h.queries.ReplayFailedJobs(c.Request.Context(), params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to replay jobs"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"replayed": n, "more": n == replayBatchSize})
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/gsarma/tusker/internal/crypto"
+	"github.com/gsarma/tusker/internal/store"
+)
+
+// sealedPayload is the stored form of a job, schedule or workflow step
+// payload: the payload encrypted with the tenant's data key, alongside the
+// provider, the only field left in the clear so that it stays queryable.
+//
+// Payloads written before encryption was introduced are plain JSON objects
+// without a ciphertext field; openPayload returns them unchanged, and
+// EncryptPayloads seals them. webhook.deliver jobs, which only carry a
+// delivery id, are queued by the database and never sealed.
+type sealedPayload struct {
+	Provider   string `json:"provider,omitempty"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// sealPayload encrypts payload with the tenant's data key.
+func (h *Handler) sealPayload(t *store.Tenant, payload []byte) ([]byte, error) {
+	dataKey, err := h.tenantSvc.DataKey(t)
+	if err != nil {
+		return nil, err
+	}
+	return sealWithDataKey(dataKey, payload)
+}
+
+func sealWithDataKey(dataKey, payload []byte) ([]byte, error) {
+	var meta struct {
+		Provider string `json:"provider"`
+	}
+	json.Unmarshal(payload, &meta) // best effort: the provider is only metadata
+	ciphertext, err := crypto.EncryptWithDataKey(dataKey, payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealedPayload{Provider: meta.Provider, Ciphertext: ciphertext})
+}
+
+// openPayload returns the plaintext of a stored payload, decrypting it with
+// the tenant's data key if it is sealed.
+func (h *Handler) openPayload(t *store.Tenant, stored []byte) ([]byte, error) {
+	var sealed sealedPayload
+	if json.Unmarshal(stored, &sealed) != nil || sealed.Ciphertext == nil {
+		return stored, nil
+	}
+	dataKey, err := h.tenantSvc.DataKey(t)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.DecryptWithDataKey(dataKey, sealed.Ciphertext)
+}
+
+// SealPayload implements workflow.Codec, sealing the payloads of workflow
+// steps the worker queues.
+func (h *Handler) SealPayload(ctx context.Context, tenantID uuid.UUID, payload []byte) ([]byte, error) {
+	t, err := h.queries.GetTenantByID(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("tenant not found: %w", err)
+	}
+	return h.sealPayload(&t, payload)
+}
+
+// OpenPayload implements workflow.Codec.
+func (h *Handler) OpenPayload(ctx context.Context, tenantID uuid.UUID, payload []byte) ([]byte, error) {
+	t, err := h.queries.GetTenantByID(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("tenant not found: %w", err)
+	}
+	return h.openPayload(&t, payload)
+}
+
+// payloadBatchSize is how many rows EncryptPayloads reads at a time.
+const payloadBatchSize = 500
+
+// EncryptPayloads seals the job, schedule and workflow step payloads still
+// stored in plaintext, in batches, returning how many it sealed. It is safe
+// to run while the API and workers are serving traffic, and to re-run after
+// an interruption.
+func (h *Handler) EncryptPayloads(ctx context.Context) (int, error) {
+	keys := map[uuid.UUID][]byte{}
+	dataKey := func(tenantID uuid.UUID) ([]byte, error) {
+		if k, ok := keys[tenantID]; ok {
+			return k, nil
+		}
+		t, err := h.queries.GetTenantByID(ctx, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenantID, err)
+		}
+		k, err := h.tenantSvc.DataKey(&t)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: data key: %w", tenantID, err)
+		}
+		keys[tenantID] = k
+		return k, nil
+	}
+	seal := func(tenantID uuid.UUID, payload []byte) ([]byte, error) {
+		k, err := dataKey(tenantID)
+		if err != nil {
+			return nil, err
+		}
+		return sealWithDataKey(k, payload)
+	}
+
+	total := 0
+	for {
+		rows, err := h.queries.ListPlaintextJobPayloads(ctx, payloadBatchSize)
+		if err != nil {
+			return total, fmt.Errorf("list jobs: %w", err)
+		}
+		for _, r := range rows {
+			sealed, err := seal(r.TenantID, r.Payload)
+			if err != nil {
+				return total, err
+			}
+			n, err := h.queries.SealJobPayload(ctx, store.SealJobPayloadParams{ID: r.ID, Payload: sealed})
+			if err != nil {
+				return total, fmt.Errorf("seal job %s: %w", r.ID, err)
+			}
+			total += int(n)
+		}
+		if len(rows) < payloadBatchSize {
+			break
+		}
+	}
+
+	for {
+		rows, err := h.queries.ListPlaintextSchedulePayloads(ctx, payloadBatchSize)
+		if err != nil {
+			return total, fmt.Errorf("list schedules: %w", err)
+		}
+		for _, r := range rows {
+			sealed, err := seal(r.TenantID, r.Payload)
+			if err != nil {
+				return total, err
+			}
+			n, err := h.queries.SealSchedulePayload(ctx, store.SealSchedulePayloadParams{ID: r.ID, Payload: sealed})
+			if err != nil {
+				return total, fmt.Errorf("seal schedule %s: %w", r.ID, err)
+			}
+			total += int(n)
+		}
+		if len(rows) < payloadBatchSize {
+			break
+		}
+	}
+
+	for {
+		rows, err := h.queries.ListPlaintextWorkflowStepPayloads(ctx, payloadBatchSize)
+		if err != nil {
+			return total, fmt.Errorf("list workflow steps: %w", err)
+		}
+		for _, r := range rows {
+			sealed, err := seal(r.TenantID, r.Payload)
+			if err != nil {
+				return total, err
+			}
+			n, err := h.queries.SealWorkflowStepPayload(ctx, store.SealWorkflowStepPayloadParams{
+				WorkflowID: r.WorkflowID,
+				Name:       r.Name,
+				Payload:    sealed,
+			})
+			if err != nil {
+				return total, fmt.Errorf("seal step %q of workflow %s: %w", r.Name, r.WorkflowID, err)
+			}
+			total += int(n)
+		}
+		if len(rows) < payloadBatchSize {
+			break
+		}
+	}
+	return total, nil
+}
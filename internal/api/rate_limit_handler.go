@@ -0,0 +1,125 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gsarma/tusker/internal/store"
+	"github.com/gsarma/tusker/internal/tenant"
+)
+
+// rateLimitedProviders are the providers jobs can call, and so the ones a
+// rate limit may be set for.
+var rateLimitedProviders = map[string]bool{
+	"smtp":     true,
+	"sendgrid": true,
+	"twilio":   true,
+	"judge0":   true,
+}
+
+const maxRateLimitBurst = 10000
+
+type rateLimitResponse struct {
+	Provider      string    `json:"provider"`
+	RatePerSecond float64   `json:"rate_per_second"`
+	Burst         int32     `json:"burst"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func toRateLimitResponse(l store.ProviderRateLimit) rateLimitResponse {
+	return rateLimitResponse{
+		Provider:      l.Provider,
+		RatePerSecond: l.RatePerSecond,
+		Burst:         l.Burst,
+		UpdatedAt:     l.UpdatedAt,
+	}
+}
+
+// SetRateLimit sets a token-bucket limit on how fast the tenant's jobs call a
+// provider, shared by every worker: up to burst jobs may start back to back,
+// after which jobs start at rate_per_second. Jobs over the limit wait in the
+// queue without using up an attempt.
+//
+// Request body:
+//
+//	{ "rate_per_second": 10, "burst": 20 }
+//
+// burst defaults to rate_per_second rounded up.
+func (h *Handler) SetRateLimit(c *gin.Context) {
+	t := tenant.FromContext(c)
+	provider := c.Param("provider")
+	if !rateLimitedProviders[provider] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported provider: " + provider})
+		return
+	}
+
+	var body struct {
+		RatePerSecond float64 `json:"rate_per_second" binding:"required"`
+		Burst         int32   `json:"burst"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if body.RatePerSecond <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rate_per_second must be positive"})
+		return
+	}
+	if body.Burst == 0 {
+		body.Burst = int32(min(maxRateLimitBurst, math.Ceil(body.RatePerSecond)))
+	}
+	if body.Burst < 1 || body.Burst > maxRateLimitBurst {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "burst must be between 1 and 10000"})
+		return
+	}
+
+	l, err := h.queries.UpsertProviderRateLimit(c.Request.Context(), store.UpsertProviderRateLimitParams{
+		TenantID:      t.ID,
+		Provider:      provider,
+		RatePerSecond: body.RatePerSecond,
+		Burst:         body.Burst,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save rate limit"})
+		return
+	}
+	c.JSON(http.StatusOK, toRateLimitResponse(l))
+}
+
+// ListRateLimits returns the tenant's provider rate limits.
+func (h *Handler) ListRateLimits(c *gin.Context) {
+	t := tenant.FromContext(c)
+
+	rows, err := h.queries.ListProviderRateLimits(c.Request.Context(), t.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list rate limits"})
+		return
+	}
+	result := make([]rateLimitResponse, 0, len(rows))
+	for _, l := range rows {
+		result = append(result, toRateLimitResponse(l))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteRateLimit removes the tenant's limit for a provider.
+func (h *Handler) DeleteRateLimit(c *gin.Context) {
+	t := tenant.FromContext(c)
+
+	n, err := h.queries.DeleteProviderRateLimit(c.Request.Context(), store.DeleteProviderRateLimitParams{
+		TenantID: t.ID,
+		Provider: c.Param("provider"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete rate limit"})
+		return
+	}
+	if n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "rate limit not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/gsarma/tusker/internal/store"
+	"github.com/gsarma/tusker/internal/tenant"
+)
+
+const maxRetentionDays = 3650
+
+// retentionResponse is the tenant's retention window; Days is null when the
+// server default (JOB_RETENTION_DAYS) applies.
+type retentionResponse struct {
+	Days *int32 `json:"days"`
+}
+
+func toRetentionResponse(t *store.Tenant) retentionResponse {
+	if !t.JobRetentionDays.Valid {
+		return retentionResponse{}
+	}
+	return retentionResponse{Days: &t.JobRetentionDays.Int32}
+}
+
+// GetRetention returns how many days the tenant's finished jobs are kept.
+func (h *Handler) GetRetention(c *gin.Context) {
+	c.JSON(http.StatusOK, toRetentionResponse(tenant.FromContext(c)))
+}
+
+// SetRetention sets how many days the tenant's completed, failed and cancelled
+// jobs are kept before the worker purges them, with their attempts, code
+// execution results and webhook deliveries. Finished workflows are purged on
+// the same schedule.
+//
+// Request body:
+//
+//	{ "days": 30 }
+func (h *Handler) SetRetention(c *gin.Context) {
+	t := tenant.FromContext(c)
+
+	var body struct {
+		Days int32 `json:"days" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if body.Days < 1 || body.Days > maxRetentionDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days must be between 1 and %d", maxRetentionDays)})
+		return
+	}
+	h.setRetention(c, t, pgtype.Int4{Int32: body.Days, Valid: true})
+}
+
+// DeleteRetention reverts the tenant to the server's default retention window.
+func (h *Handler) DeleteRetention(c *gin.Context) {
+	h.setRetention(c, tenant.FromContext(c), pgtype.Int4{})
+}
+
+func (h *Handler) setRetention(c *gin.Context, t *store.Tenant, days pgtype.Int4) {
+	updated, err := h.queries.SetTenantJobRetention(c.Request.Context(), store.SetTenantJobRetentionParams{
+		ID:               t.ID,
+		JobRetentionDays: days,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update retention"})
+		return
+	}
+	c.JSON(http.StatusOK, toRetentionResponse(&updated))
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// retryPolicy controls how a failed job is retried: up to MaxAttempts attempts
+// in total, the n-th retry waiting BaseDelay * 2^n capped at MaxDelay, minus a
+// random fraction of up to Jitter of that delay.
+type retryPolicy struct {
+	MaxAttempts int32
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+}
+
+// defaultRetryPolicy applies to job types without an entry in retryDefaults.
+var defaultRetryPolicy = retryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   10 * time.Second,
+	MaxDelay:    time.Hour,
+}
+
+// retryDefaults holds per-job-type retry policies. SMS is usually time
+// sensitive (e.g. one-time passcodes), so it retries quickly and gives up
+// early; email can usefully keep trying through a provider outage.
+var retryDefaults = map[string]retryPolicy{
+	"sms.send": {
+		MaxAttempts: 3,
+		BaseDelay:   2 * time.Second,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.2,
+	},
+	"email.send": {
+		MaxAttempts: 8,
+		BaseDelay:   30 * time.Second,
+		MaxDelay:    time.Hour,
+		Jitter:      0.2,
+	},
+	"email.send_template": {
+		MaxAttempts: 8,
+		BaseDelay:   30 * time.Second,
+		MaxDelay:    time.Hour,
+		Jitter:      0.2,
+	},
+}
+
+// Bounds for client-supplied retry overrides.
+const (
+	maxRetryAttempts  = 25
+	maxRetryBaseDelay = time.Hour
+	maxRetryMaxDelay  = 24 * time.Hour
+)
+
+// retryOverrides is the optional "retry" object accepted by every endpoint that
+// queues a job. Omitted fields keep the job type's default.
+type retryOverrides struct {
+	MaxAttempts      *int32   `json:"max_attempts"`
+	BaseDelaySeconds *int32   `json:"base_delay_seconds"`
+	MaxDelaySeconds  *int32   `json:"max_delay_seconds"`
+	Jitter           *float64 `json:"jitter"`
+}
+
+// retryPolicyFor returns jobType's default policy with o applied, or an error
+// describing the first out-of-range field. o may be nil.
+func retryPolicyFor(jobType string, o *retryOverrides) (retryPolicy, error) {
+	p, ok := retryDefaults[jobType]
+	if !ok {
+		p = defaultRetryPolicy
+	}
+	if o == nil {
+		return p, nil
+	}
+	if o.MaxAttempts != nil {
+		if *o.MaxAttempts < 1 || *o.MaxAttempts > maxRetryAttempts {
+			return p, fmt.Errorf("retry.max_attempts must be between 1 and %d", maxRetryAttempts)
+		}
+		p.MaxAttempts = *o.MaxAttempts
+	}
+	if o.BaseDelaySeconds != nil {
+		d := time.Duration(*o.BaseDelaySeconds) * time.Second
+		if d < time.Second || d > maxRetryBaseDelay {
+			return p, fmt.Errorf("retry.base_delay_seconds must be between 1 and %d", int(maxRetryBaseDelay.Seconds()))
+		}
+		p.BaseDelay = d
+	}
+	if o.MaxDelaySeconds != nil {
+		d := time.Duration(*o.MaxDelaySeconds) * time.Second
+		if d < time.Second || d > maxRetryMaxDelay {
+			return p, fmt.Errorf("retry.max_delay_seconds must be between 1 and %d", int(maxRetryMaxDelay.Seconds()))
+		}
+		p.MaxDelay = d
+	}
+	if o.Jitter != nil {
+		if *o.Jitter < 0 || *o.Jitter > 1 {
+			return p, fmt.Errorf("retry.jitter must be between 0 and 1")
+		}
+		p.Jitter = *o.Jitter
+	}
+	if p.MaxDelay < p.BaseDelay {
+		return p, fmt.Errorf("retry.max_delay_seconds must not be less than the base delay (%ds)", int(p.BaseDelay.Seconds()))
+	}
+	return p, nil
+}
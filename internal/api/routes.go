@@ -7,9 +7,13 @@ import (
 	"github.com/gsarma/tusker/internal/crypto"
 	"github.com/gsarma/tusker/internal/store"
 	"github.com/gsarma/tusker/internal/tenant"
+	"github.com/gsarma/tusker/internal/webhook"
+	"github.com/gsarma/tusker/internal/workflow"
 )
 
-func RegisterRoutes(r *gin.Engine, db *pgxpool.Pool, enc *crypto.Encryptor) *Handler {
+// RegisterRoutes mounts the API on r. The /admin routes, tenant provisioning
+// included, are only mounted when adminKey is set.
+func RegisterRoutes(r *gin.Engine, db *pgxpool.Pool, enc *crypto.Encryptor, adminKey string) *Handler {
 	r.GET("/health", func(c *gin.Context) { c.JSON(200, gin.H{"status": "ok"}) })
 
 	tenantSvc := tenant.NewService(db, enc)
@@ -18,34 +22,93 @@ func RegisterRoutes(r *gin.Engine, db *pgxpool.Pool, enc *crypto.Encryptor) *Han
 		queries:   queries,
 		tenantSvc: tenantSvc,
 		enc:       enc,
+		webhooks:  webhook.NewSender(),
+		jobEvents: newJobEventHub(),
+
+		workflowTx: workflow.InTx(db),
 	}
 	h.registerExecutors()
 
-	// Tenant provisioning (would be admin-gated in production)
-	r.POST("/tenants", h.CreateTenant)
-
-	// Authenticated routes
+	// Authenticated routes. Each requires a scope of the request's API key;
+	// POST /batches and POST /workflows check the scopes of the job types
+	// they queue instead, as do schedules on top of schedules:write.
+	scope := tenant.RequireScope
 	authed := r.Group("/", tenantSvc.AuthMiddleware())
 	{
-		authed.POST("/oauth/:provider/config", h.SetProviderConfig)
-		authed.GET("/oauth/:provider/authorize", h.Authorize)
-		authed.GET("/oauth/:provider/token", h.GetToken)
-		authed.DELETE("/oauth/:provider/token", h.DeleteToken)
-
-		authed.POST("/email/:provider/config", h.SetEmailProviderConfig)
-		authed.POST("/email/:provider/send", h.SendEmail)
-		authed.POST("/sms/:provider/config", h.SetSMSProviderConfig)
-		authed.POST("/sms/:provider/send", h.SendSMS)
-		authed.POST("/code/:provider/config", h.SetCodeProviderConfig)
-		authed.POST("/code/:provider/execute", h.ExecuteCode)
-		authed.GET("/code/executions/:job_id", h.GetCodeExecution)
-
-		authed.GET("/jobs/:id", h.GetJob)
+		authed.POST("/api-keys", scope(tenant.ScopeKeysManage), h.CreateAPIKey)
+		authed.GET("/api-keys", scope(tenant.ScopeKeysManage), h.ListAPIKeys)
+		authed.DELETE("/api-keys/:id", scope(tenant.ScopeKeysManage), h.RevokeAPIKey)
+
+		authed.POST("/oauth/:provider/config", scope(tenant.ScopeConfigWrite), h.SetProviderConfig)
+		authed.GET("/oauth/:provider/authorize", scope(tenant.ScopeOAuthAuthorize), h.Authorize)
+		authed.GET("/oauth/:provider/token", scope(tenant.ScopeOAuthTokenRead), h.GetToken)
+		authed.DELETE("/oauth/:provider/token", scope(tenant.ScopeOAuthTokenDelete), h.DeleteToken)
+
+		authed.POST("/email/:provider/config", scope(tenant.ScopeConfigWrite), h.SetEmailProviderConfig)
+		authed.POST("/email/:provider/send", scope(tenant.ScopeEmailSend), h.Idempotent(), h.SendEmail)
+		authed.POST("/sms/:provider/config", scope(tenant.ScopeConfigWrite), h.SetSMSProviderConfig)
+		authed.POST("/sms/:provider/send", scope(tenant.ScopeSMSSend), h.Idempotent(), h.SendSMS)
+		authed.POST("/code/:provider/config", scope(tenant.ScopeConfigWrite), h.SetCodeProviderConfig)
+		authed.POST("/code/:provider/execute", scope(tenant.ScopeCodeExecute), h.Idempotent(), h.ExecuteCode)
+		authed.GET("/code/executions/:job_id", scope(tenant.ScopeCodeExecute), h.GetCodeExecution)
+
+		authed.GET("/jobs", scope(tenant.ScopeJobsRead), h.ListJobs)
+		authed.GET("/jobs/events", scope(tenant.ScopeJobsRead), h.StreamTenantJobEvents)
+		authed.GET("/jobs/dead-letter", scope(tenant.ScopeJobsRead), h.ListDeadLetterJobs)
+		authed.POST("/jobs/dead-letter/replay", scope(tenant.ScopeJobsWrite), h.ReplayDeadLetterJobs)
+		authed.GET("/jobs/:id", scope(tenant.ScopeJobsRead), h.GetJob)
+		authed.GET("/jobs/:id/events", scope(tenant.ScopeJobsRead), h.StreamJobEvents)
+		authed.DELETE("/jobs/:id", scope(tenant.ScopeJobsWrite), h.CancelJob)
+		authed.POST("/jobs/:id/retry", scope(tenant.ScopeJobsWrite), h.RetryJob)
 		
-    authed.POST("/email/templates", h.UpsertEmailTemplate)
-		authed.GET("/email/templates", h.ListEmailTemplates)
-		authed.DELETE("/email/templates/:name", h.DeleteEmailTemplate)
-		authed.POST("/email/:provider/send-template", h.SendEmailWithTemplate)
+    authed.POST("/email/templates", scope(tenant.ScopeConfigWrite), h.UpsertEmailTemplate)
+		authed.GET("/email/templates", scope(tenant.ScopeConfigRead), h.ListEmailTemplates)
+		authed.DELETE("/email/templates/:name", scope(tenant.ScopeConfigWrite), h.DeleteEmailTemplate)
+		authed.POST("/email/:provider/send-template", scope(tenant.ScopeEmailSend), h.Idempotent(), h.SendEmailWithTemplate)
+
+		authed.POST("/schedules", scope(tenant.ScopeSchedulesWrite), h.CreateSchedule)
+		authed.GET("/schedules", scope(tenant.ScopeJobsRead), h.ListSchedules)
+		authed.GET("/schedules/:id", scope(tenant.ScopeJobsRead), h.GetSchedule)
+		authed.PUT("/schedules/:id", scope(tenant.ScopeSchedulesWrite), h.UpdateSchedule)
+		authed.DELETE("/schedules/:id", scope(tenant.ScopeSchedulesWrite), h.DeleteSchedule)
+		authed.POST("/schedules/:id/pause", scope(tenant.ScopeSchedulesWrite), h.PauseSchedule)
+		authed.POST("/schedules/:id/resume", scope(tenant.ScopeSchedulesWrite), h.ResumeSchedule)
+
+		authed.GET("/rate-limits", scope(tenant.ScopeConfigRead), h.ListRateLimits)
+		authed.PUT("/rate-limits/:provider", scope(tenant.ScopeConfigWrite), h.SetRateLimit)
+		authed.DELETE("/rate-limits/:provider", scope(tenant.ScopeConfigWrite), h.DeleteRateLimit)
+
+		authed.GET("/retention", scope(tenant.ScopeConfigRead), h.GetRetention)
+		authed.PUT("/retention", scope(tenant.ScopeConfigWrite), h.SetRetention)
+		authed.DELETE("/retention", scope(tenant.ScopeConfigWrite), h.DeleteRetention)
+
+		authed.POST("/webhooks", scope(tenant.ScopeConfigWrite), h.CreateWebhook)
+		authed.GET("/webhooks", scope(tenant.ScopeConfigRead), h.ListWebhooks)
+		authed.DELETE("/webhooks/:id", scope(tenant.ScopeConfigWrite), h.DeleteWebhook)
+		authed.GET("/webhooks/:id/deliveries", scope(tenant.ScopeConfigRead), h.ListWebhookDeliveries)
+
+		authed.POST("/batches", h.Idempotent(), h.CreateBatch)
+		authed.GET("/batches/:id", scope(tenant.ScopeJobsRead), h.GetBatch)
+
+		authed.POST("/workflows", h.Idempotent(), h.CreateWorkflow)
+		authed.GET("/workflows", scope(tenant.ScopeJobsRead), h.ListWorkflows)
+		authed.GET("/workflows/:id", scope(tenant.ScopeJobsRead), h.GetWorkflow)
+	}
+
+	if adminKey != "" {
+		admin := r.Group("/admin", AdminAuth(adminKey))
+		{
+			admin.GET("/circuits", h.ListCircuits)
+			admin.POST("/circuits/:tenant_id/:provider/reset", h.ResetCircuit)
+			admin.GET("/status", h.Status)
+
+			admin.POST("/tenants", h.CreateTenant)
+			admin.GET("/tenants", h.ListTenants)
+			admin.GET("/tenants/:tenant_id", h.GetTenant)
+			admin.POST("/tenants/:tenant_id/suspend", h.SuspendTenant)
+			admin.POST("/tenants/:tenant_id/reactivate", h.ReactivateTenant)
+			admin.DELETE("/tenants/:tenant_id", h.DeleteTenant)
+		}
 	}
 
 	// Callback is called by the provider — no tenant auth header, tenant from state param
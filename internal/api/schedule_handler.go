@@ -0,0 +1,366 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/gsarma/tusker/internal/cron"
+	"github.com/gsarma/tusker/internal/store"
+	"github.com/gsarma/tusker/internal/tenant"
+)
+
+// scheduleResponse is the API representation of a schedule. It exists so the
+// payload is returned as JSON rather than base64-encoded bytes.
+type scheduleResponse struct {
+	ID        uuid.UUID       `json:"id"`
+	Name      string          `json:"name"`
+	Cron      string          `json:"cron"`
+	Timezone  string          `json:"timezone"`
+	JobType   string          `json:"job_type"`
+	Payload   json.RawMessage `json:"payload"`
+	Queue     string          `json:"queue"`
+	Priority  int32           `json:"priority"`
+	Retry     scheduleRetry   `json:"retry"`
+	Paused    bool            `json:"paused"`
+	NextRunAt time.Time       `json:"next_run_at"`
+	LastRunAt *time.Time      `json:"last_run_at"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// scheduleRetry is the retry policy of the jobs a schedule fires: the job
+// type's default with the schedule's overrides applied.
+type scheduleRetry struct {
+	MaxAttempts      int32   `json:"max_attempts"`
+	BaseDelaySeconds int32   `json:"base_delay_seconds"`
+	MaxDelaySeconds  int32   `json:"max_delay_seconds"`
+	Jitter           float64 `json:"jitter"`
+}
+
+func toScheduleResponse(s store.Schedule) scheduleResponse {
+	return scheduleResponse{
+		ID:       s.ID,
+		Name:     s.Name,
+		Cron:     s.CronExpr,
+		Timezone: s.Timezone,
+		JobType:  s.JobType,
+		Payload:  json.RawMessage(s.Payload),
+		Queue:    s.Queue,
+		Priority: s.Priority,
+		Retry: scheduleRetry{
+			MaxAttempts:      s.MaxAttempts,
+			BaseDelaySeconds: s.BackoffBaseSeconds,
+			MaxDelaySeconds:  s.BackoffMaxSeconds,
+			Jitter:           s.BackoffJitter,
+		},
+		Paused:    s.Paused,
+		NextRunAt: s.NextRunAt,
+		LastRunAt: s.LastRunAt,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+	}
+}
+
+// scheduleResponse converts s with its payload opened.
+func (h *Handler) scheduleResponse(t *store.Tenant, s store.Schedule) (scheduleResponse, error) {
+	payload, err := h.openPayload(t, s.Payload)
+	if err != nil {
+		return scheduleResponse{}, err
+	}
+	resp := toScheduleResponse(s)
+	resp.Payload = payload
+	return resp, nil
+}
+
+func (h *Handler) writeSchedule(c *gin.Context, status int, t *store.Tenant, s store.Schedule) {
+	resp, err := h.scheduleResponse(t, s)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decrypt schedule payload"})
+		return
+	}
+	c.JSON(status, resp)
+}
+
+// scheduleBody is the request body for creating or updating a schedule. Its
+// job options apply to every job the schedule fires; send_at is not
+// supported.
+type scheduleBody struct {
+	Cron     string          `json:"cron" binding:"required"`
+	Timezone string          `json:"timezone"`
+	JobType  string          `json:"job_type" binding:"required"`
+	Payload  json.RawMessage `json:"payload" binding:"required"`
+	jobOptions
+}
+
+// validateSchedule checks the job type, payload and job options, normalizes
+// the time zone, and returns the schedule's first run and the retry policy of
+// the jobs it fires.
+func (h *Handler) validateSchedule(b *scheduleBody) (time.Time, retryPolicy, error) {
+	if _, ok := jobPayloadBodies[b.JobType]; !ok || !h.userJobType(b.JobType) {
+		return time.Time{}, retryPolicy{}, fmt.Errorf("unknown job_type %q", b.JobType)
+	}
+	if _, err := bindJobPayload(b.JobType, b.Payload); err != nil {
+		return time.Time{}, retryPolicy{}, err
+	}
+	if b.SendAt != nil {
+		return time.Time{}, retryPolicy{}, fmt.Errorf("send_at is not supported in schedules")
+	}
+	if err := b.jobOptions.validate(false); err != nil {
+		return time.Time{}, retryPolicy{}, err
+	}
+	policy, err := retryPolicyFor(b.JobType, b.Retry)
+	if err != nil {
+		return time.Time{}, retryPolicy{}, err
+	}
+	if b.Timezone == "" {
+		b.Timezone = "UTC"
+	}
+	next, err := cron.NextRun(b.Cron, b.Timezone, time.Now())
+	return next, policy, err
+}
+
+// CreateSchedule registers a recurring job. Each time the cron expression fires
+// (evaluated in the optional IANA timezone, default UTC), a worker enqueues a job
+// of job_type with the given payload — the same payload the matching send
+// endpoint would queue, e.g. an email.send_template payload. The optional
+// retry, queue and priority of async requests apply to each job it queues.
+// Besides schedules:write, the API key needs that endpoint's scope.
+//
+// Request body:
+//
+//	{
+//	  "name":     "nightly-digest",
+//	  "cron":     "0 2 * * *",
+//	  "timezone": "Europe/London",
+//	  "job_type": "email.send_template",
+//	  "payload":  { "provider": "smtp", "template": "digest", "to": ["..."], "from": "..." }
+//	}
+func (h *Handler) CreateSchedule(c *gin.Context) {
+	t := tenant.FromContext(c)
+
+	var body struct {
+		Name string `json:"name" binding:"required"`
+		scheduleBody
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	next, policy, err := h.validateSchedule(&body.scheduleBody)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !requireJobScopes(c, body.JobType) {
+		return
+	}
+	payload, err := h.sealPayload(t, body.Payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption error"})
+		return
+	}
+
+	s, err := h.queries.CreateSchedule(c.Request.Context(), store.CreateScheduleParams{
+		TenantID:           t.ID,
+		Name:               body.Name,
+		CronExpr:           body.Cron,
+		Timezone:           body.Timezone,
+		JobType:            body.JobType,
+		Payload:            payload,
+		NextRunAt:          next,
+		Queue:              body.queue(),
+		Priority:           body.priority(),
+		MaxAttempts:        policy.MaxAttempts,
+		BackoffBaseSeconds: int32(policy.BaseDelay / time.Second),
+		BackoffMaxSeconds:  int32(policy.MaxDelay / time.Second),
+		BackoffJitter:      policy.Jitter,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "a schedule named " + body.Name + " already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create schedule"})
+		return
+	}
+	h.writeSchedule(c, http.StatusCreated, t, s)
+}
+
+// ListSchedules returns all of the tenant's schedules, paused ones included.
+func (h *Handler) ListSchedules(c *gin.Context) {
+	t := tenant.FromContext(c)
+
+	rows, err := h.queries.ListSchedules(c.Request.Context(), t.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list schedules"})
+		return
+	}
+	result := make([]scheduleResponse, 0, len(rows))
+	for _, s := range rows {
+		resp, err := h.scheduleResponse(t, s)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decrypt schedule payload"})
+			return
+		}
+		result = append(result, resp)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetSchedule returns a single schedule.
+func (h *Handler) GetSchedule(c *gin.Context) {
+	t := tenant.FromContext(c)
+	id, ok := scheduleID(c)
+	if !ok {
+		return
+	}
+	s, err := h.queries.GetSchedule(c.Request.Context(), store.GetScheduleParams{ID: id, TenantID: t.ID})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	}
+	h.writeSchedule(c, http.StatusOK, t, s)
+}
+
+// UpdateSchedule replaces a schedule's cron expression, timezone, job type,
+// payload and job options. The next run is recomputed from now.
+func (h *Handler) UpdateSchedule(c *gin.Context) {
+	t := tenant.FromContext(c)
+	id, ok := scheduleID(c)
+	if !ok {
+		return
+	}
+
+	var body scheduleBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	next, policy, err := h.validateSchedule(&body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !requireJobScopes(c, body.JobType) {
+		return
+	}
+	payload, err := h.sealPayload(t, body.Payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption error"})
+		return
+	}
+
+	s, err := h.queries.UpdateSchedule(c.Request.Context(), store.UpdateScheduleParams{
+		ID:                 id,
+		TenantID:           t.ID,
+		CronExpr:           body.Cron,
+		Timezone:           body.Timezone,
+		JobType:            body.JobType,
+		Payload:            payload,
+		NextRunAt:          next,
+		Queue:              body.queue(),
+		Priority:           body.priority(),
+		MaxAttempts:        policy.MaxAttempts,
+		BackoffBaseSeconds: int32(policy.BaseDelay / time.Second),
+		BackoffMaxSeconds:  int32(policy.MaxDelay / time.Second),
+		BackoffJitter:      policy.Jitter,
+	})
+	if err != nil {
+		scheduleWriteError(c, err)
+		return
+	}
+	h.writeSchedule(c, http.StatusOK, t, s)
+}
+
+// PauseSchedule stops a schedule from firing until it is resumed.
+func (h *Handler) PauseSchedule(c *gin.Context) {
+	t := tenant.FromContext(c)
+	id, ok := scheduleID(c)
+	if !ok {
+		return
+	}
+	s, err := h.queries.PauseSchedule(c.Request.Context(), store.PauseScheduleParams{ID: id, TenantID: t.ID})
+	if err != nil {
+		scheduleWriteError(c, err)
+		return
+	}
+	h.writeSchedule(c, http.StatusOK, t, s)
+}
+
+// ResumeSchedule re-enables a paused schedule. Runs missed while it was paused
+// are skipped; the next run is the first one after now.
+func (h *Handler) ResumeSchedule(c *gin.Context) {
+	t := tenant.FromContext(c)
+	id, ok := scheduleID(c)
+	if !ok {
+		return
+	}
+
+	s, err := h.queries.GetSchedule(c.Request.Context(), store.GetScheduleParams{ID: id, TenantID: t.ID})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	}
+	next, err := cron.NextRun(s.CronExpr, s.Timezone, time.Now())
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	s, err = h.queries.ResumeSchedule(c.Request.Context(), store.ResumeScheduleParams{
+		ID:        id,
+		TenantID:  t.ID,
+		NextRunAt: next,
+	})
+	if err != nil {
+		scheduleWriteError(c, err)
+		return
+	}
+	h.writeSchedule(c, http.StatusOK, t, s)
+}
+
+// DeleteSchedule removes a schedule. Jobs it has already queued are unaffected.
+func (h *Handler) DeleteSchedule(c *gin.Context) {
+	t := tenant.FromContext(c)
+	id, ok := scheduleID(c)
+	if !ok {
+		return
+	}
+	n, err := h.queries.DeleteSchedule(c.Request.Context(), store.DeleteScheduleParams{ID: id, TenantID: t.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete schedule"})
+		return
+	}
+	if n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// scheduleID parses the :id path parameter, writing a 400 if it is malformed.
+func scheduleID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func scheduleWriteError(c *gin.Context, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update schedule"})
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/gsarma/tusker/internal/tenant"
+)
+
+// jobTypeScopes is the API key scope needed to queue each job type through
+// the endpoints that take any type: batches, workflows and schedules. It is
+// the scope of the type's own send or execute endpoint, so a key limited to
+// email:send can batch emails but not run code.
+var jobTypeScopes = map[string]string{
+	"email.send":          tenant.ScopeEmailSend,
+	"email.send_template": tenant.ScopeEmailSend,
+	"sms.send":            tenant.ScopeSMSSend,
+	"code.execute":        tenant.ScopeCodeExecute,
+}
+
+// requireJobScopes writes a 403 and returns false unless the request's API
+// key may queue jobs of every one of jobTypes. Call it once the job types are
+// known to be valid.
+func requireJobScopes(c *gin.Context, jobTypes ...string) bool {
+	for _, jobType := range jobTypes {
+		scope, ok := jobTypeScopes[jobType]
+		if !ok {
+			scope = tenant.ScopeAll
+		}
+		if !tenant.HasScope(c, scope) {
+			tenant.AbortMissingScope(c, scope)
+			return false
+		}
+	}
+	return true
+}
@@ -2,7 +2,6 @@ package api
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 
@@ -56,38 +55,41 @@ func (h *Handler) SetSMSProviderConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
+// sendSMSBody is the message SendSMS takes: an sms.send job's payload less its
+// provider.
+type sendSMSBody struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+	Body string `json:"body" binding:"required"`
+}
+
 // SendSMS queues an SMS job (async by default) or sends immediately with ?sync=true.
+// An optional send_at (RFC3339) schedules the SMS for a future time.
 func (h *Handler) SendSMS(c *gin.Context) {
 	t := tenant.FromContext(c)
 	providerName := c.Param("provider")
 
 	var body struct {
-		From string `json:"from" binding:"required"`
-		To   string `json:"to" binding:"required"`
-		Body string `json:"body" binding:"required"`
+		sendSMSBody
+		jobOptions
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	sync := c.Query("sync") == "true"
+	if err := body.jobOptions.validate(sync); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if c.Query("sync") != "true" {
-		payloadJSON, _ := json.Marshal(sms.JobPayload{
+	if !sync {
+		h.enqueueJob(c, t, "sms.send", providerName, sms.JobPayload{
 			Provider: providerName,
 			From:     body.From,
 			To:       body.To,
 			Body:     body.Body,
-		})
-		job, err := h.queries.CreateJob(c.Request.Context(), store.CreateJobParams{
-			TenantID: t.ID,
-			JobType:  "sms.send",
-			Payload:  payloadJSON,
-		})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue job"})
-			return
-		}
-		c.JSON(http.StatusAccepted, gin.H{"job_id": job.ID, "status": "queued"})
+		}, body.jobOptions)
 		return
 	}
 
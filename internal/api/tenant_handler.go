@@ -0,0 +1,210 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/gsarma/tusker/internal/store"
+)
+
+const (
+	defaultTenantPageSize = 100
+	maxTenantPageSize     = 1000
+	maxTenantNameLength   = 200
+)
+
+// tenantResponse is the admin view of a tenant. Status is "active" or
+// "suspended".
+type tenantResponse struct {
+	ID                uuid.UUID  `json:"id"`
+	Name              string     `json:"name"`
+	Status            string     `json:"status"`
+	MaxConcurrentJobs *int32     `json:"max_concurrent_jobs"`
+	JobRetentionDays  *int32     `json:"job_retention_days"`
+	CreatedAt         time.Time  `json:"created_at"`
+	SuspendedAt       *time.Time `json:"suspended_at"`
+}
+
+func toTenantResponse(t store.Tenant) tenantResponse {
+	r := tenantResponse{
+		ID:          t.ID,
+		Name:        t.Name,
+		Status:      "active",
+		CreatedAt:   t.CreatedAt,
+		SuspendedAt: t.SuspendedAt,
+	}
+	if t.SuspendedAt != nil {
+		r.Status = "suspended"
+	}
+	if t.MaxConcurrentJobs.Valid {
+		r.MaxConcurrentJobs = &t.MaxConcurrentJobs.Int32
+	}
+	if t.JobRetentionDays.Valid {
+		r.JobRetentionDays = &t.JobRetentionDays.Int32
+	}
+	return r
+}
+
+// CreateTenant provisions a new tenant and returns its first API key, named
+// "default" (shown once). Further keys are managed under /api-keys.
+//
+// Request body (optional):
+//
+//	{ "name": "acme-corp" }
+func (h *Handler) CreateTenant(c *gin.Context) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if len(body.Name) > maxTenantNameLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("name must be at most %d characters", maxTenantNameLength)})
+		return
+	}
+
+	apiKey, tenantID, err := h.tenantSvc.Create(c.Request.Context(), body.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create tenant"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"tenant_id": tenantID,
+		"api_key":   apiKey,
+		"note":      "Store this API key — it will not be shown again.",
+	})
+}
+
+// ListTenants returns tenants, oldest first. ?limit= caps the number returned
+// (default 100, max 1000); pass the last tenant's id as ?after= for the next
+// page.
+func (h *Handler) ListTenants(c *gin.Context) {
+	params := store.ListTenantsParams{MaxResults: defaultTenantPageSize}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTenantPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxTenantPageSize)})
+			return
+		}
+		params.MaxResults = int32(n)
+	}
+	if v := c.Query("after"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid after"})
+			return
+		}
+		params.AfterID = pgtype.UUID{Bytes: id, Valid: true}
+	}
+
+	rows, err := h.queries.ListTenants(c.Request.Context(), params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list tenants"})
+		return
+	}
+	result := make([]tenantResponse, 0, len(rows))
+	for _, t := range rows {
+		result = append(result, toTenantResponse(t))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetTenant returns a tenant.
+func (h *Handler) GetTenant(c *gin.Context) {
+	id, ok := tenantIDParam(c)
+	if !ok {
+		return
+	}
+	t, err := h.queries.GetTenantByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tenant not found"})
+		return
+	}
+	c.JSON(http.StatusOK, toTenantResponse(t))
+}
+
+// SuspendTenant suspends a tenant: its API keys are rejected with 403 and
+// workers stop claiming its jobs and firing its schedules, which wait until
+// it is reactivated. Jobs already running are left to finish.
+func (h *Handler) SuspendTenant(c *gin.Context) {
+	id, ok := tenantIDParam(c)
+	if !ok {
+		return
+	}
+	h.writeTenantUpdate(c, func() (store.Tenant, error) {
+		return h.queries.SuspendTenant(c.Request.Context(), id)
+	})
+}
+
+// ReactivateTenant lifts a tenant's suspension. Its held jobs run as workers
+// reach them, and each overdue schedule fires once.
+func (h *Handler) ReactivateTenant(c *gin.Context) {
+	id, ok := tenantIDParam(c)
+	if !ok {
+		return
+	}
+	h.writeTenantUpdate(c, func() (store.Tenant, error) {
+		return h.queries.ReactivateTenant(c.Request.Context(), id)
+	})
+}
+
+func (h *Handler) writeTenantUpdate(c *gin.Context, update func() (store.Tenant, error)) {
+	t, err := update()
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tenant not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update tenant"})
+		return
+	}
+	c.JSON(http.StatusOK, toTenantResponse(t))
+}
+
+// DeleteTenant permanently deletes a tenant with its keys, configuration,
+// jobs, schedules, workflows and tokens. Only suspended tenants can be
+// deleted (409 otherwise), so that a tenant is taken out of service first.
+func (h *Handler) DeleteTenant(c *gin.Context) {
+	id, ok := tenantIDParam(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	n, err := h.queries.DeleteTenant(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete tenant"})
+		return
+	}
+	if n > 0 {
+		c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+		return
+	}
+
+	// Nothing deleted: find out why.
+	if _, err := h.queries.GetTenantByID(ctx, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tenant not found"})
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "tenant must be suspended before it is deleted"})
+}
+
+func tenantIDParam(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("tenant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/gsarma/tusker/internal/crypto"
+	"github.com/gsarma/tusker/internal/store"
+	"github.com/gsarma/tusker/internal/tenant"
+	"github.com/gsarma/tusker/internal/webhook"
+	"github.com/gsarma/tusker/internal/worker"
+)
+
+// webhookEvents are the events an endpoint may subscribe to.
+var webhookEvents = map[string]bool{
+	worker.EventJobCompleted: true,
+	worker.EventJobFailed:    true,
+}
+
+const (
+	defaultDeliveryPageSize = 50
+	maxDeliveryPageSize     = 200
+)
+
+type webhookResponse struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func toWebhookResponse(e store.WebhookEndpoint) webhookResponse {
+	return webhookResponse{ID: e.ID, URL: e.URL, Events: e.Events, CreatedAt: e.CreatedAt}
+}
+
+// deliveryResponse is one entry of an endpoint's delivery log.
+type deliveryResponse struct {
+	ID             uuid.UUID       `json:"id"`
+	JobID          uuid.UUID       `json:"job_id"`
+	Event          string          `json:"event"`
+	Data           json.RawMessage `json:"data"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	ResponseStatus *int32          `json:"response_status"`
+	LastError      *string         `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+func toDeliveryResponse(d store.WebhookDelivery) deliveryResponse {
+	r := deliveryResponse{
+		ID:          d.ID,
+		JobID:       d.JobID,
+		Event:       d.Event,
+		Data:        json.RawMessage(d.Data),
+		Status:      d.Status,
+		Attempts:    d.Attempts,
+		CreatedAt:   d.CreatedAt,
+		DeliveredAt: d.DeliveredAt,
+	}
+	if d.ResponseStatus.Valid {
+		r.ResponseStatus = &d.ResponseStatus.Int32
+	}
+	if d.LastError.Valid {
+		r.LastError = &d.LastError.String
+	}
+	return r
+}
+
+// CreateWebhook registers an endpoint to be notified of the tenant's job
+// events. The response includes the endpoint's signing secret, which is shown
+// only once. The URL must not point at a private address (see
+// webhook.CheckURL).
+//
+// Request body:
+//
+//	{ "url": "https://example.com/hooks/tusker", "events": ["job.completed", "job.failed"] }
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	t := tenant.FromContext(c)
+
+	var body struct {
+		URL    string   `json:"url" binding:"required"`
+		Events []string `json:"events" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := webhook.CheckURL(body.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(body.Events) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "events must not be empty"})
+		return
+	}
+	for _, ev := range body.Events {
+		if !webhookEvents[ev] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown event: " + ev})
+			return
+		}
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret"})
+		return
+	}
+	dataKey, err := h.tenantSvc.DataKey(t)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption error"})
+		return
+	}
+	encSecret, err := crypto.EncryptWithDataKey(dataKey, []byte(secret))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption error"})
+		return
+	}
+
+	e, err := h.queries.CreateWebhookEndpoint(c.Request.Context(), store.CreateWebhookEndpointParams{
+		TenantID:        t.ID,
+		URL:             body.URL,
+		Events:          body.Events,
+		EncryptedSecret: encSecret,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create webhook"})
+		return
+	}
+	resp := toWebhookResponse(e)
+	resp.Secret = secret
+	c.JSON(http.StatusCreated, resp)
+}
+
+// ListWebhooks returns the tenant's webhook endpoints, without their secrets.
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	t := tenant.FromContext(c)
+
+	rows, err := h.queries.ListWebhookEndpoints(c.Request.Context(), t.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list webhooks"})
+		return
+	}
+	result := make([]webhookResponse, 0, len(rows))
+	for _, e := range rows {
+		result = append(result, toWebhookResponse(e))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteWebhook removes an endpoint. Deliveries still queued for it are dropped.
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	t := tenant.FromContext(c)
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	n, err := h.queries.DeleteWebhookEndpoint(c.Request.Context(), store.DeleteWebhookEndpointParams{ID: id, TenantID: t.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete webhook"})
+		return
+	}
+	if n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// ListWebhookDeliveries returns an endpoint's delivery log, newest first.
+// ?limit= caps the number of entries (default 50, max 200).
+func (h *Handler) ListWebhookDeliveries(c *gin.Context) {
+	t := tenant.FromContext(c)
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	limit := defaultDeliveryPageSize
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDeliveryPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+			return
+		}
+		limit = n
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.queries.GetWebhookEndpoint(ctx, store.GetWebhookEndpointParams{ID: id, TenantID: t.ID}); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return
+	}
+	rows, err := h.queries.ListWebhookDeliveries(ctx, store.ListWebhookDeliveriesParams{
+		EndpointID: id,
+		TenantID:   t.ID,
+		Limit:      int32(limit),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list deliveries"})
+		return
+	}
+	result := make([]deliveryResponse, 0, len(rows))
+	for _, d := range rows {
+		result = append(result, toDeliveryResponse(d))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// webhookID parses the :id path parameter, writing a 400 if it is malformed.
+func webhookID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
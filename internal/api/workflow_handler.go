@@ -0,0 +1,296 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/gsarma/tusker/internal/store"
+	"github.com/gsarma/tusker/internal/tenant"
+	"github.com/gsarma/tusker/internal/workflow"
+)
+
+const (
+	defaultWorkflowPageSize = 50
+	maxWorkflowPageSize     = 200
+)
+
+type workflowResponse struct {
+	ID          uuid.UUID              `json:"id"`
+	Name        string                 `json:"name"`
+	Status      string                 `json:"status"`
+	Steps       []workflowStepResponse `json:"steps,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	CompletedAt *time.Time             `json:"completed_at"`
+}
+
+func toWorkflowResponse(w store.Workflow) workflowResponse {
+	return workflowResponse{
+		ID:          w.ID,
+		Name:        w.Name,
+		Status:      w.Status,
+		CreatedAt:   w.CreatedAt,
+		CompletedAt: w.CompletedAt,
+	}
+}
+
+// workflowStepResponse is a step as submitted, with its progress. Payload
+// still holds its placeholders; the job's payload has them filled in.
+type workflowStepResponse struct {
+	Name      string          `json:"name"`
+	JobType   string          `json:"job_type"`
+	Payload   json.RawMessage `json:"payload"`
+	OnSuccess []string        `json:"on_success"`
+	OnFailure []string        `json:"on_failure"`
+	Status    string          `json:"status"`
+	JobID     *uuid.UUID      `json:"job_id"`
+	Output    json.RawMessage `json:"output"`
+	Error     *string         `json:"error"`
+}
+
+func toWorkflowStepResponse(s store.WorkflowStep) workflowStepResponse {
+	r := workflowStepResponse{
+		Name:      s.Name,
+		JobType:   s.JobType,
+		Payload:   json.RawMessage(s.Payload),
+		OnSuccess: s.OnSuccess,
+		OnFailure: s.OnFailure,
+		Status:    s.Status,
+		Output:    json.RawMessage(s.Output),
+	}
+	if s.JobID.Valid {
+		id := uuid.UUID(s.JobID.Bytes)
+		r.JobID = &id
+	}
+	if s.Error.Valid {
+		r.Error = &s.Error.String
+	}
+	if len(r.Output) == 0 {
+		r.Output = json.RawMessage("null")
+	}
+	return r
+}
+
+// workflowStepBody is one step of a workflow submission. Its job options
+// apply to the step's job; send_at is not supported.
+type workflowStepBody struct {
+	Name      string          `json:"name" binding:"required"`
+	JobType   string          `json:"job_type" binding:"required"`
+	Payload   json.RawMessage `json:"payload" binding:"required"`
+	OnSuccess []string        `json:"on_success"`
+	OnFailure []string        `json:"on_failure"`
+	jobOptions
+}
+
+// workflowStepRow is a step in the form CreateWorkflow inserts it.
+type workflowStepRow struct {
+	Name               string          `json:"name"`
+	Position           int             `json:"position"`
+	JobType            string          `json:"job_type"`
+	Provider           string          `json:"provider"`
+	Payload            json.RawMessage `json:"payload"`
+	Queue              string          `json:"queue"`
+	Priority           int32           `json:"priority"`
+	MaxAttempts        int32           `json:"max_attempts"`
+	BackoffBaseSeconds int32           `json:"backoff_base_seconds"`
+	BackoffMaxSeconds  int32           `json:"backoff_max_seconds"`
+	BackoffJitter      float64         `json:"backoff_jitter"`
+	OnSuccess          []string        `json:"on_success"`
+	OnFailure          []string        `json:"on_failure"`
+	Queued             bool            `json:"queued"`
+}
+
+// CreateWorkflow submits a small DAG of jobs. Each step is a job of any type a
+// send or execute endpoint queues, with the same payload a schedule takes, and
+// needs the API key scope of that endpoint.
+// Steps no edge leads to are queued immediately; the others are queued by the
+// worker once their upstream steps finish, if an on_success edge from a
+// completed step or an on_failure edge from a failed one leads to them, and
+// skipped otherwise. String values in a payload may reference the results of
+// upstream steps: {{steps.<name>.status}}, .error, .job_id, or .output with
+// an optional path into it, e.g. {{steps.run.output.stdout}}.
+//
+// Request body:
+//
+//	{
+//	  "name": "run-and-report",
+//	  "steps": [
+//	    { "name": "run", "job_type": "code.execute",
+//	      "payload": { "provider": "judge0", "source_code": "print(42)", "language_id": 71 },
+//	      "on_success": ["report"], "on_failure": ["alert"] },
+//	    { "name": "report", "job_type": "email.send",
+//	      "payload": { "provider": "smtp", "to": ["..."], "from": "...", "subject": "Result", "body": "{{steps.run.output.stdout}}" } },
+//	    { "name": "alert", "job_type": "sms.send",
+//	      "payload": { "provider": "twilio", "from": "...", "to": "...", "body": "Run failed: {{steps.run.error}}" } }
+//	  ]
+//	}
+func (h *Handler) CreateWorkflow(c *gin.Context) {
+	t := tenant.FromContext(c)
+
+	var body struct {
+		Name  string             `json:"name"`
+		Steps []workflowStepBody `json:"steps" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	steps := make([]workflow.Step, len(body.Steps))
+	for i, s := range body.Steps {
+		steps[i] = workflow.Step{Name: s.Name, Payload: s.Payload, OnSuccess: s.OnSuccess, OnFailure: s.OnFailure}
+	}
+	if err := workflow.Validate(steps); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	roots := workflow.Roots(steps)
+
+	rows := make([]workflowStepRow, len(body.Steps))
+	for i, s := range body.Steps {
+		row, err := h.workflowStepRow(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("step %q: %v", s.Name, err)})
+			return
+		}
+		if row.Payload, err = h.sealPayload(t, row.Payload); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "encryption error"})
+			return
+		}
+		row.Position = i
+		row.Queued = roots[s.Name]
+		rows[i] = row
+	}
+	for _, row := range rows {
+		if !requireJobScopes(c, row.JobType) {
+			return
+		}
+	}
+	stepsJSON, err := json.Marshal(rows)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode workflow steps"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	w, err := h.queries.CreateWorkflow(ctx, store.CreateWorkflowParams{
+		TenantID: t.ID,
+		Name:     body.Name,
+		Steps:    stepsJSON,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create workflow"})
+		return
+	}
+	h.writeWorkflow(c, http.StatusCreated, t, w)
+}
+
+// workflowStepRow checks a step's job type and options and resolves them as
+// enqueueJob would.
+func (h *Handler) workflowStepRow(s workflowStepBody) (workflowStepRow, error) {
+	if !h.userJobType(s.JobType) {
+		return workflowStepRow{}, fmt.Errorf("unknown job_type %q", s.JobType)
+	}
+	if s.SendAt != nil {
+		return workflowStepRow{}, fmt.Errorf("send_at is not supported in workflows")
+	}
+	if err := s.jobOptions.validate(false); err != nil {
+		return workflowStepRow{}, err
+	}
+	policy, err := retryPolicyFor(s.JobType, s.Retry)
+	if err != nil {
+		return workflowStepRow{}, err
+	}
+	var p struct {
+		Provider string `json:"provider"`
+	}
+	json.Unmarshal(s.Payload, &p) // Validate has checked it is an object
+	return workflowStepRow{
+		Name:               s.Name,
+		JobType:            s.JobType,
+		Provider:           p.Provider,
+		Payload:            s.Payload,
+		Queue:              s.queue(),
+		Priority:           s.priority(),
+		MaxAttempts:        policy.MaxAttempts,
+		BackoffBaseSeconds: int32(policy.BaseDelay / time.Second),
+		BackoffMaxSeconds:  int32(policy.MaxDelay / time.Second),
+		BackoffJitter:      policy.Jitter,
+		OnSuccess:          nonNil(s.OnSuccess),
+		OnFailure:          nonNil(s.OnFailure),
+	}, nil
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// ListWorkflows returns the tenant's workflows, newest first, without their
+// steps. ?limit= caps the number returned (default 50, max 200).
+func (h *Handler) ListWorkflows(c *gin.Context) {
+	t := tenant.FromContext(c)
+
+	limit := defaultWorkflowPageSize
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxWorkflowPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxWorkflowPageSize)})
+			return
+		}
+		limit = n
+	}
+
+	rows, err := h.queries.ListWorkflows(c.Request.Context(), store.ListWorkflowsParams{TenantID: t.ID, Limit: int32(limit)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list workflows"})
+		return
+	}
+	result := make([]workflowResponse, 0, len(rows))
+	for _, w := range rows {
+		result = append(result, toWorkflowResponse(w))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetWorkflow returns a workflow with the status, job, output and error of
+// each step.
+func (h *Handler) GetWorkflow(c *gin.Context) {
+	t := tenant.FromContext(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workflow id"})
+		return
+	}
+	w, err := h.queries.GetWorkflow(c.Request.Context(), store.GetWorkflowParams{ID: id, TenantID: t.ID})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "workflow not found"})
+		return
+	}
+	h.writeWorkflow(c, http.StatusOK, t, w)
+}
+
+func (h *Handler) writeWorkflow(c *gin.Context, status int, t *store.Tenant, w store.Workflow) {
+	steps, err := h.queries.ListWorkflowSteps(c.Request.Context(), w.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load workflow steps"})
+		return
+	}
+	resp := toWorkflowResponse(w)
+	resp.Steps = make([]workflowStepResponse, len(steps))
+	for i, s := range steps {
+		if s.Payload, err = h.openPayload(t, s.Payload); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decrypt workflow step payload"})
+			return
+		}
+		resp.Steps[i] = toWorkflowStepResponse(s)
+	}
+	c.JSON(status, resp)
+}
@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/gsarma/tusker/internal/worker"
 )
 
 // Judge0Config holds the connection settings for a Judge0 CE instance.
@@ -69,7 +71,7 @@ func (p *Judge0Provider) Execute(ctx context.Context, sourceCode string, languag
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("judge0 returned HTTP %d", resp.StatusCode)
+		return nil, worker.HTTPError(resp, fmt.Errorf("judge0 returned HTTP %d", resp.StatusCode))
 	}
 
 	var raw struct {
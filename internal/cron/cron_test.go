@@ -0,0 +1,89 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gsarma/tusker/internal/cron"
+)
+
+func mustTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatalf("parse %q: %v", s, err)
+	}
+	return v
+}
+
+func TestNext(t *testing.T) {
+	cases := []struct {
+		expr  string
+		after string
+		want  string
+	}{
+		{"* * * * *", "2025-01-01T10:00:30Z", "2025-01-01T10:01:00Z"},
+		{"0 * * * *", "2025-01-01T10:00:00Z", "2025-01-01T11:00:00Z"},
+		{"*/15 * * * *", "2025-01-01T10:16:00Z", "2025-01-01T10:30:00Z"},
+		{"30 9 * * mon-fri", "2025-01-03T10:00:00Z", "2025-01-06T09:30:00Z"}, // Fri → Mon
+		{"0 0 1 * *", "2025-01-15T00:00:00Z", "2025-02-01T00:00:00Z"},
+		{"0 0 29 2 *", "2025-03-01T00:00:00Z", "2028-02-29T00:00:00Z"}, // leap day
+		{"0 12 * jun sun", "2025-01-01T00:00:00Z", "2025-06-01T12:00:00Z"},
+		{"0 0 * * 7", "2025-01-01T00:00:00Z", "2025-01-05T00:00:00Z"},  // 7 = Sunday
+		{"0 0 13 * 5", "2025-01-01T00:00:00Z", "2025-01-03T00:00:00Z"}, // dom OR dow
+		{"5,10-12 8 * * *", "2025-01-01T08:10:00Z", "2025-01-01T08:11:00Z"},
+		{"@daily", "2025-01-01T00:00:00Z", "2025-01-02T00:00:00Z"},
+		{"@weekly", "2025-01-01T00:00:00Z", "2025-01-05T00:00:00Z"},
+	}
+	for _, tc := range cases {
+		s, err := cron.Parse(tc.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.expr, err)
+			continue
+		}
+		got := s.Next(mustTime(t, tc.after))
+		if want := mustTime(t, tc.want); !got.Equal(want) {
+			t.Errorf("Next(%q, %s) = %s, want %s", tc.expr, tc.after, got.Format(time.RFC3339), tc.want)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		if _, err := cron.Parse(expr); err == nil {
+			t.Errorf("Parse(%q): expected error", expr)
+		}
+	}
+}
+
+func TestNextRun_TimeZone(t *testing.T) {
+	// 09:00 in New York is 14:00 UTC in winter.
+	got, err := cron.NextRun("0 9 * * *", "America/New_York", mustTime(t, "2025-01-01T15:00:00Z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mustTime(t, "2025-01-02T14:00:00Z"); !got.Equal(want) {
+		t.Errorf("got %s, want %s", got.UTC().Format(time.RFC3339), want.Format(time.RFC3339))
+	}
+}
+
+func TestNextRun_Errors(t *testing.T) {
+	now := time.Now()
+	if _, err := cron.NextRun("0 0 * * *", "Mars/Olympus", now); err == nil {
+		t.Error("expected error for unknown time zone")
+	}
+	if _, err := cron.NextRun("0 0 30 2 *", "", now); err == nil {
+		t.Error("expected error for an expression that never fires")
+	}
+}
@@ -17,6 +17,16 @@ type JobPayload struct {
 	Message
 }
 
+// TemplateJobPayload is the serialized form of an email.send_template job.
+// The template is resolved and rendered when the job runs.
+type TemplateJobPayload struct {
+	Provider  string         `json:"provider"`
+	Template  string         `json:"template"`
+	To        []string       `json:"to"`
+	From      string         `json:"from"`
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
 // Provider defines the interface each email provider must implement.
 type Provider interface {
 	Send(ctx context.Context, msg Message) error
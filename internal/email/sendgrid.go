@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+
+	"github.com/gsarma/tusker/internal/worker"
 )
 
 // SendGridConfig holds credentials for the SendGrid API.
@@ -64,7 +66,7 @@ func (p *SendGridProvider) Send(ctx context.Context, msg Message) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("sendgrid returned status %d", resp.StatusCode)
+		return worker.HTTPError(resp, fmt.Errorf("sendgrid returned status %d", resp.StatusCode))
 	}
 	return nil
 }
@@ -2,9 +2,13 @@ package email
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/smtp"
+	"net/textproto"
 	"strings"
+
+	"github.com/gsarma/tusker/internal/worker"
 )
 
 // SMTPConfig holds credentials for an SMTP server.
@@ -42,5 +46,13 @@ func (p *SMTPProvider) Send(_ context.Context, msg Message) error {
 	body := []byte(header + msg.Body)
 
 	addr := fmt.Sprintf("%s:%d", p.cfg.Host, p.cfg.Port)
-	return smtp.SendMail(addr, auth, msg.From, msg.To, body)
+	err := smtp.SendMail(addr, auth, msg.From, msg.To, body)
+
+	// 5xx replies (e.g. unknown mailbox, authentication failed) are permanent;
+	// 4xx replies and connection errors are transient.
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) && tpErr.Code >= 500 {
+		return worker.Permanent(err)
+	}
+	return err
 }
@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+
+	"github.com/gsarma/tusker/internal/worker"
 )
 
 // TwilioProvider sends SMS messages via the Twilio REST API.
@@ -59,8 +61,8 @@ func (t *TwilioProvider) Send(ctx context.Context, from, to, body string) (*Mess
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("twilio: API error %d (code %d): %s",
-			resp.StatusCode, result.Code, result.Message)
+		return nil, worker.HTTPError(resp, fmt.Errorf("twilio: API error %d (code %d): %s",
+			resp.StatusCode, result.Code, result.Message))
 	}
 
 	return &Message{SID: result.SID, Status: result.Status}, nil
@@ -13,23 +13,133 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const claimNextJob = `-- name: ClaimNextJob :one
+const cancelJob = `-- name: CancelJob :one
 UPDATE jobs SET
-    status = 'running',
-    started_at = NOW(),
-    attempt = attempt + 1
-WHERE id = (
-    SELECT id FROM jobs
-    WHERE status = 'pending' AND run_at <= NOW()
-    ORDER BY run_at ASC
+    status = 'cancelled',
+    completed_at = NOW()
+WHERE id = $1 AND tenant_id = $2 AND status = 'pending'
+RETURNING id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at, lease_expires_at, cancel_requested, backoff_base_seconds, backoff_max_seconds, backoff_jitter, queue, priority, provider, workflow_id, output, batch_id
+`
+
+type CancelJobParams struct {
+	ID       uuid.UUID `json:"id"`
+	TenantID uuid.UUID `json:"tenant_id"`
+}
+
+// Cancels a job that has not started yet. Returns no rows if the job does not
+// exist or has already left the pending state.
+func (q *Queries) CancelJob(ctx context.Context, arg CancelJobParams) (Job, error) {
+	row := q.db.QueryRow(ctx, cancelJob, arg.ID, arg.TenantID)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.JobType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempt,
+		&i.MaxAttempts,
+		&i.Error,
+		&i.RunAt,
+		&i.StartedAt,
+		&i.CompletedAt,
+		&i.CreatedAt,
+		&i.LeaseExpiresAt,
+		&i.CancelRequested,
+		&i.BackoffBaseSeconds,
+		&i.BackoffMaxSeconds,
+		&i.BackoffJitter,
+		&i.Queue,
+		&i.Priority,
+		&i.Provider,
+		&i.WorkflowID,
+		&i.Output,
+		&i.BatchID,
+	)
+	return i, err
+}
+
+const claimNextJob = `-- name: ClaimNextJob :one
+WITH busy AS (
+    SELECT r.tenant_id
+    FROM jobs r
+    JOIN tenants t ON t.id = r.tenant_id
+    WHERE r.status = 'running'
+    GROUP BY r.tenant_id, t.max_concurrent_jobs
+    HAVING COUNT(*) >= COALESCE(t.max_concurrent_jobs, $1::int)
+), blocked AS (
+    SELECT tenant_id, provider FROM provider_circuits WHERE open_until > NOW()
+    UNION
+    SELECT tenant_id, provider FROM provider_rate_limits
+    WHERE LEAST(burst, tokens + rate_per_second * EXTRACT(EPOCH FROM NOW() - updated_at)) < 1
+), next AS (
+    -- The lateral probe runs tenant by tenant in the order below and stops at
+    -- the first job it can lock, so only that job is locked.
+    SELECT j.id
+    FROM (
+        SELECT id FROM tenants
+        WHERE id NOT IN (SELECT tenant_id FROM busy) AND suspended_at IS NULL
+        ORDER BY id <= $2::uuid, id
+    ) t
+    CROSS JOIN LATERAL (
+        SELECT id FROM jobs
+        WHERE tenant_id = t.id AND status = 'pending' AND run_at <= NOW()
+          AND ($3::text[] IS NULL OR queue = ANY($3::text[]))
+          AND NOT EXISTS (SELECT 1 FROM blocked b WHERE b.tenant_id = t.id AND b.provider = jobs.provider)
+        ORDER BY priority DESC, run_at ASC
+        LIMIT 1
+        FOR UPDATE SKIP LOCKED
+    ) j
     LIMIT 1
-    FOR UPDATE SKIP LOCKED
+), claimed AS (
+    UPDATE jobs SET
+        status = 'running',
+        started_at = NOW(),
+        attempt = attempt + 1,
+        lease_expires_at = NOW() + $4::int * INTERVAL '1 second'
+    WHERE id = (SELECT id FROM next)
+    RETURNING id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at, lease_expires_at, cancel_requested, backoff_base_seconds, backoff_max_seconds, backoff_jitter, queue, priority, provider, workflow_id, output, batch_id
+), opened AS (
+    INSERT INTO job_attempts (job_id, attempt, worker_id, started_at)
+    SELECT id, attempt, $5, started_at FROM claimed
+), taken AS (
+    -- Concurrent claims may each see the last token, leaving the bucket
+    -- slightly negative; it then simply takes longer to refill.
+    UPDATE provider_rate_limits l SET
+        tokens = LEAST(l.burst, l.tokens + l.rate_per_second * EXTRACT(EPOCH FROM NOW() - l.updated_at)) - 1,
+        updated_at = NOW()
+    FROM claimed c
+    WHERE l.tenant_id = c.tenant_id AND l.provider = c.provider
 )
-RETURNING id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at
+SELECT id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at, lease_expires_at, cancel_requested, backoff_base_seconds, backoff_max_seconds, backoff_jitter, queue, priority, provider, workflow_id, output, batch_id FROM claimed
 `
 
-func (q *Queries) ClaimNextJob(ctx context.Context) (Job, error) {
-	row := q.db.QueryRow(ctx, claimNextJob)
+type ClaimNextJobParams struct {
+	DefaultTenantLimit pgtype.Int4 `json:"default_tenant_limit"`
+	AfterTenantID      uuid.UUID   `json:"after_tenant_id"`
+	Queues             []string    `json:"queues"`
+	LeaseSeconds       int32       `json:"lease_seconds"`
+	WorkerID           string      `json:"worker_id"`
+}
+
+// Claims a due job in one of queues (NULL for any queue) from the first tenant
+// after after_tenant_id (in id order, wrapping around) that has one, so tenants
+// are served round-robin rather than strictly by run_at: a tenant with a large
+// backlog cannot starve the others. Within the tenant, the highest-priority
+// job is claimed first, oldest first among equals. Tenants already running their max_concurrent_jobs, or
+// default_tenant_limit if that is NULL, are skipped; a NULL limit is uncapped.
+// Suspended tenants are skipped too.
+// Jobs for a provider whose circuit is open, or whose rate limit bucket holds
+// less than one token, are skipped too; claiming takes a token from the bucket.
+// Also opens the job_attempts row for the new attempt.
+func (q *Queries) ClaimNextJob(ctx context.Context, arg ClaimNextJobParams) (Job, error) {
+	row := q.db.QueryRow(ctx, claimNextJob,
+		arg.DefaultTenantLimit,
+		arg.AfterTenantID,
+		arg.Queues,
+		arg.LeaseSeconds,
+		arg.WorkerID,
+	)
 	var i Job
 	err := row.Scan(
 		&i.ID,
@@ -44,24 +154,113 @@ func (q *Queries) ClaimNextJob(ctx context.Context) (Job, error) {
 		&i.StartedAt,
 		&i.CompletedAt,
 		&i.CreatedAt,
+		&i.LeaseExpiresAt,
+		&i.CancelRequested,
+		&i.BackoffBaseSeconds,
+		&i.BackoffMaxSeconds,
+		&i.BackoffJitter,
+		&i.Queue,
+		&i.Priority,
+		&i.Provider,
+		&i.WorkflowID,
+		&i.Output,
+		&i.BatchID,
 	)
 	return i, err
 }
 
+const countJobsByStatus = `-- name: CountJobsByStatus :many
+SELECT status, COUNT(*) AS count FROM jobs
+WHERE tenant_id = $1
+  AND ($2::text IS NULL OR job_type = $2)
+  AND ($3::timestamptz IS NULL OR created_at >= $3)
+  AND ($4::timestamptz IS NULL OR created_at < $4)
+GROUP BY status
+`
+
+type CountJobsByStatusParams struct {
+	TenantID      uuid.UUID   `json:"tenant_id"`
+	JobType       pgtype.Text `json:"job_type"`
+	CreatedAfter  *time.Time  `json:"created_after"`
+	CreatedBefore *time.Time  `json:"created_before"`
+}
+
+type CountJobsByStatusRow struct {
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+func (q *Queries) CountJobsByStatus(ctx context.Context, arg CountJobsByStatusParams) ([]CountJobsByStatusRow, error) {
+	rows, err := q.db.Query(ctx, countJobsByStatus,
+		arg.TenantID,
+		arg.JobType,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountJobsByStatusRow
+	for rows.Next() {
+		var i CountJobsByStatusRow
+		if err := rows.Scan(
+			&i.Status,
+			&i.Count,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createJob = `-- name: CreateJob :one
-INSERT INTO jobs (tenant_id, job_type, payload)
-VALUES ($1, $2, $3)
-RETURNING id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at
+INSERT INTO jobs (
+    tenant_id, job_type, payload, provider, queue, priority,
+    max_attempts, backoff_base_seconds, backoff_max_seconds, backoff_jitter,
+    run_at
+)
+VALUES (
+    $1, $2, $3, $4, $5, $6,
+    $7, $8, $9, $10,
+    COALESCE($11::timestamptz, NOW())
+)
+RETURNING id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at, lease_expires_at, cancel_requested, backoff_base_seconds, backoff_max_seconds, backoff_jitter, queue, priority, provider, workflow_id, output, batch_id
 `
 
 type CreateJobParams struct {
-	TenantID uuid.UUID `json:"tenant_id"`
-	JobType  string    `json:"job_type"`
-	Payload  []byte    `json:"payload"`
+	TenantID           uuid.UUID  `json:"tenant_id"`
+	JobType            string     `json:"job_type"`
+	Payload            []byte     `json:"payload"`
+	Provider           string     `json:"provider"`
+	Queue              string     `json:"queue"`
+	Priority           int32      `json:"priority"`
+	MaxAttempts        int32      `json:"max_attempts"`
+	BackoffBaseSeconds int32      `json:"backoff_base_seconds"`
+	BackoffMaxSeconds  int32      `json:"backoff_max_seconds"`
+	BackoffJitter      float64    `json:"backoff_jitter"`
+	RunAt              *time.Time `json:"run_at"`
 }
 
+// A NULL run_at queues the job to run immediately.
 func (q *Queries) CreateJob(ctx context.Context, arg CreateJobParams) (Job, error) {
-	row := q.db.QueryRow(ctx, createJob, arg.TenantID, arg.JobType, arg.Payload)
+	row := q.db.QueryRow(ctx, createJob,
+		arg.TenantID,
+		arg.JobType,
+		arg.Payload,
+		arg.Provider,
+		arg.Queue,
+		arg.Priority,
+		arg.MaxAttempts,
+		arg.BackoffBaseSeconds,
+		arg.BackoffMaxSeconds,
+		arg.BackoffJitter,
+		arg.RunAt,
+	)
 	var i Job
 	err := row.Scan(
 		&i.ID,
@@ -76,12 +275,46 @@ func (q *Queries) CreateJob(ctx context.Context, arg CreateJobParams) (Job, erro
 		&i.StartedAt,
 		&i.CompletedAt,
 		&i.CreatedAt,
+		&i.LeaseExpiresAt,
+		&i.CancelRequested,
+		&i.BackoffBaseSeconds,
+		&i.BackoffMaxSeconds,
+		&i.BackoffJitter,
+		&i.Queue,
+		&i.Priority,
+		&i.Provider,
+		&i.WorkflowID,
+		&i.Output,
+		&i.BatchID,
 	)
 	return i, err
 }
 
+const extendJobLease = `-- name: ExtendJobLease :one
+UPDATE jobs SET
+    lease_expires_at = NOW() + $1::int * INTERVAL '1 second'
+WHERE id = $2 AND status = 'running' AND attempt = $3
+RETURNING cancel_requested
+`
+
+type ExtendJobLeaseParams struct {
+	LeaseSeconds int32     `json:"lease_seconds"`
+	ID           uuid.UUID `json:"id"`
+	Attempt      int32     `json:"attempt"`
+}
+
+// Reports whether cancellation of the job has been requested. Returns no rows
+// once the job is no longer ours: it finished, or the reaper reclaimed it and it
+// may already be running elsewhere.
+func (q *Queries) ExtendJobLease(ctx context.Context, arg ExtendJobLeaseParams) (bool, error) {
+	row := q.db.QueryRow(ctx, extendJobLease, arg.LeaseSeconds, arg.ID, arg.Attempt)
+	var cancelRequested bool
+	err := row.Scan(&cancelRequested)
+	return cancelRequested, err
+}
+
 const getJob = `-- name: GetJob :one
-SELECT id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at FROM jobs
+SELECT id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at, lease_expires_at, cancel_requested, backoff_base_seconds, backoff_max_seconds, backoff_jitter, queue, priority, provider, workflow_id, output, batch_id FROM jobs
 WHERE id = $1 AND tenant_id = $2
 `
 
@@ -106,18 +339,477 @@ func (q *Queries) GetJob(ctx context.Context, arg GetJobParams) (Job, error) {
 		&i.StartedAt,
 		&i.CompletedAt,
 		&i.CreatedAt,
+		&i.LeaseExpiresAt,
+		&i.CancelRequested,
+		&i.BackoffBaseSeconds,
+		&i.BackoffMaxSeconds,
+		&i.BackoffJitter,
+		&i.Queue,
+		&i.Priority,
+		&i.Provider,
+		&i.WorkflowID,
+		&i.Output,
+		&i.BatchID,
 	)
 	return i, err
 }
 
-const updateJobStatus = `-- name: UpdateJobStatus :one
+const listJobs = `-- name: ListJobs :many
+SELECT id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at, lease_expires_at, cancel_requested, backoff_base_seconds, backoff_max_seconds, backoff_jitter, queue, priority, provider, workflow_id, output, batch_id FROM jobs
+WHERE tenant_id = $1
+  AND ($2::text IS NULL OR status = $2)
+  AND ($3::text IS NULL OR job_type = $3)
+  AND ($4::timestamptz IS NULL OR created_at >= $4)
+  AND ($5::timestamptz IS NULL OR created_at < $5)
+  AND ($6::timestamptz IS NULL
+       OR (created_at, id) < ($6, $7::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $8
+`
+
+type ListJobsParams struct {
+	TenantID        uuid.UUID   `json:"tenant_id"`
+	Status          pgtype.Text `json:"status"`
+	JobType         pgtype.Text `json:"job_type"`
+	CreatedAfter    *time.Time  `json:"created_after"`
+	CreatedBefore   *time.Time  `json:"created_before"`
+	CursorCreatedAt *time.Time  `json:"cursor_created_at"`
+	CursorID        uuid.UUID   `json:"cursor_id"`
+	PageSize        int32       `json:"page_size"`
+}
+
+// Keyset pagination, newest first: pass the created_at and id of the last job
+// on the previous page as the cursor. NULL filters match everything.
+func (q *Queries) ListJobs(ctx context.Context, arg ListJobsParams) ([]Job, error) {
+	rows, err := q.db.Query(ctx, listJobs,
+		arg.TenantID,
+		arg.Status,
+		arg.JobType,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Job
+	for rows.Next() {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.JobType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempt,
+			&i.MaxAttempts,
+			&i.Error,
+			&i.RunAt,
+			&i.StartedAt,
+			&i.CompletedAt,
+			&i.CreatedAt,
+			&i.LeaseExpiresAt,
+			&i.CancelRequested,
+			&i.BackoffBaseSeconds,
+			&i.BackoffMaxSeconds,
+			&i.BackoffJitter,
+			&i.Queue,
+			&i.Priority,
+			&i.Provider,
+			&i.WorkflowID,
+			&i.Output,
+			&i.BatchID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPlaintextJobPayloads = `-- name: ListPlaintextJobPayloads :many
+SELECT id, tenant_id, payload FROM jobs
+WHERE NOT payload ? 'ciphertext' AND job_type <> 'webhook.deliver'
+LIMIT $1
+`
+
+type ListPlaintextJobPayloadsRow struct {
+	ID       uuid.UUID `json:"id"`
+	TenantID uuid.UUID `json:"tenant_id"`
+	Payload  []byte    `json:"payload"`
+}
+
+// Jobs whose payload predates payload encryption. webhook.deliver payloads
+// only hold a delivery id and are left in the clear.
+func (q *Queries) ListPlaintextJobPayloads(ctx context.Context, limit int32) ([]ListPlaintextJobPayloadsRow, error) {
+	rows, err := q.db.Query(ctx, listPlaintextJobPayloads, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPlaintextJobPayloadsRow
+	for rows.Next() {
+		var i ListPlaintextJobPayloadsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Payload,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeFinishedJobs = `-- name: PurgeFinishedJobs :execrows
+DELETE FROM jobs
+WHERE id IN (
+    SELECT j.id FROM jobs j
+    JOIN tenants t ON t.id = j.tenant_id
+    WHERE j.status IN ('completed', 'failed', 'cancelled')
+      AND j.completed_at < NOW() - make_interval(days => COALESCE(t.job_retention_days, $1::int))
+    LIMIT $2
+    FOR UPDATE OF j SKIP LOCKED
+)
+`
+
+type PurgeFinishedJobsParams struct {
+	DefaultDays pgtype.Int4 `json:"default_days"`
+	BatchSize   int32       `json:"batch_size"`
+}
+
+// Deletes up to batch_size jobs that finished longer ago than their tenant's
+// retention window, or default_days for tenants without one; with neither,
+// jobs are kept. Attempts, code execution results and webhook deliveries go
+// with them. Only finished jobs are read and locked, skipping any a retry has
+// locked, so a purge never blocks claims of pending jobs.
+func (q *Queries) PurgeFinishedJobs(ctx context.Context, arg PurgeFinishedJobsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeFinishedJobs, arg.DefaultDays, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const reapExpiredJobs = `-- name: ReapExpiredJobs :many
+WITH reaped AS (
+    UPDATE jobs SET
+        status = CASE
+            WHEN cancel_requested THEN 'cancelled'
+            WHEN attempt >= max_attempts THEN 'failed'
+            ELSE 'pending'
+        END,
+        error = 'lease expired: worker stopped responding',
+        run_at = NOW(),
+        completed_at = CASE WHEN cancel_requested OR attempt >= max_attempts THEN NOW() END,
+        lease_expires_at = NULL
+    WHERE id IN (
+        SELECT id FROM jobs
+        WHERE status = 'running' AND lease_expires_at < NOW()
+        LIMIT 100
+        FOR UPDATE SKIP LOCKED
+    )
+    RETURNING id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at, lease_expires_at, cancel_requested, backoff_base_seconds, backoff_max_seconds, backoff_jitter, queue, priority, provider, workflow_id, output, batch_id
+), finished AS (
+    UPDATE job_attempts a SET
+        outcome = 'lease_expired',
+        error = r.error,
+        finished_at = NOW(),
+        duration_ms = (EXTRACT(EPOCH FROM NOW() - a.started_at) * 1000)::bigint
+    FROM reaped r
+    WHERE a.job_id = r.id AND a.attempt = r.attempt AND a.finished_at IS NULL
+)
+SELECT id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at, lease_expires_at, cancel_requested, backoff_base_seconds, backoff_max_seconds, backoff_jitter, queue, priority, provider, workflow_id, output, batch_id FROM reaped
+`
+
+// Returns jobs abandoned by a crashed worker to the queue. The attempt was
+// already counted when the job was claimed.
+func (q *Queries) ReapExpiredJobs(ctx context.Context) ([]Job, error) {
+	rows, err := q.db.Query(ctx, reapExpiredJobs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Job
+	for rows.Next() {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.JobType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempt,
+			&i.MaxAttempts,
+			&i.Error,
+			&i.RunAt,
+			&i.StartedAt,
+			&i.CompletedAt,
+			&i.CreatedAt,
+			&i.LeaseExpiresAt,
+			&i.CancelRequested,
+			&i.BackoffBaseSeconds,
+			&i.BackoffMaxSeconds,
+			&i.BackoffJitter,
+			&i.Queue,
+			&i.Priority,
+			&i.Provider,
+			&i.WorkflowID,
+			&i.Output,
+			&i.BatchID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const replayFailedJobs = `-- name: ReplayFailedJobs :execrows
 UPDATE jobs SET
-    status = $2,
-    error = $3,
-    completed_at = $4,
-    run_at = $5
-WHERE id = $1
-RETURNING id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at
+    status = 'pending',
+    attempt = 0,
+    error = NULL,
+    output = NULL,
+    cancel_requested = FALSE,
+    run_at = NOW(),
+    started_at = NULL,
+    completed_at = NULL
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE tenant_id = $1
+      AND status = 'failed'
+      AND workflow_id IS NULL
+      AND ($2::text IS NULL OR job_type = $2)
+      AND created_at >= $3
+      AND created_at < $4
+    ORDER BY created_at
+    LIMIT $5
+    FOR UPDATE SKIP LOCKED
+)
+`
+
+type ReplayFailedJobsParams struct {
+	TenantID      uuid.UUID   `json:"tenant_id"`
+	JobType       pgtype.Text `json:"job_type"`
+	CreatedAfter  time.Time   `json:"created_after"`
+	CreatedBefore time.Time   `json:"created_before"`
+	MaxJobs       int32       `json:"max_jobs"`
+}
+
+// Bulk RetryJob for failed jobs created in [created_after, created_before),
+// optionally limited to one job type. Replays at most max_jobs per call.
+func (q *Queries) ReplayFailedJobs(ctx context.Context, arg ReplayFailedJobsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, replayFailedJobs,
+		arg.TenantID,
+		arg.JobType,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.MaxJobs,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const requestJobCancel = `-- name: RequestJobCancel :one
+UPDATE jobs SET
+    cancel_requested = TRUE
+WHERE id = $1 AND tenant_id = $2 AND status = 'running'
+RETURNING id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at, lease_expires_at, cancel_requested, backoff_base_seconds, backoff_max_seconds, backoff_jitter, queue, priority, provider, workflow_id, output, batch_id
+`
+
+type RequestJobCancelParams struct {
+	ID       uuid.UUID `json:"id"`
+	TenantID uuid.UUID `json:"tenant_id"`
+}
+
+// Flags a running job for cooperative cancellation by its worker.
+func (q *Queries) RequestJobCancel(ctx context.Context, arg RequestJobCancelParams) (Job, error) {
+	row := q.db.QueryRow(ctx, requestJobCancel, arg.ID, arg.TenantID)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.JobType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempt,
+		&i.MaxAttempts,
+		&i.Error,
+		&i.RunAt,
+		&i.StartedAt,
+		&i.CompletedAt,
+		&i.CreatedAt,
+		&i.LeaseExpiresAt,
+		&i.CancelRequested,
+		&i.BackoffBaseSeconds,
+		&i.BackoffMaxSeconds,
+		&i.BackoffJitter,
+		&i.Queue,
+		&i.Priority,
+		&i.Provider,
+		&i.WorkflowID,
+		&i.Output,
+		&i.BatchID,
+	)
+	return i, err
+}
+
+const requeueJob = `-- name: RequeueJob :execrows
+WITH finished AS (
+    UPDATE job_attempts SET
+        outcome = 'interrupted',
+        finished_at = NOW(),
+        duration_ms = (EXTRACT(EPOCH FROM NOW() - started_at) * 1000)::bigint
+    WHERE job_id = $1 AND attempt = $2 AND finished_at IS NULL
+)
+UPDATE jobs SET
+    status = CASE WHEN cancel_requested THEN 'cancelled' ELSE 'pending' END,
+    attempt = attempt - 1,
+    run_at = NOW(),
+    started_at = NULL,
+    lease_expires_at = NULL
+WHERE id = $1 AND status = 'running' AND attempt = $2
+`
+
+type RequeueJobParams struct {
+	ID      uuid.UUID `json:"id"`
+	Attempt int32     `json:"attempt"`
+}
+
+// Returns a job interrupted by worker shutdown to the queue without counting
+// the interrupted attempt against max_attempts. A job whose cancellation was
+// requested is cancelled instead.
+func (q *Queries) RequeueJob(ctx context.Context, arg RequeueJobParams) (int64, error) {
+	result, err := q.db.Exec(ctx, requeueJob, arg.ID, arg.Attempt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const retryJob = `-- name: RetryJob :one
+UPDATE jobs SET
+    status = 'pending',
+    attempt = 0,
+    error = NULL,
+    output = NULL,
+    cancel_requested = FALSE,
+    run_at = NOW(),
+    started_at = NULL,
+    completed_at = NULL
+WHERE id = $1 AND tenant_id = $2 AND status = 'failed' AND workflow_id IS NULL
+RETURNING id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at, lease_expires_at, cancel_requested, backoff_base_seconds, backoff_max_seconds, backoff_jitter, queue, priority, provider, workflow_id, output, batch_id
+`
+
+type RetryJobParams struct {
+	ID       uuid.UUID `json:"id"`
+	TenantID uuid.UUID `json:"tenant_id"`
+}
+
+// Gives a dead-lettered job a fresh set of attempts. Its attempt history is kept.
+// Workflow steps are not retried: a step's outcome is decided once, and its
+// workflow has already moved on.
+func (q *Queries) RetryJob(ctx context.Context, arg RetryJobParams) (Job, error) {
+	row := q.db.QueryRow(ctx, retryJob, arg.ID, arg.TenantID)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.JobType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempt,
+		&i.MaxAttempts,
+		&i.Error,
+		&i.RunAt,
+		&i.StartedAt,
+		&i.CompletedAt,
+		&i.CreatedAt,
+		&i.LeaseExpiresAt,
+		&i.CancelRequested,
+		&i.BackoffBaseSeconds,
+		&i.BackoffMaxSeconds,
+		&i.BackoffJitter,
+		&i.Queue,
+		&i.Priority,
+		&i.Provider,
+		&i.WorkflowID,
+		&i.Output,
+		&i.BatchID,
+	)
+	return i, err
+}
+
+const sealJobPayload = `-- name: SealJobPayload :execrows
+UPDATE jobs SET payload = $2
+WHERE id = $1 AND NOT payload ? 'ciphertext'
+`
+
+type SealJobPayloadParams struct {
+	ID      uuid.UUID `json:"id"`
+	Payload []byte    `json:"payload"`
+}
+
+func (q *Queries) SealJobPayload(ctx context.Context, arg SealJobPayloadParams) (int64, error) {
+	result, err := q.db.Exec(ctx, sealJobPayload, arg.ID, arg.Payload)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setJobOutput = `-- name: SetJobOutput :exec
+UPDATE jobs SET output = $2
+WHERE id = $1 AND status = 'running'
+`
+
+type SetJobOutputParams struct {
+	ID     uuid.UUID `json:"id"`
+	Output []byte    `json:"output"`
+}
+
+// Records the result an executor reports for a running job.
+func (q *Queries) SetJobOutput(ctx context.Context, arg SetJobOutputParams) error {
+	_, err := q.db.Exec(ctx, setJobOutput, arg.ID, arg.Output)
+	return err
+}
+
+const updateJobStatus = `-- name: UpdateJobStatus :one
+WITH updated AS (
+    UPDATE jobs SET
+        status = $2,
+        error = $3,
+        completed_at = $4,
+        run_at = $5,
+        lease_expires_at = NULL
+    WHERE id = $1 AND status = 'running' AND attempt = $6
+    RETURNING id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at, lease_expires_at, cancel_requested, backoff_base_seconds, backoff_max_seconds, backoff_jitter, queue, priority, provider, workflow_id, output, batch_id
+), finished AS (
+    UPDATE job_attempts a SET
+        outcome = CASE WHEN u.status = 'pending' THEN 'failed' ELSE u.status END,
+        error = u.error,
+        finished_at = NOW(),
+        duration_ms = (EXTRACT(EPOCH FROM NOW() - a.started_at) * 1000)::bigint
+    FROM updated u
+    WHERE a.job_id = u.id AND a.attempt = u.attempt AND a.finished_at IS NULL
+)
+SELECT id, tenant_id, job_type, payload, status, attempt, max_attempts, error, run_at, started_at, completed_at, created_at, lease_expires_at, cancel_requested, backoff_base_seconds, backoff_max_seconds, backoff_jitter, queue, priority, provider, workflow_id, output, batch_id FROM updated
 `
 
 type UpdateJobStatusParams struct {
@@ -126,8 +818,12 @@ type UpdateJobStatusParams struct {
 	Error       pgtype.Text `json:"error"`
 	CompletedAt *time.Time  `json:"completed_at"`
 	RunAt       time.Time   `json:"run_at"`
+	Attempt     int32       `json:"attempt"`
 }
 
+// The status/attempt guard fences out a worker whose lease was reaped.
+// Also closes the attempt's job_attempts row; an attempt that left the job
+// pending for a retry is recorded as failed.
 func (q *Queries) UpdateJobStatus(ctx context.Context, arg UpdateJobStatusParams) (Job, error) {
 	row := q.db.QueryRow(ctx, updateJobStatus,
 		arg.ID,
@@ -135,6 +831,7 @@ func (q *Queries) UpdateJobStatus(ctx context.Context, arg UpdateJobStatusParams
 		arg.Error,
 		arg.CompletedAt,
 		arg.RunAt,
+		arg.Attempt,
 	)
 	var i Job
 	err := row.Scan(
@@ -150,6 +847,17 @@ func (q *Queries) UpdateJobStatus(ctx context.Context, arg UpdateJobStatusParams
 		&i.StartedAt,
 		&i.CompletedAt,
 		&i.CreatedAt,
+		&i.LeaseExpiresAt,
+		&i.CancelRequested,
+		&i.BackoffBaseSeconds,
+		&i.BackoffMaxSeconds,
+		&i.BackoffJitter,
+		&i.Queue,
+		&i.Priority,
+		&i.Provider,
+		&i.WorkflowID,
+		&i.Output,
+		&i.BatchID,
 	)
 	return i, err
 }
@@ -11,6 +11,25 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID  `json:"id"`
+	TenantID   uuid.UUID  `json:"tenant_id"`
+	Name       string     `json:"name"`
+	KeyHash    string     `json:"key_hash"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	Scopes     []string   `json:"scopes"`
+}
+
+type Batch struct {
+	ID        uuid.UUID `json:"id"`
+	TenantID  uuid.UUID `json:"tenant_id"`
+	Total     int32     `json:"total"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type CodeExecution struct {
 	ID            uuid.UUID `json:"id"`
 	JobID         uuid.UUID `json:"job_id"`
@@ -51,19 +70,52 @@ type EmailTemplate struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-type Job struct {
-	ID          uuid.UUID   `json:"id"`
+type IdempotencyKey struct {
 	TenantID    uuid.UUID   `json:"tenant_id"`
-	JobType     string      `json:"job_type"`
-	Payload     []byte      `json:"payload"`
-	Status      string      `json:"status"`
-	Attempt     int32       `json:"attempt"`
-	MaxAttempts int32       `json:"max_attempts"`
-	Error       pgtype.Text `json:"error"`
-	RunAt       time.Time   `json:"run_at"`
-	StartedAt   *time.Time  `json:"started_at"`
-	CompletedAt *time.Time  `json:"completed_at"`
+	Key         string      `json:"key"`
+	RequestHash []byte      `json:"request_hash"`
+	StatusCode  pgtype.Int4 `json:"status_code"`
+	Response    []byte      `json:"response"`
 	CreatedAt   time.Time   `json:"created_at"`
+	ExpiresAt   time.Time   `json:"expires_at"`
+}
+
+type Job struct {
+	ID                 uuid.UUID   `json:"id"`
+	TenantID           uuid.UUID   `json:"tenant_id"`
+	JobType            string      `json:"job_type"`
+	Payload            []byte      `json:"payload"`
+	Status             string      `json:"status"`
+	Attempt            int32       `json:"attempt"`
+	MaxAttempts        int32       `json:"max_attempts"`
+	Error              pgtype.Text `json:"error"`
+	RunAt              time.Time   `json:"run_at"`
+	StartedAt          *time.Time  `json:"started_at"`
+	CompletedAt        *time.Time  `json:"completed_at"`
+	CreatedAt          time.Time   `json:"created_at"`
+	LeaseExpiresAt     *time.Time  `json:"lease_expires_at"`
+	CancelRequested    bool        `json:"cancel_requested"`
+	BackoffBaseSeconds int32       `json:"backoff_base_seconds"`
+	BackoffMaxSeconds  int32       `json:"backoff_max_seconds"`
+	BackoffJitter      float64     `json:"backoff_jitter"`
+	Queue              string      `json:"queue"`
+	Priority           int32       `json:"priority"`
+	Provider           string      `json:"provider"`
+	WorkflowID         pgtype.UUID `json:"workflow_id"`
+	Output             []byte      `json:"output"`
+	BatchID            pgtype.UUID `json:"batch_id"`
+}
+
+type JobAttempt struct {
+	ID         int64       `json:"id"`
+	JobID      uuid.UUID   `json:"job_id"`
+	Attempt    int32       `json:"attempt"`
+	WorkerID   string      `json:"worker_id"`
+	Outcome    string      `json:"outcome"`
+	Error      pgtype.Text `json:"error"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at"`
+	DurationMs pgtype.Int8 `json:"duration_ms"`
 }
 
 type OauthProviderConfig struct {
@@ -87,9 +139,117 @@ type OauthToken struct {
 	UpdatedAt             time.Time  `json:"updated_at"`
 }
 
+type ProviderCircuit struct {
+	TenantID            uuid.UUID   `json:"tenant_id"`
+	Provider            string      `json:"provider"`
+	ConsecutiveFailures int32       `json:"consecutive_failures"`
+	OpenUntil           *time.Time  `json:"open_until"`
+	LastError           pgtype.Text `json:"last_error"`
+	LastFailureAt       *time.Time  `json:"last_failure_at"`
+	UpdatedAt           time.Time   `json:"updated_at"`
+}
+
+type ProviderRateLimit struct {
+	TenantID      uuid.UUID `json:"tenant_id"`
+	Provider      string    `json:"provider"`
+	RatePerSecond float64   `json:"rate_per_second"`
+	Burst         int32     `json:"burst"`
+	Tokens        float64   `json:"tokens"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type Schedule struct {
+	ID                 uuid.UUID  `json:"id"`
+	TenantID           uuid.UUID  `json:"tenant_id"`
+	Name               string     `json:"name"`
+	CronExpr           string     `json:"cron_expr"`
+	Timezone           string     `json:"timezone"`
+	JobType            string     `json:"job_type"`
+	Payload            []byte     `json:"payload"`
+	Paused             bool       `json:"paused"`
+	NextRunAt          time.Time  `json:"next_run_at"`
+	LastRunAt          *time.Time `json:"last_run_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	Queue              string     `json:"queue"`
+	Priority           int32      `json:"priority"`
+	MaxAttempts        int32      `json:"max_attempts"`
+	BackoffBaseSeconds int32      `json:"backoff_base_seconds"`
+	BackoffMaxSeconds  int32      `json:"backoff_max_seconds"`
+	BackoffJitter      float64    `json:"backoff_jitter"`
+}
+
 type Tenant struct {
-	ID               uuid.UUID `json:"id"`
-	ApiKeyHash       string    `json:"api_key_hash"`
-	EncryptedDataKey []byte    `json:"encrypted_data_key"`
-	CreatedAt        time.Time `json:"created_at"`
+	ID                uuid.UUID   `json:"id"`
+	EncryptedDataKey  []byte      `json:"encrypted_data_key"`
+	CreatedAt         time.Time   `json:"created_at"`
+	MaxConcurrentJobs pgtype.Int4 `json:"max_concurrent_jobs"`
+	JobRetentionDays  pgtype.Int4 `json:"job_retention_days"`
+	Name              string      `json:"name"`
+	SuspendedAt       *time.Time  `json:"suspended_at"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID   `json:"id"`
+	EndpointID     uuid.UUID   `json:"endpoint_id"`
+	TenantID       uuid.UUID   `json:"tenant_id"`
+	JobID          uuid.UUID   `json:"job_id"`
+	Event          string      `json:"event"`
+	Data           []byte      `json:"data"`
+	Status         string      `json:"status"`
+	Attempts       int32       `json:"attempts"`
+	MaxAttempts    int32       `json:"max_attempts"`
+	ResponseStatus pgtype.Int4 `json:"response_status"`
+	LastError      pgtype.Text `json:"last_error"`
+	CreatedAt      time.Time   `json:"created_at"`
+	DeliveredAt    *time.Time  `json:"delivered_at"`
+}
+
+type WebhookEndpoint struct {
+	ID              uuid.UUID `json:"id"`
+	TenantID        uuid.UUID `json:"tenant_id"`
+	URL             string    `json:"url"`
+	Events          []string  `json:"events"`
+	EncryptedSecret []byte    `json:"encrypted_secret"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type Worker struct {
+	ID              string     `json:"id"`
+	Hostname        string     `json:"hostname"`
+	Queues          []string   `json:"queues"`
+	Concurrency     int32      `json:"concurrency"`
+	StartedAt       time.Time  `json:"started_at"`
+	LastHeartbeatAt time.Time  `json:"last_heartbeat_at"`
+	StoppedAt       *time.Time `json:"stopped_at"`
+}
+
+type Workflow struct {
+	ID          uuid.UUID  `json:"id"`
+	TenantID    uuid.UUID  `json:"tenant_id"`
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+type WorkflowStep struct {
+	WorkflowID         uuid.UUID   `json:"workflow_id"`
+	Name               string      `json:"name"`
+	Position           int32       `json:"position"`
+	JobType            string      `json:"job_type"`
+	Provider           string      `json:"provider"`
+	Payload            []byte      `json:"payload"`
+	Queue              string      `json:"queue"`
+	Priority           int32       `json:"priority"`
+	MaxAttempts        int32       `json:"max_attempts"`
+	BackoffBaseSeconds int32       `json:"backoff_base_seconds"`
+	BackoffMaxSeconds  int32       `json:"backoff_max_seconds"`
+	BackoffJitter      float64     `json:"backoff_jitter"`
+	OnSuccess          []string    `json:"on_success"`
+	OnFailure          []string    `json:"on_failure"`
+	Status             string      `json:"status"`
+	JobID              pgtype.UUID `json:"job_id"`
+	Output             []byte      `json:"output"`
+	Error              pgtype.Text `json:"error"`
 }
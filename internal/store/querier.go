@@ -11,28 +11,212 @@ import (
 )
 
 type Querier interface {
-	ClaimNextJob(ctx context.Context) (Job, error)
+	// Returns the active (unrevoked, unexpired) key with the given hash and
+	// records its use. last_used_at is only written once a minute per key, so
+	// busy keys do not turn every request into a write.
+	AuthenticateAPIKey(ctx context.Context, keyHash string) (ApiKey, error)
+	// Cancels a job that has not started yet. Returns no rows if the job does not
+	// exist or has already left the pending state.
+	CancelJob(ctx context.Context, arg CancelJobParams) (Job, error)
+	// Claims a due job in one of queues (NULL for any queue) from the first tenant
+	// after after_tenant_id (in id order, wrapping around) that has one, so tenants
+	// are served round-robin rather than strictly by run_at: a tenant with a large
+	// backlog cannot starve the others. Within the tenant, the highest-priority
+	// job is claimed first, oldest first among equals. Tenants already running their max_concurrent_jobs, or
+	// default_tenant_limit if that is NULL, are skipped; a NULL limit is uncapped.
+	// Suspended tenants are skipped too.
+	// Jobs for a provider whose circuit is open, or whose rate limit bucket holds
+	// less than one token, are skipped too; claiming takes a token from the bucket.
+	// Also opens the job_attempts row for the new attempt.
+	ClaimNextJob(ctx context.Context, arg ClaimNextJobParams) (Job, error)
+	CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams) error
+	CountJobsByStatus(ctx context.Context, arg CountJobsByStatusParams) ([]CountJobsByStatusRow, error)
+	// A NULL expires_at never expires.
+	CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error)
+	// Inserts the batch and queues its jobs, given as a JSON array of objects with
+	// the fields named below: a bulk CreateJob. A NULL run_at queues the job to
+	// run immediately. Either every job is queued or none is.
+	CreateBatch(ctx context.Context, arg CreateBatchParams) (Batch, error)
+	// A NULL run_at queues the job to run immediately.
 	CreateJob(ctx context.Context, arg CreateJobParams) (Job, error)
+	CreateSchedule(ctx context.Context, arg CreateScheduleParams) (Schedule, error)
+	// Inserts the tenant with its first API key, named "default".
 	CreateTenant(ctx context.Context, arg CreateTenantParams) (Tenant, error)
+	CreateWebhookEndpoint(ctx context.Context, arg CreateWebhookEndpointParams) (WebhookEndpoint, error)
+	// Inserts the workflow and its steps, given as a JSON array of objects with
+	// the fields named below, and queues a job for each step with queued set: the
+	// steps no edge leads to. Either everything is created or nothing is.
+	CreateWorkflow(ctx context.Context, arg CreateWorkflowParams) (Workflow, error)
 	DeleteEmailTemplate(ctx context.Context, arg DeleteEmailTemplateParams) error
+	// Deletes up to batch_size expired keys, skipping any a request has locked.
+	DeleteExpiredIdempotencyKeys(ctx context.Context, batchSize int32) (int64, error)
 	DeleteOAuthToken(ctx context.Context, arg DeleteOAuthTokenParams) error
+	DeleteProviderRateLimit(ctx context.Context, arg DeleteProviderRateLimitParams) (int64, error)
+	DeleteSchedule(ctx context.Context, arg DeleteScheduleParams) (int64, error)
+	// Prunes workers that stopped, or stopped heartbeating, long ago.
+	DeleteStaleWorkers(ctx context.Context, olderThanSeconds int32) (int64, error)
+	// Deletes a suspended tenant with everything it owns. Active tenants must be
+	// suspended first.
+	DeleteTenant(ctx context.Context, id uuid.UUID) (int64, error)
+	DeleteWebhookEndpoint(ctx context.Context, arg DeleteWebhookEndpointParams) (int64, error)
+	// Records a delivery of event for the finished job to each of its tenant's
+	// endpoints subscribed to it, and queues a webhook.deliver job to send each.
+	// Webhook jobs themselves raise no events.
+	EnqueueWebhookDeliveries(ctx context.Context, arg EnqueueWebhookDeliveriesParams) (int64, error)
+	// Reports whether cancellation of the job has been requested. Returns no rows
+	// once the job is no longer ours: it finished, or the reaper reclaimed it and it
+	// may already be running elsewhere.
+	ExtendJobLease(ctx context.Context, arg ExtendJobLeaseParams) (bool, error)
+	FinishWorkflow(ctx context.Context, arg FinishWorkflowParams) (int64, error)
+	// Copies the outcome of a step's finished job onto the step. Returns no rows
+	// if the job is not a queued step's: a step's outcome is decided once.
+	FinishWorkflowStep(ctx context.Context, jobID uuid.UUID) (WorkflowStep, error)
+	// Advances a due schedule and enqueues its job in a single statement. The
+	// update only matches while next_run_at still equals due_at, so when several
+	// workers race on the same run exactly one inserts a job; the rest get no rows.
+	FireSchedule(ctx context.Context, arg FireScheduleParams) (Job, error)
+	GetAPIKey(ctx context.Context, arg GetAPIKeyParams) (ApiKey, error)
+	// Returns the batch with how many of its jobs are in each status. Jobs purged
+	// past the tenant's retention window are no longer counted.
+	GetBatch(ctx context.Context, arg GetBatchParams) (GetBatchRow, error)
 	GetCodeExecution(ctx context.Context, arg GetCodeExecutionParams) (CodeExecution, error)
 	GetCodeProviderConfig(ctx context.Context, arg GetCodeProviderConfigParams) (CodeProviderConfig, error)
 	GetEmailProviderConfig(ctx context.Context, arg GetEmailProviderConfigParams) (EmailProviderConfig, error)
 	GetEmailTemplate(ctx context.Context, arg GetEmailTemplateParams) (EmailTemplate, error)
+	GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error)
 	GetJob(ctx context.Context, arg GetJobParams) (Job, error)
 	GetOAuthToken(ctx context.Context, arg GetOAuthTokenParams) (OauthToken, error)
 	GetProviderConfig(ctx context.Context, arg GetProviderConfigParams) (OauthProviderConfig, error)
-	GetTenantByAPIKeyHash(ctx context.Context, apiKeyHash string) (Tenant, error)
+	// Per-queue depth across all tenants. pending counts jobs due now; scheduled
+	// counts jobs waiting for a future run_at or a retry backoff.
+	GetQueueStats(ctx context.Context) ([]GetQueueStatsRow, error)
+	GetSchedule(ctx context.Context, arg GetScheduleParams) (Schedule, error)
 	GetTenantByID(ctx context.Context, id uuid.UUID) (Tenant, error)
+	GetWebhookDelivery(ctx context.Context, arg GetWebhookDeliveryParams) (WebhookDelivery, error)
+	GetWebhookEndpoint(ctx context.Context, arg GetWebhookEndpointParams) (WebhookEndpoint, error)
+	GetWorkflow(ctx context.Context, arg GetWorkflowParams) (Workflow, error)
+	// Returns 0 if the worker's row has been pruned and it must register again.
+	HeartbeatWorker(ctx context.Context, id string) (int64, error)
 	InsertCodeExecution(ctx context.Context, arg InsertCodeExecutionParams) (CodeExecution, error)
+	ListAPIKeys(ctx context.Context, tenantID uuid.UUID) ([]ApiKey, error)
+	// Schedules of suspended tenants are held until the tenant is reactivated.
+	ListDueSchedules(ctx context.Context, limit int32) ([]Schedule, error)
 	ListEmailTemplates(ctx context.Context, tenantID uuid.UUID) ([]EmailTemplate, error)
+	ListJobAttempts(ctx context.Context, jobIds []uuid.UUID) ([]JobAttempt, error)
+	// Keyset pagination, newest first: pass the created_at and id of the last job
+	// on the previous page as the cursor. NULL filters match everything.
+	ListJobs(ctx context.Context, arg ListJobsParams) ([]Job, error)
+	// Jobs whose payload predates payload encryption. webhook.deliver payloads
+	// only hold a delivery id and are left in the clear.
+	ListPlaintextJobPayloads(ctx context.Context, limit int32) ([]ListPlaintextJobPayloadsRow, error)
+	// Schedules whose payload predates payload encryption.
+	ListPlaintextSchedulePayloads(ctx context.Context, limit int32) ([]ListPlaintextSchedulePayloadsRow, error)
+	// Workflow steps whose payload predates payload encryption.
+	ListPlaintextWorkflowStepPayloads(ctx context.Context, limit int32) ([]ListPlaintextWorkflowStepPayloadsRow, error)
+	ListProviderCircuits(ctx context.Context) ([]ProviderCircuit, error)
+	ListProviderRateLimits(ctx context.Context, tenantID uuid.UUID) ([]ProviderRateLimit, error)
+	// The running jobs of every tenant with the worker running each one.
+	ListRunningJobs(ctx context.Context) ([]ListRunningJobsRow, error)
+	ListSchedules(ctx context.Context, tenantID uuid.UUID) ([]Schedule, error)
+	// Jobs of queued steps that finished over a minute ago without their outcome
+	// being recorded on the step, because advancing the workflow failed. Jobs
+	// finished without a completion time count from their last start.
+	ListStalledWorkflowSteps(ctx context.Context, limit int32) ([]ListStalledWorkflowStepsRow, error)
+	// Oldest first, starting after the tenant after_id if given.
+	ListTenants(ctx context.Context, arg ListTenantsParams) ([]Tenant, error)
+	// Newest first.
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ListWebhookEndpoints(ctx context.Context, tenantID uuid.UUID) ([]WebhookEndpoint, error)
+	ListWorkers(ctx context.Context) ([]Worker, error)
+	// In the order they were submitted.
+	ListWorkflowSteps(ctx context.Context, workflowID uuid.UUID) ([]WorkflowStep, error)
+	// Newest first.
+	ListWorkflows(ctx context.Context, arg ListWorkflowsParams) ([]Workflow, error)
+	// Locks the workflow of job_id's step until the end of the transaction, so
+	// that the steps of a workflow advance one after another. Returns no rows if
+	// the job is not a workflow step's.
+	LockJobWorkflow(ctx context.Context, jobID uuid.UUID) (uuid.UUID, error)
+	PauseSchedule(ctx context.Context, arg PauseScheduleParams) (Schedule, error)
+	// Deletes up to batch_size jobs that finished longer ago than their tenant's
+	// retention window, or default_days for tenants without one; with neither,
+	// jobs are kept. Attempts, code execution results and webhook deliveries go
+	// with them. Only finished jobs are read and locked, skipping any a retry has
+	// locked, so a purge never blocks claims of pending jobs.
+	PurgeFinishedJobs(ctx context.Context, arg PurgeFinishedJobsParams) (int64, error)
+	// Deletes up to batch_size finished workflows, with their steps, under the
+	// same retention windows as PurgeFinishedJobs.
+	PurgeFinishedWorkflows(ctx context.Context, arg PurgeFinishedWorkflowsParams) (int64, error)
+	// Queues the job for a waiting step with its rendered payload. Returns no rows
+	// if the step is no longer waiting, e.g. because the worker that finished
+	// another of its upstream steps queued it first.
+	QueueWorkflowStep(ctx context.Context, arg QueueWorkflowStepParams) (Job, error)
+	ReactivateTenant(ctx context.Context, id uuid.UUID) (Tenant, error)
+	// Returns jobs abandoned by a crashed worker to the queue. The attempt was
+	// already counted when the job was claimed.
+	ReapExpiredJobs(ctx context.Context) ([]Job, error)
+	// Counts a retryable failure against the provider's circuit and opens it for
+	// open_seconds once failure_threshold failures have happened in a row. A
+	// retry_after_seconds hint from the provider holds the circuit open at least
+	// that long regardless of the count.
+	RecordProviderFailure(ctx context.Context, arg RecordProviderFailureParams) (ProviderCircuit, error)
+	// Closes the provider's circuit.
+	RecordProviderSuccess(ctx context.Context, arg RecordProviderSuccessParams) error
+	// Logs the outcome of one delivery attempt. A delivery that fails its last
+	// attempt, or fails with final set because retrying cannot help, is marked
+	// failed.
+	RecordWebhookAttempt(ctx context.Context, arg RecordWebhookAttemptParams) (WebhookDelivery, error)
+	// Records a worker starting up. A worker restarting under the same id takes
+	// over its previous row.
+	RegisterWorker(ctx context.Context, arg RegisterWorkerParams) (Worker, error)
+	// Frees a key whose request failed so the client can retry it.
+	ReleaseIdempotencyKey(ctx context.Context, arg ReleaseIdempotencyKeyParams) error
+	// Bulk RetryJob for failed jobs created in [created_after, created_before),
+	// optionally limited to one job type. Replays at most max_jobs per call.
+	ReplayFailedJobs(ctx context.Context, arg ReplayFailedJobsParams) (int64, error)
+	// Flags a running job for cooperative cancellation by its worker.
+	RequestJobCancel(ctx context.Context, arg RequestJobCancelParams) (Job, error)
+	// Returns a job interrupted by worker shutdown to the queue without counting
+	// the interrupted attempt against max_attempts. A job whose cancellation was
+	// requested is cancelled instead.
+	RequeueJob(ctx context.Context, arg RequeueJobParams) (int64, error)
+	// Claims key for a new request. Returns no rows if the key is already held by
+	// a live entry; an expired entry, or one whose request has been in progress
+	// for longer than lock_seconds (e.g. the server crashed), is taken over.
+	ReserveIdempotencyKey(ctx context.Context, arg ReserveIdempotencyKeyParams) (IdempotencyKey, error)
+	ResetProviderCircuit(ctx context.Context, arg ResetProviderCircuitParams) (int64, error)
+	// next_run_at is recomputed by the caller so runs missed while paused are skipped.
+	ResumeSchedule(ctx context.Context, arg ResumeScheduleParams) (Schedule, error)
+	// Gives a dead-lettered job a fresh set of attempts. Its attempt history is kept.
+	// Workflow steps are not retried: a step's outcome is decided once, and its
+	// workflow has already moved on.
+	RetryJob(ctx context.Context, arg RetryJobParams) (Job, error)
+	// Revokes an active key unless it is the tenant's last active one, which
+	// would lock the tenant out.
+	RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (int64, error)
+	SealJobPayload(ctx context.Context, arg SealJobPayloadParams) (int64, error)
+	SealSchedulePayload(ctx context.Context, arg SealSchedulePayloadParams) (int64, error)
+	SealWorkflowStepPayload(ctx context.Context, arg SealWorkflowStepPayloadParams) (int64, error)
+	// Records the result an executor reports for a running job.
+	SetJobOutput(ctx context.Context, arg SetJobOutputParams) error
+	SetTenantJobRetention(ctx context.Context, arg SetTenantJobRetentionParams) (Tenant, error)
+	// Marks a waiting step that none of its upstream steps triggered.
+	SkipWorkflowStep(ctx context.Context, arg SkipWorkflowStepParams) (int64, error)
+	StopWorker(ctx context.Context, id string) error
+	// Suspending a suspended tenant keeps its original suspended_at.
+	SuspendTenant(ctx context.Context, id uuid.UUID) (Tenant, error)
+	// The status/attempt guard fences out a worker whose lease was reaped.
+	// Also closes the attempt's job_attempts row; an attempt that left the job
+	// pending for a retry is recorded as failed.
 	UpdateJobStatus(ctx context.Context, arg UpdateJobStatusParams) (Job, error)
+	UpdateSchedule(ctx context.Context, arg UpdateScheduleParams) (Schedule, error)
 	UpsertCodeProviderConfig(ctx context.Context, arg UpsertCodeProviderConfigParams) (CodeProviderConfig, error)
 	UpsertEmailProviderConfig(ctx context.Context, arg UpsertEmailProviderConfigParams) (EmailProviderConfig, error)
 	UpsertEmailTemplate(ctx context.Context, arg UpsertEmailTemplateParams) (EmailTemplate, error)
 	UpsertOAuthToken(ctx context.Context, arg UpsertOAuthTokenParams) (OauthToken, error)
 	UpsertProviderConfig(ctx context.Context, arg UpsertProviderConfigParams) (OauthProviderConfig, error)
+	// Changing an existing limit keeps the tokens already accrued, capped at the
+	// new burst.
+	UpsertProviderRateLimit(ctx context.Context, arg UpsertProviderRateLimitParams) (ProviderRateLimit, error)
 }
 
 var _ Querier = (*Queries)(nil)
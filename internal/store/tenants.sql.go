@@ -9,61 +9,185 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const createTenant = `-- name: CreateTenant :one
-INSERT INTO tenants (api_key_hash, encrypted_data_key)
-VALUES ($1, $2)
-RETURNING id, api_key_hash, encrypted_data_key, created_at
+WITH tenant AS (
+    INSERT INTO tenants (name, encrypted_data_key)
+    VALUES ($1, $2)
+    RETURNING id, encrypted_data_key, created_at, max_concurrent_jobs, job_retention_days, name, suspended_at
+), first_key AS (
+    INSERT INTO api_keys (tenant_id, name, key_hash)
+    SELECT id, 'default', $3 FROM tenant
+)
+SELECT id, encrypted_data_key, created_at, max_concurrent_jobs, job_retention_days, name, suspended_at FROM tenant
 `
 
 type CreateTenantParams struct {
-	ApiKeyHash       string `json:"api_key_hash"`
+	Name             string `json:"name"`
 	EncryptedDataKey []byte `json:"encrypted_data_key"`
+	KeyHash          string `json:"key_hash"`
 }
 
+// Inserts the tenant with its first API key, named "default".
 func (q *Queries) CreateTenant(ctx context.Context, arg CreateTenantParams) (Tenant, error) {
-	row := q.db.QueryRow(ctx, createTenant, arg.ApiKeyHash, arg.EncryptedDataKey)
+	row := q.db.QueryRow(ctx, createTenant, arg.Name, arg.EncryptedDataKey, arg.KeyHash)
 	var i Tenant
 	err := row.Scan(
 		&i.ID,
-		&i.ApiKeyHash,
 		&i.EncryptedDataKey,
 		&i.CreatedAt,
+		&i.MaxConcurrentJobs,
+		&i.JobRetentionDays,
+		&i.Name,
+		&i.SuspendedAt,
 	)
 	return i, err
 }
 
-const getTenantByAPIKeyHash = `-- name: GetTenantByAPIKeyHash :one
-SELECT id, api_key_hash, encrypted_data_key, created_at FROM tenants
-WHERE api_key_hash = $1
+const deleteTenant = `-- name: DeleteTenant :execrows
+DELETE FROM tenants
+WHERE id = $1 AND suspended_at IS NOT NULL
 `
 
-func (q *Queries) GetTenantByAPIKeyHash(ctx context.Context, apiKeyHash string) (Tenant, error) {
-	row := q.db.QueryRow(ctx, getTenantByAPIKeyHash, apiKeyHash)
+// Deletes a suspended tenant with everything it owns. Active tenants must be
+// suspended first.
+func (q *Queries) DeleteTenant(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteTenant, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getTenantByID = `-- name: GetTenantByID :one
+SELECT id, encrypted_data_key, created_at, max_concurrent_jobs, job_retention_days, name, suspended_at FROM tenants
+WHERE id = $1
+`
+
+func (q *Queries) GetTenantByID(ctx context.Context, id uuid.UUID) (Tenant, error) {
+	row := q.db.QueryRow(ctx, getTenantByID, id)
 	var i Tenant
 	err := row.Scan(
 		&i.ID,
-		&i.ApiKeyHash,
 		&i.EncryptedDataKey,
 		&i.CreatedAt,
+		&i.MaxConcurrentJobs,
+		&i.JobRetentionDays,
+		&i.Name,
+		&i.SuspendedAt,
 	)
 	return i, err
 }
 
-const getTenantByID = `-- name: GetTenantByID :one
-SELECT id, api_key_hash, encrypted_data_key, created_at FROM tenants
+const listTenants = `-- name: ListTenants :many
+SELECT id, encrypted_data_key, created_at, max_concurrent_jobs, job_retention_days, name, suspended_at FROM tenants
+WHERE $1::uuid IS NULL
+   OR (created_at, id) > (SELECT a.created_at, a.id FROM tenants a WHERE a.id = $1::uuid)
+ORDER BY created_at, id
+LIMIT $2
+`
+
+type ListTenantsParams struct {
+	AfterID    pgtype.UUID `json:"after_id"`
+	MaxResults int32       `json:"max_results"`
+}
+
+// Oldest first, starting after the tenant after_id if given.
+func (q *Queries) ListTenants(ctx context.Context, arg ListTenantsParams) ([]Tenant, error) {
+	rows, err := q.db.Query(ctx, listTenants, arg.AfterID, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Tenant
+	for rows.Next() {
+		var i Tenant
+		if err := rows.Scan(
+			&i.ID,
+			&i.EncryptedDataKey,
+			&i.CreatedAt,
+			&i.MaxConcurrentJobs,
+			&i.JobRetentionDays,
+			&i.Name,
+			&i.SuspendedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const reactivateTenant = `-- name: ReactivateTenant :one
+UPDATE tenants SET suspended_at = NULL
 WHERE id = $1
+RETURNING id, encrypted_data_key, created_at, max_concurrent_jobs, job_retention_days, name, suspended_at
 `
 
-func (q *Queries) GetTenantByID(ctx context.Context, id uuid.UUID) (Tenant, error) {
-	row := q.db.QueryRow(ctx, getTenantByID, id)
+func (q *Queries) ReactivateTenant(ctx context.Context, id uuid.UUID) (Tenant, error) {
+	row := q.db.QueryRow(ctx, reactivateTenant, id)
+	var i Tenant
+	err := row.Scan(
+		&i.ID,
+		&i.EncryptedDataKey,
+		&i.CreatedAt,
+		&i.MaxConcurrentJobs,
+		&i.JobRetentionDays,
+		&i.Name,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const setTenantJobRetention = `-- name: SetTenantJobRetention :one
+UPDATE tenants SET job_retention_days = $2
+WHERE id = $1
+RETURNING id, encrypted_data_key, created_at, max_concurrent_jobs, job_retention_days, name, suspended_at
+`
+
+type SetTenantJobRetentionParams struct {
+	ID               uuid.UUID   `json:"id"`
+	JobRetentionDays pgtype.Int4 `json:"job_retention_days"`
+}
+
+func (q *Queries) SetTenantJobRetention(ctx context.Context, arg SetTenantJobRetentionParams) (Tenant, error) {
+	row := q.db.QueryRow(ctx, setTenantJobRetention, arg.ID, arg.JobRetentionDays)
+	var i Tenant
+	err := row.Scan(
+		&i.ID,
+		&i.EncryptedDataKey,
+		&i.CreatedAt,
+		&i.MaxConcurrentJobs,
+		&i.JobRetentionDays,
+		&i.Name,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const suspendTenant = `-- name: SuspendTenant :one
+UPDATE tenants SET suspended_at = COALESCE(suspended_at, NOW())
+WHERE id = $1
+RETURNING id, encrypted_data_key, created_at, max_concurrent_jobs, job_retention_days, name, suspended_at
+`
+
+// Suspending a suspended tenant keeps its original suspended_at.
+func (q *Queries) SuspendTenant(ctx context.Context, id uuid.UUID) (Tenant, error) {
+	row := q.db.QueryRow(ctx, suspendTenant, id)
 	var i Tenant
 	err := row.Scan(
 		&i.ID,
-		&i.ApiKeyHash,
 		&i.EncryptedDataKey,
 		&i.CreatedAt,
+		&i.MaxConcurrentJobs,
+		&i.JobRetentionDays,
+		&i.Name,
+		&i.SuspendedAt,
 	)
 	return i, err
 }
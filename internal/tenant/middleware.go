@@ -9,9 +9,14 @@ import (
 	"github.com/gsarma/tusker/internal/store"
 )
 
-const ctxKey = "tenant"
+const (
+	ctxKey    = "tenant"
+	keyCtxKey = "api_key"
+)
 
-// AuthMiddleware validates the Bearer API key and sets the tenant in context.
+// AuthMiddleware validates the Bearer API key and sets the tenant and the key
+// in context. Requests of suspended tenants are rejected. Routes check the
+// key's scopes with RequireScope.
 func (s *Service) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		header := c.GetHeader("Authorization")
@@ -21,13 +26,18 @@ func (s *Service) AuthMiddleware() gin.HandlerFunc {
 		}
 		rawKey := strings.TrimPrefix(header, "Bearer ")
 
-		t, err := s.GetByAPIKey(c.Request.Context(), rawKey)
+		t, key, err := s.GetByAPIKey(c.Request.Context(), rawKey)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
 			return
 		}
+		if t.SuspendedAt != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "tenant is suspended"})
+			return
+		}
 
 		c.Set(ctxKey, t)
+		c.Set(keyCtxKey, key)
 		c.Next()
 	}
 }
@@ -0,0 +1,87 @@
+package tenant
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gsarma/tusker/internal/store"
+)
+
+// Scopes an API key may carry. Each route requires one; ScopeAll grants every
+// scope, including ones added later, and is what the tenant's first key and
+// keys created before scopes existed hold.
+const (
+	ScopeAll              = "*"
+	ScopeEmailSend        = "email:send"
+	ScopeSMSSend          = "sms:send"
+	ScopeCodeExecute      = "code:execute"
+	ScopeOAuthAuthorize   = "oauth:authorize"
+	ScopeOAuthTokenRead   = "oauth:token:read"
+	ScopeOAuthTokenDelete = "oauth:token:delete"
+	ScopeConfigRead       = "config:read"
+	ScopeConfigWrite      = "config:write"
+	ScopeJobsRead         = "jobs:read"
+	ScopeJobsWrite        = "jobs:write"
+	ScopeSchedulesWrite   = "schedules:write"
+	ScopeKeysManage       = "keys:manage"
+)
+
+var validScopes = map[string]bool{
+	ScopeAll:              true,
+	ScopeEmailSend:        true,
+	ScopeSMSSend:          true,
+	ScopeCodeExecute:      true,
+	ScopeOAuthAuthorize:   true,
+	ScopeOAuthTokenRead:   true,
+	ScopeOAuthTokenDelete: true,
+	ScopeConfigRead:       true,
+	ScopeConfigWrite:      true,
+	ScopeJobsRead:         true,
+	ScopeJobsWrite:        true,
+	ScopeSchedulesWrite:   true,
+	ScopeKeysManage:       true,
+}
+
+// ValidScope reports whether scope is one an API key may carry.
+func ValidScope(scope string) bool {
+	return validScopes[scope]
+}
+
+// HasScope reports whether the API key that authenticated the request grants
+// scope.
+func HasScope(c *gin.Context, scope string) bool {
+	k := KeyFromContext(c)
+	if k == nil {
+		return false
+	}
+	return slices.Contains(k.Scopes, ScopeAll) || slices.Contains(k.Scopes, scope)
+}
+
+// RequireScope rejects requests whose API key lacks scope with a 403 naming
+// it. It must run after AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasScope(c, scope) {
+			AbortMissingScope(c, scope)
+			return
+		}
+		c.Next()
+	}
+}
+
+// AbortMissingScope writes the 403 for a request whose API key lacks scope.
+func AbortMissingScope(c *gin.Context, scope string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"error":         "API key is missing scope " + scope,
+		"missing_scope": scope,
+	})
+}
+
+// KeyFromContext retrieves the API key that authenticated the request.
+func KeyFromContext(c *gin.Context) *store.ApiKey {
+	k, _ := c.Get(keyCtxKey)
+	key, _ := k.(*store.ApiKey)
+	return key
+}
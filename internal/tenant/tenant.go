@@ -27,23 +27,23 @@ func NewService(db *pgxpool.Pool, enc *crypto.Encryptor) *Service {
 	}
 }
 
-// Create provisions a new tenant, returning the raw API key (shown once).
-func (s *Service) Create(ctx context.Context) (apiKey string, tenantID uuid.UUID, err error) {
-	rawKey, err := generateAPIKey()
+// Create provisions a new tenant with an API key named "default", returning
+// the raw key (shown once).
+func (s *Service) Create(ctx context.Context, name string) (apiKey string, tenantID uuid.UUID, err error) {
+	rawKey, keyHash, err := NewAPIKey()
 	if err != nil {
 		return "", uuid.Nil, err
 	}
 
-	keyHash := hashAPIKey(rawKey)
-
 	_, encDataKey, err := s.enc.GenerateDataKey()
 	if err != nil {
 		return "", uuid.Nil, err
 	}
 
 	t, err := s.queries.CreateTenant(ctx, store.CreateTenantParams{
-		ApiKeyHash:       keyHash,
+		Name:             name,
 		EncryptedDataKey: encDataKey,
+		KeyHash:          keyHash,
 	})
 	if err != nil {
 		return "", uuid.Nil, err
@@ -52,14 +52,18 @@ func (s *Service) Create(ctx context.Context) (apiKey string, tenantID uuid.UUID
 	return rawKey, t.ID, nil
 }
 
-// GetByAPIKey resolves a tenant from a raw API key.
-func (s *Service) GetByAPIKey(ctx context.Context, rawKey string) (*store.Tenant, error) {
-	hash := hashAPIKey(rawKey)
-	t, err := s.queries.GetTenantByAPIKeyHash(ctx, hash)
+// GetByAPIKey resolves a tenant and the matching key from any of its active
+// API keys.
+func (s *Service) GetByAPIKey(ctx context.Context, rawKey string) (*store.Tenant, *store.ApiKey, error) {
+	key, err := s.queries.AuthenticateAPIKey(ctx, hashAPIKey(rawKey))
+	if err != nil {
+		return nil, nil, errors.New("invalid API key")
+	}
+	t, err := s.queries.GetTenantByID(ctx, key.TenantID)
 	if err != nil {
-		return nil, errors.New("invalid API key")
+		return nil, nil, errors.New("invalid API key")
 	}
-	return &t, nil
+	return &t, &key, nil
 }
 
 // DataKey decrypts and returns the tenant's plaintext data key.
@@ -67,6 +71,15 @@ func (s *Service) DataKey(t *store.Tenant) ([]byte, error) {
 	return s.enc.DecryptDataKey(t.EncryptedDataKey)
 }
 
+// NewAPIKey generates a raw API key and the hash stored in its place.
+func NewAPIKey() (rawKey, keyHash string, err error) {
+	rawKey, err = generateAPIKey()
+	if err != nil {
+		return "", "", err
+	}
+	return rawKey, hashAPIKey(rawKey), nil
+}
+
 func hashAPIKey(rawKey string) string {
 	sum := sha256.Sum256([]byte(rawKey))
 	return hex.EncodeToString(sum[:])
@@ -0,0 +1,202 @@
+// Package webhook signs and delivers job event notifications to tenants'
+// HTTP endpoints.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gsarma/tusker/internal/worker"
+)
+
+// Headers set on every delivery.
+const (
+	SignatureHeader = "Tusker-Signature"
+	EventHeader     = "Tusker-Event"
+	DeliveryHeader  = "Tusker-Delivery"
+)
+
+// Event is the JSON body POSTed to an endpoint.
+type Event struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// GenerateSecret returns a new random signing secret.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value for body sent at ts:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>" keyed by secret>".
+// Including the timestamp lets receivers reject replayed deliveries.
+func Sign(secret string, ts time.Time, body []byte) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ErrPrivateAddress is returned for deliveries to an address that is not
+// publicly routable, e.g. loopback, RFC 1918 or link-local (cloud metadata)
+// addresses, which tenants must not reach through the worker.
+var ErrPrivateAddress = errors.New("webhook destination is not a public address")
+
+var (
+	thisNetwork        = netip.MustParsePrefix("0.0.0.0/8")
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+)
+
+// publicAddr reports whether ip is publicly routable.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() &&
+		!thisNetwork.Contains(ip) && !sharedAddressSpace.Contains(ip)
+}
+
+// CheckURL checks that raw is an absolute http or https URL whose host is not
+// obviously private: a loopback, private or link-local IP literal, or
+// localhost. Hostnames are checked again against the addresses they resolve
+// to when a delivery is sent.
+func CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateAddress
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !publicAddr(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// checkDial is a net.Dialer Control hook that refuses connections to
+// addresses that are not public, whatever hostname resolved to them.
+func checkDial(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, ip)
+	}
+	return nil
+}
+
+// Sender POSTs signed events. It only connects to public addresses and does
+// not follow redirects, so tenants cannot use deliveries to reach services
+// on the worker's network.
+type Sender struct {
+	client          *http.Client
+	privateNetworks bool
+}
+
+// SenderOption configures a Sender.
+type SenderOption func(*Sender)
+
+// WithPrivateNetworks lets deliveries reach loopback, private and link-local
+// addresses, e.g. in tests.
+func WithPrivateNetworks() SenderOption {
+	return func(s *Sender) {
+		s.privateNetworks = true
+	}
+}
+
+func NewSender(opts ...SenderOption) *Sender {
+	s := &Sender{}
+	for _, o := range opts {
+		o(s)
+	}
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	if !s.privateNetworks {
+		dialer.Control = checkDial
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Connect to endpoints directly: through a proxy, the dial check would
+	// only see the proxy's address.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	s.client = &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return s
+}
+
+// Send delivers ev to url signed with secret and returns the response status
+// code, or 0 if no response was received. Any non-2xx response, redirects
+// included, is an error, retryable unless the endpoint answered 410 Gone; a
+// 429 or 503 honours its Retry-After header. Deliveries to private addresses
+// fail permanently.
+func (s *Sender) Send(ctx context.Context, url, secret string, ev Event) (int, error) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return 0, worker.Permanent(fmt.Errorf("marshal webhook event: %w", err))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, worker.Permanent(fmt.Errorf("build webhook request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Tusker-Webhooks/1.0")
+	req.Header.Set(EventHeader, ev.Type)
+	req.Header.Set(DeliveryHeader, ev.ID.String())
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+
+	resp, err := s.client.Do(req)
+	if errors.Is(err, ErrPrivateAddress) {
+		return 0, worker.Permanent(fmt.Errorf("webhook request: %w", err))
+	}
+	if err != nil {
+		return 0, fmt.Errorf("webhook request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, nil
+	}
+	err = fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	switch resp.StatusCode {
+	case http.StatusGone:
+		return resp.StatusCode, worker.Permanent(err)
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return resp.StatusCode, worker.HTTPError(resp, err)
+	}
+	return resp.StatusCode, err
+}
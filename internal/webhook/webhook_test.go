@@ -0,0 +1,149 @@
+package webhook_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gsarma/tusker/internal/webhook"
+	"github.com/gsarma/tusker/internal/worker"
+)
+
+func TestSign(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"job.completed"}`)
+	got := webhook.Sign("whsec_test", ts, body)
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+	if got != want {
+		t.Errorf("Sign = %q, want %q", got, want)
+	}
+}
+
+func TestSender_Send(t *testing.T) {
+	ev := webhook.Event{
+		ID:        uuid.New(),
+		Type:      "job.failed",
+		CreatedAt: time.Now().UTC(),
+		Data:      json.RawMessage(`{"job_id":"x"}`),
+	}
+	var gotHeader http.Header
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	status, err := webhook.NewSender(webhook.WithPrivateNetworks()).Send(context.Background(), srv.URL, "whsec_test", ev)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("Send = %d, %v", status, err)
+	}
+	if gotHeader.Get(webhook.EventHeader) != "job.failed" || gotHeader.Get(webhook.DeliveryHeader) != ev.ID.String() {
+		t.Errorf("unexpected headers: %v", gotHeader)
+	}
+	sig := gotHeader.Get(webhook.SignatureHeader)
+	ts, _, _ := strings.Cut(strings.TrimPrefix(sig, "t="), ",")
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte(ts + "."))
+	mac.Write(gotBody)
+	if !strings.HasSuffix(sig, ",v1="+hex.EncodeToString(mac.Sum(nil))) {
+		t.Errorf("signature %q does not match body", sig)
+	}
+}
+
+func TestSender_SendErrors(t *testing.T) {
+	cases := []struct {
+		status        int
+		wantPermanent bool
+	}{
+		{http.StatusInternalServerError, false},
+		{http.StatusNotFound, false},
+		{http.StatusGone, true},
+	}
+	for _, tc := range cases {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+		}))
+		status, err := webhook.NewSender(webhook.WithPrivateNetworks()).Send(context.Background(), srv.URL, "s", webhook.Event{ID: uuid.New()})
+		srv.Close()
+		if err == nil || status != tc.status {
+			t.Errorf("status %d: Send = %d, %v", tc.status, status, err)
+			continue
+		}
+		if worker.IsPermanent(err) != tc.wantPermanent {
+			t.Errorf("status %d: permanent = %v, want %v", tc.status, !tc.wantPermanent, tc.wantPermanent)
+		}
+	}
+}
+
+func TestSender_RefusesPrivateAddresses(t *testing.T) {
+	hit := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+	}))
+	defer srv.Close()
+
+	// srv listens on loopback, as would an internal service.
+	status, err := webhook.NewSender().Send(context.Background(), srv.URL, "s", webhook.Event{ID: uuid.New()})
+	if !errors.Is(err, webhook.ErrPrivateAddress) || !worker.IsPermanent(err) || status != 0 {
+		t.Errorf("Send = %d, %v; want a permanent ErrPrivateAddress", status, err)
+	}
+	if hit {
+		t.Error("expected no request to reach the loopback server")
+	}
+}
+
+func TestSender_DoesNotFollowRedirects(t *testing.T) {
+	hit := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer srv.Close()
+
+	status, err := webhook.NewSender(webhook.WithPrivateNetworks()).Send(context.Background(), srv.URL, "s", webhook.Event{ID: uuid.New()})
+	if err == nil || status != http.StatusFound {
+		t.Errorf("Send = %d, %v; want a failed 302", status, err)
+	}
+	if hit {
+		t.Error("expected the redirect not to be followed")
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	for raw, ok := range map[string]bool{
+		"https://example.com/hooks":       true,
+		"http://203.0.113.10:8080/hooks":  true,
+		"ftp://example.com/hooks":         false,
+		"/hooks":                          false,
+		"http://localhost:8080/hooks":     false,
+		"http://127.0.0.1/hooks":          false,
+		"http://10.0.0.5/hooks":           false,
+		"http://192.168.1.1/hooks":        false,
+		"http://169.254.169.254/latest":   false,
+		"http://100.100.100.200/latest":   false,
+		"http://[::1]/hooks":              false,
+		"http://[fd00::1]/hooks":          false,
+		"http://[::ffff:127.0.0.1]/hooks": false,
+	} {
+		if err := webhook.CheckURL(raw); (err == nil) != ok {
+			t.Errorf("CheckURL(%q) = %v, want ok=%v", raw, err, ok)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/gsarma/tusker/internal/store"
+)
+
+// recordProviderOutcome feeds a finished attempt into the circuit breaker for
+// the job's tenant and provider. Permanent errors say nothing about the
+// provider's health (the request itself was bad), so they are not counted.
+func (w *Worker) recordProviderOutcome(ctx context.Context, job store.Job, execErr error) {
+	if job.Provider == "" || IsPermanent(execErr) {
+		return
+	}
+	if execErr == nil {
+		err := w.store.RecordProviderSuccess(ctx, store.RecordProviderSuccessParams{
+			TenantID: job.TenantID,
+			Provider: job.Provider,
+		})
+		if err != nil {
+			log.Printf("worker: record provider success error for job %s: %v", job.ID, err)
+		}
+		return
+	}
+
+	var retryAfter int32
+	if d, ok := retryDelay(execErr); ok {
+		retryAfter = int32((d + time.Second - 1) / time.Second)
+	}
+	circuit, err := w.store.RecordProviderFailure(ctx, store.RecordProviderFailureParams{
+		TenantID:          job.TenantID,
+		Provider:          job.Provider,
+		FailureThreshold:  w.breakerThreshold,
+		OpenSeconds:       int32((w.breakerCooldown + time.Second - 1) / time.Second),
+		RetryAfterSeconds: retryAfter,
+		Error:             pgtype.Text{String: execErr.Error(), Valid: true},
+	})
+	if err != nil {
+		log.Printf("worker: record provider failure error for job %s: %v", job.ID, err)
+		return
+	}
+	if circuit.OpenUntil != nil && circuit.OpenUntil.After(time.Now()) {
+		log.Printf("worker: circuit for %s open for tenant %s until %s after %d consecutive failures",
+			job.Provider, job.TenantID, circuit.OpenUntil.Format(time.RFC3339), circuit.ConsecutiveFailures)
+	}
+}
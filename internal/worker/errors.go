@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PermanentError marks a job failure that retrying cannot fix, such as a
+// provider rejecting an invalid address. The worker fails the job immediately
+// instead of spending its remaining attempts.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// Permanent wraps err so the worker does not retry the job. It returns nil if
+// err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent reports whether err, or any error it wraps, is a PermanentError.
+func IsPermanent(err error) bool {
+	var p *PermanentError
+	return errors.As(err, &p)
+}
+
+// RetryAfterError is a retryable failure for which the provider said when to
+// try again, typically via a Retry-After header on a 429 or 503 response. The
+// worker schedules the next attempt after Delay instead of its usual backoff.
+type RetryAfterError struct {
+	Err   error
+	Delay time.Duration
+}
+
+func (e *RetryAfterError) Error() string { return e.Err.Error() }
+func (e *RetryAfterError) Unwrap() error { return e.Err }
+
+// RetryAfter wraps err with a hint to retry no sooner than d from now. It
+// returns nil if err is nil.
+func RetryAfter(err error, d time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &RetryAfterError{Err: err, Delay: d}
+}
+
+// retryDelay returns the provider-supplied delay carried by err, if any.
+func retryDelay(err error) (time.Duration, bool) {
+	var r *RetryAfterError
+	if errors.As(err, &r) && r.Delay > 0 {
+		return r.Delay, true
+	}
+	return 0, false
+}
+
+// HTTPError classifies err, the error for a non-2xx provider response, by the
+// response's status code:
+//
+//   - 408, 425 and 429 are retryable; 429 honours a Retry-After header.
+//   - Any other 4xx is permanent: the request itself was rejected.
+//   - 503 honours a Retry-After header; other 5xx are retryable.
+func HTTPError(resp *http.Response, err error) error {
+	switch code := resp.StatusCode; {
+	case code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable:
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return RetryAfter(err, d)
+		}
+	case code == http.StatusRequestTimeout || code == http.StatusTooEarly:
+	case code >= 400 && code < 500:
+		return Permanent(err)
+	}
+	return err
+}
+
+// parseRetryAfter parses a Retry-After header given as delay-seconds or as an
+// HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, secs > 0
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now), true
+	}
+	return 0, false
+}
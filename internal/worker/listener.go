@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// JobsChannel is the Postgres NOTIFY channel the jobs table trigger publishes to
+// whenever a job becomes claimable (see migration 000005_jobs_notify).
+const JobsChannel = "tusker_jobs"
+
+// JobEventsChannel is the Postgres NOTIFY channel carrying every job status
+// transition as JSON (see migration 000018_job_events).
+const JobEventsChannel = "tusker_job_events"
+
+// Listener delivers wake-up signals to the worker.
+// Listen blocks until ctx is cancelled or the underlying connection fails,
+// calling notify with the payload of every notification received.
+type Listener interface {
+	Listen(ctx context.Context, notify func(payload string)) error
+}
+
+// PGListener is a Listener backed by a dedicated Postgres connection running LISTEN.
+type PGListener struct {
+	pool    *pgxpool.Pool
+	channel string
+}
+
+// NewPGListener creates a PGListener for the given NOTIFY channel.
+func NewPGListener(pool *pgxpool.Pool, channel string) *PGListener {
+	return &PGListener{pool: pool, channel: channel}
+}
+
+func (l *PGListener) Listen(ctx context.Context, notify func(payload string)) error {
+	pooled, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire listen connection: %w", err)
+	}
+	// Take the connection out of the pool for good: a connection left in LISTEN
+	// mode must never be handed back out to run unrelated queries.
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{l.channel}.Sanitize()); err != nil {
+		return fmt.Errorf("listen %s: %w", l.channel, err)
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		notify(n.Payload)
+	}
+}
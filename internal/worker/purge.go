@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/gsarma/tusker/internal/store"
+)
+
+const (
+	// purgeBatchSize caps how many rows one purge statement deletes, keeping
+	// each transaction short.
+	purgeBatchSize = 1000
+	// purgeBatchPause spaces out batches so a large backlog does not saturate
+	// the database.
+	purgeBatchPause = 100 * time.Millisecond
+)
+
+// purge periodically deletes finished jobs and workflows that have outlived
+// their tenant's retention window, expired idempotency keys, and workers long
+// gone from the registry, and advances workflows whose steps were left
+// stalled. Every worker runs it; concurrent purges skip each other's rows.
+func (w *Worker) purge(ctx context.Context) {
+	ticker := time.NewTicker(w.purgeInterval)
+	defer ticker.Stop()
+	for {
+		w.purgeFinished(ctx)
+		w.purgeIdempotencyKeys(ctx)
+		w.pruneWorkers(ctx)
+		w.resumeWorkflows(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) purgeFinished(ctx context.Context) {
+	var defaultDays pgtype.Int4
+	if w.retentionDays > 0 {
+		defaultDays = pgtype.Int4{Int32: w.retentionDays, Valid: true}
+	}
+	jobs := w.purgeBatches(ctx, "jobs", func() (int64, error) {
+		return w.store.PurgeFinishedJobs(ctx, store.PurgeFinishedJobsParams{DefaultDays: defaultDays, BatchSize: purgeBatchSize})
+	})
+	workflows := w.purgeBatches(ctx, "workflows", func() (int64, error) {
+		return w.store.PurgeFinishedWorkflows(ctx, store.PurgeFinishedWorkflowsParams{DefaultDays: defaultDays, BatchSize: purgeBatchSize})
+	})
+	if jobs > 0 || workflows > 0 {
+		log.Printf("worker: purged %d jobs and %d workflows past their retention window", jobs, workflows)
+	}
+}
+
+func (w *Worker) purgeIdempotencyKeys(ctx context.Context) {
+	n := w.purgeBatches(ctx, "idempotency keys", func() (int64, error) {
+		return w.store.DeleteExpiredIdempotencyKeys(ctx, purgeBatchSize)
+	})
+	if n > 0 {
+		log.Printf("worker: purged %d expired idempotency keys", n)
+	}
+}
+
+// purgeBatches runs purge until it deletes less than a full batch, returning
+// the number of rows deleted.
+func (w *Worker) purgeBatches(ctx context.Context, what string, purge func() (int64, error)) int64 {
+	var total int64
+	for {
+		n, err := purge()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("worker: purge %s error: %v", what, err)
+			}
+			return total
+		}
+		total += n
+		if n < purgeBatchSize {
+			return total
+		}
+		select {
+		case <-ctx.Done():
+			return total
+		case <-time.After(purgeBatchPause):
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gsarma/tusker/internal/store"
+)
+
+// staleWorkerAge is how long after its last heartbeat a worker is dropped
+// from the registry.
+const staleWorkerAge = 24 * time.Hour
+
+// register records the worker in the workers table with the queues and
+// concurrency it serves. The registry is informational: failures are logged
+// and the worker runs regardless.
+func (w *Worker) register(ctx context.Context) {
+	host, _ := os.Hostname()
+	var queues []string
+	concurrency := 0
+	for _, p := range w.pools {
+		if p.queues == nil {
+			queues = append(queues, "*")
+		} else {
+			queues = append(queues, p.queues...)
+		}
+		concurrency += p.size
+	}
+	_, err := w.store.RegisterWorker(ctx, store.RegisterWorkerParams{
+		ID:          w.id,
+		Hostname:    host,
+		Queues:      queues,
+		Concurrency: int32(concurrency),
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("worker: register error: %v", err)
+	}
+}
+
+// keepAlive keeps the worker's registry entry fresh until ctx is cancelled,
+// registering again if the entry has been pruned.
+func (w *Worker) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(w.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		n, err := w.store.HeartbeatWorker(ctx, w.id)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("worker: heartbeat error: %v", err)
+			}
+			continue
+		}
+		if n == 0 {
+			w.register(ctx)
+		}
+	}
+}
+
+// deregister marks the worker stopped once its jobs have drained.
+func (w *Worker) deregister(ctx context.Context) {
+	if err := w.store.StopWorker(context.WithoutCancel(ctx), w.id); err != nil {
+		log.Printf("worker: deregister error: %v", err)
+	}
+}
+
+// pruneWorkers drops registry entries of workers gone for staleWorkerAge.
+func (w *Worker) pruneWorkers(ctx context.Context) {
+	if _, err := w.store.DeleteStaleWorkers(ctx, int32(staleWorkerAge/time.Second)); err != nil && ctx.Err() == nil {
+		log.Printf("worker: prune workers error: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/gsarma/tusker/internal/cron"
+	"github.com/gsarma/tusker/internal/store"
+)
+
+// scheduleBatchSize caps how many due schedules are fired per tick.
+const scheduleBatchSize = 100
+
+// schedule materializes due cron schedules into jobs until ctx is cancelled.
+// Every worker runs it; FireSchedule guarantees each run is enqueued once.
+func (w *Worker) schedule(ctx context.Context) {
+	ticker := time.NewTicker(w.scheduleTick)
+	defer ticker.Stop()
+	for {
+		w.fireDueSchedules(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) fireDueSchedules(ctx context.Context) {
+	due, err := w.store.ListDueSchedules(ctx, scheduleBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("worker: list due schedules error: %v", err)
+		}
+		return
+	}
+
+	for _, s := range due {
+		// Runs missed while no worker was up are collapsed into this one:
+		// the next run is computed from now, not from the missed slot.
+		after := time.Now()
+		if s.NextRunAt.After(after) {
+			after = s.NextRunAt
+		}
+		next, err := cron.NextRun(s.CronExpr, s.Timezone, after)
+		if err != nil {
+			// Validated on write, so this only happens if the row was edited by hand.
+			log.Printf("worker: schedule %s has an invalid cron expression, pausing: %v", s.ID, err)
+			if _, err := w.store.PauseSchedule(ctx, store.PauseScheduleParams{ID: s.ID, TenantID: s.TenantID}); err != nil {
+				log.Printf("worker: pause schedule %s error: %v", s.ID, err)
+			}
+			continue
+		}
+
+		job, err := w.store.FireSchedule(ctx, store.FireScheduleParams{
+			NextRunAt: next,
+			DueAt:     s.NextRunAt,
+			ID:        s.ID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue // another worker fired this run first, or it was paused/edited
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("worker: fire schedule %s error: %v", s.ID, err)
+			}
+			continue
+		}
+		log.Printf("worker: schedule %s (%s) queued job %s, next run %s",
+			s.ID, s.Name, job.ID, next.UTC().Format(time.RFC3339))
+	}
+}
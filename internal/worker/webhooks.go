@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gsarma/tusker/internal/store"
+)
+
+// Job events delivered to tenants' webhook endpoints.
+const (
+	EventJobCompleted = "job.completed"
+	EventJobFailed    = "job.failed"
+)
+
+// Webhook deliveries retry on their own policy, independent of the job that
+// raised the event: up to 8 attempts over roughly four hours.
+const (
+	webhookMaxAttempts = 8
+	webhookBackoffBase = 30 * time.Second
+	webhookBackoffMax  = time.Hour
+)
+
+// emitJobEvent queues webhook deliveries of event for job to the tenant's
+// subscribed endpoints.
+func (w *Worker) emitJobEvent(ctx context.Context, job store.Job, event string) {
+	_, err := w.store.EnqueueWebhookDeliveries(ctx, store.EnqueueWebhookDeliveriesParams{
+		Event:              event,
+		MaxAttempts:        webhookMaxAttempts,
+		JobID:              job.ID,
+		BackoffBaseSeconds: int32(webhookBackoffBase / time.Second),
+		BackoffMaxSeconds:  int32(webhookBackoffMax / time.Second),
+	})
+	if err != nil {
+		log.Printf("worker: enqueue %s webhooks error for job %s: %v", event, job.ID, err)
+	}
+}
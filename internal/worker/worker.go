@@ -4,7 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,80 +19,473 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 
 	"github.com/gsarma/tusker/internal/store"
+	"github.com/gsarma/tusker/internal/workflow"
 )
 
 // JobExecutor executes a single job by type and payload.
+// Returned errors are retried with exponential backoff unless wrapped with
+// Permanent or RetryAfter.
 type JobExecutor interface {
 	ExecuteJob(ctx context.Context, jobID uuid.UUID, tenantID uuid.UUID, jobType string, payload json.RawMessage) error
 }
 
-// Worker polls the database for pending jobs and executes them concurrently.
+// Worker claims pending jobs from the database and executes them concurrently.
+// Claims rotate round-robin across tenants with due jobs, so one tenant's
+// backlog does not delay everyone else's jobs.
 type Worker struct {
-	store       store.Querier
-	executor    JobExecutor
-	concurrency int
+	store        store.Querier
+	executor     JobExecutor
+	pools        []*pool
+	listener     Listener
+	pollInterval time.Duration
+	lease        time.Duration
+	drainTimeout time.Duration
+	scheduleTick time.Duration
+	id           string
+	tenantLimit  int32
+
+	retentionDays     int32
+	purgeInterval     time.Duration
+	heartbeatInterval time.Duration
+
+	breakerThreshold int32
+	breakerCooldown  time.Duration
+
+	workflowTx workflow.Transactor
+
+	// lastTenant is the tenant whose job was claimed most recently; the next
+	// claim starts from the tenant after it.
+	mu         sync.Mutex
+	lastTenant uuid.UUID
+}
+
+// pool is a set of goroutines claiming jobs from the same queues.
+type pool struct {
+	queues []string // nil serves every queue
+	size   int
+	wake   chan struct{}
+}
+
+func (p *pool) serves(queue string) bool {
+	return p.queues == nil || slices.Contains(p.queues, queue)
+}
+
+// Option configures a Worker.
+type Option func(*Worker)
+
+// WithListener wakes idle goroutines as soon as l reports a new job.
+// Polling is kept as a slow fallback for scheduled run_at jobs and for
+// notifications missed while the listen connection is down.
+func WithListener(l Listener) Option {
+	return func(w *Worker) {
+		w.listener = l
+	}
+}
+
+// WithPollInterval sets how often an idle goroutine polls for jobs.
+// Defaults to 500ms, or 5s when a Listener is configured.
+func WithPollInterval(d time.Duration) Option {
+	return func(w *Worker) {
+		w.pollInterval = d
+	}
+}
+
+// WithLease sets how long a claimed job stays reserved without a heartbeat.
+// The worker extends the lease every third of this duration while the job runs;
+// if the process dies, the reaper returns the job to the queue once it expires.
+// Defaults to one minute.
+func WithLease(d time.Duration) Option {
+	return func(w *Worker) {
+		w.lease = d
+	}
+}
+
+// WithShutdownTimeout bounds how long Start waits for in-flight jobs once its
+// context is cancelled. Jobs still running at the deadline are interrupted and
+// returned to the queue. Defaults to 30s.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(w *Worker) {
+		w.drainTimeout = d
+	}
+}
+
+// WithScheduleInterval sets how often the worker checks for due cron schedules.
+// Defaults to 5s.
+func WithScheduleInterval(d time.Duration) Option {
+	return func(w *Worker) {
+		w.scheduleTick = d
+	}
+}
+
+// WithWorkerID sets the name recorded against each job attempt this worker
+// runs. Defaults to "<hostname>-<pid>".
+func WithWorkerID(id string) Option {
+	return func(w *Worker) {
+		w.id = id
+	}
+}
+
+// WithQueue adds concurrency goroutines dedicated to the named queue, on top
+// of the concurrency goroutines passed to New that serve every queue. Pass 0
+// to New to serve only the queues added with WithQueue, e.g. for a worker
+// container reserved for transactional mail.
+func WithQueue(name string, concurrency int) Option {
+	return func(w *Worker) {
+		w.pools = append(w.pools, &pool{queues: []string{name}, size: concurrency})
+	}
+}
+
+// WithTenantConcurrency caps how many jobs of any one tenant may run at once
+// across all workers, for tenants without their own max_concurrent_jobs.
+// Defaults to 0, meaning no cap.
+func WithTenantConcurrency(n int) Option {
+	return func(w *Worker) {
+		w.tenantLimit = int32(n)
+	}
+}
+
+// WithCircuitBreaker opens a tenant's circuit for a provider after threshold
+// consecutive retryable failures, pausing claims of the tenant's jobs for that
+// provider for cooldown. The first job claimed afterwards probes the provider:
+// success closes the circuit, failure reopens it. Defaults to 5 failures and
+// 30s.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(w *Worker) {
+		w.breakerThreshold = int32(threshold)
+		w.breakerCooldown = cooldown
+	}
 }
 
-func New(q store.Querier, executor JobExecutor, concurrency int) *Worker {
-	return &Worker{
-		store:       q,
-		executor:    executor,
-		concurrency: concurrency,
+// WithRetention sets how many days finished jobs and workflows are kept for
+// tenants without their own job_retention_days. Older completed, failed and
+// cancelled jobs are purged along with their attempts, code execution results
+// and webhook deliveries. Defaults to 0, meaning such tenants' jobs are kept.
+func WithRetention(days int) Option {
+	return func(w *Worker) {
+		w.retentionDays = int32(days)
 	}
 }
 
-// Start spawns concurrency goroutines that each poll for jobs every 500ms.
-// It blocks until ctx is cancelled.
+// WithPurgeInterval sets how often the worker purges jobs past their
+// retention window and expired idempotency keys. Defaults to 10 minutes.
+func WithPurgeInterval(d time.Duration) Option {
+	return func(w *Worker) {
+		w.purgeInterval = d
+	}
+}
+
+// WithHeartbeatInterval sets how often the worker refreshes its entry in the
+// workers table. Defaults to 15s.
+func WithHeartbeatInterval(d time.Duration) Option {
+	return func(w *Worker) {
+		w.heartbeatInterval = d
+	}
+}
+
+// WithWorkflowTx advances workflows in transactions run by tx, typically
+// workflow.InTx(pool). Without it workflows are advanced on the worker's store
+// outside a transaction, which only suits stores that cannot begin one.
+func WithWorkflowTx(tx workflow.Transactor) Option {
+	return func(w *Worker) {
+		w.workflowTx = tx
+	}
+}
+
+const (
+	defaultPollInterval         = 500 * time.Millisecond
+	defaultListenerPollInterval = 5 * time.Second
+	defaultLease                = time.Minute
+	defaultShutdownTimeout      = 30 * time.Second
+	defaultScheduleInterval     = 5 * time.Second
+	defaultPurgeInterval        = 10 * time.Minute
+	defaultHeartbeatInterval    = 15 * time.Second
+	defaultBackoffBase          = 10 * time.Second
+	defaultBackoffMax           = time.Hour
+	defaultBreakerThreshold     = 5
+	defaultBreakerCooldown      = 30 * time.Second
+	listenRetryDelay            = time.Second
+)
+
+// New creates a Worker with concurrency goroutines that serve every queue.
+func New(q store.Querier, executor JobExecutor, concurrency int, opts ...Option) *Worker {
+	w := &Worker{
+		store:    q,
+		executor: executor,
+	}
+	for _, o := range opts {
+		o(w)
+	}
+	// Dedicated pools come first so wake-ups prefer them.
+	if concurrency > 0 {
+		w.pools = append(w.pools, &pool{size: concurrency})
+	}
+	for _, p := range w.pools {
+		p.wake = make(chan struct{}, p.size)
+	}
+	if w.pollInterval == 0 {
+		w.pollInterval = defaultPollInterval
+		if w.listener != nil {
+			w.pollInterval = defaultListenerPollInterval
+		}
+	}
+	if w.lease < time.Second {
+		w.lease = defaultLease
+	}
+	if w.drainTimeout == 0 {
+		w.drainTimeout = defaultShutdownTimeout
+	}
+	if w.scheduleTick <= 0 {
+		w.scheduleTick = defaultScheduleInterval
+	}
+	if w.purgeInterval <= 0 {
+		w.purgeInterval = defaultPurgeInterval
+	}
+	if w.heartbeatInterval <= 0 {
+		w.heartbeatInterval = defaultHeartbeatInterval
+	}
+	if w.breakerThreshold <= 0 {
+		w.breakerThreshold = defaultBreakerThreshold
+	}
+	if w.breakerCooldown <= 0 {
+		w.breakerCooldown = defaultBreakerCooldown
+	}
+	if w.workflowTx == nil {
+		w.workflowTx = workflow.Direct(q)
+	}
+	if w.id == "" {
+		host, _ := os.Hostname()
+		w.id = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	return w
+}
+
+// Start spawns the worker's goroutines, which each claim and execute jobs from
+// their queues until none are due, then sleep until woken by the listener or
+// the poll interval.
+//
+// It blocks until ctx is cancelled and in-flight jobs have drained. Cancelling
+// ctx stops new claims immediately; running jobs get up to the shutdown timeout
+// to finish before their contexts are cancelled and they are re-queued.
 func (w *Worker) Start(ctx context.Context) {
-	for i := 0; i < w.concurrency; i++ {
-		go w.loop(ctx)
+	// Jobs run on a context that outlives ctx so in-flight work can finish after
+	// shutdown begins; it is only cancelled once the drain deadline passes.
+	runCtx, interrupt := context.WithCancel(context.WithoutCancel(ctx))
+	defer interrupt()
+
+	w.register(ctx)
+	defer w.deregister(ctx)
+	go w.keepAlive(ctx)
+	if w.listener != nil {
+		go w.listen(ctx)
+	}
+	go w.reap(ctx)
+	go w.schedule(ctx)
+	go w.purge(ctx)
+
+	var wg sync.WaitGroup
+	for _, p := range w.pools {
+		for i := 0; i < p.size; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w.loop(ctx, runCtx, p)
+			}()
+		}
 	}
 	<-ctx.Done()
+
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return
+	case <-time.After(w.drainTimeout):
+		log.Printf("worker: shutdown timeout (%s) exceeded, interrupting in-flight jobs", w.drainTimeout)
+		interrupt()
+	}
+	<-drained
 }
 
-func (w *Worker) loop(ctx context.Context) {
-	ticker := time.NewTicker(500 * time.Millisecond)
+// listen keeps the listener connected, reconnecting after failures.
+func (w *Worker) listen(ctx context.Context) {
+	for {
+		err := w.listener.Listen(ctx, w.signal)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("worker: listen error: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+// signal wakes one idle goroutine serving the queue named in payload
+// ("<queue>:<job id>"; any goroutine if it carries no queue). If every such
+// goroutine is already busy the signal is dropped: busy goroutines drain their
+// queues before going idle again.
+func (w *Worker) signal(payload string) {
+	queue, _, found := strings.Cut(payload, ":")
+	for _, p := range w.pools {
+		if found && !p.serves(queue) {
+			continue
+		}
+		select {
+		case p.wake <- struct{}{}:
+			return
+		default:
+		}
+	}
+}
+
+// reap periodically returns jobs whose lease expired to the queue. It runs once
+// immediately so jobs orphaned by a previous crash are recovered on startup.
+func (w *Worker) reap(ctx context.Context) {
+	ticker := time.NewTicker(w.lease / 2)
 	defer ticker.Stop()
 	for {
+		jobs, err := w.store.ReapExpiredJobs(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("worker: reap error: %v", err)
+		}
+		for _, job := range jobs {
+			log.Printf("worker: reclaimed job %s (attempt %d/%d) after lease expiry, now %s",
+				job.ID, job.Attempt, job.MaxAttempts, job.Status)
+			if job.Status == "failed" {
+				w.emitJobEvent(ctx, job, EventJobFailed)
+			}
+			if job.Status == "failed" || job.Status == "cancelled" {
+				w.advanceWorkflow(ctx, job)
+			}
+		}
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			w.processNext(ctx)
 		}
 	}
 }
 
-func (w *Worker) processNext(ctx context.Context) {
-	job, err := w.store.ClaimNextJob(ctx)
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+func (w *Worker) loop(ctx, runCtx context.Context, p *pool) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+	for {
+		// Drain the queue before going idle so a burst of jobs is not
+		// throttled to one per wake-up.
+		for ctx.Err() == nil && w.processNext(ctx, runCtx, p.queues) {
+		}
+		select {
+		case <-ctx.Done():
 			return
+		case <-ticker.C:
+		case <-p.wake:
 		}
-		log.Printf("worker: claim error: %v", err)
-		return
 	}
+}
+
+// processNext claims a job from queues (nil for any) using ctx and executes it
+// under runCtx. It reports whether a job was claimed so the caller knows to
+// keep draining the queue.
+func (w *Worker) processNext(ctx, runCtx context.Context, queues []string) bool {
+	w.mu.Lock()
+	after := w.lastTenant
+	w.mu.Unlock()
+	job, err := w.store.ClaimNextJob(ctx, store.ClaimNextJobParams{
+		DefaultTenantLimit: pgtype.Int4{Int32: w.tenantLimit, Valid: w.tenantLimit > 0},
+		AfterTenantID:      after,
+		Queues:             queues,
+		LeaseSeconds:       w.leaseSeconds(),
+		WorkerID:           w.id,
+	})
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) && ctx.Err() == nil {
+			log.Printf("worker: claim error: %v", err)
+		}
+		return false
+	}
+	w.mu.Lock()
+	w.lastTenant = job.TenantID
+	w.mu.Unlock()
 
-	execErr := w.executor.ExecuteJob(ctx, job.ID, job.TenantID, job.JobType, json.RawMessage(job.Payload))
+	jobCtx, cancelJob := context.WithCancel(runCtx)
+	var cancelRequested atomic.Bool
+	stopHeartbeat := w.heartbeat(jobCtx, job, cancelJob, &cancelRequested)
+	execErr := w.executor.ExecuteJob(jobCtx, job.ID, job.TenantID, job.JobType, json.RawMessage(job.Payload))
+	stopHeartbeat()
+	// Done before cancelJob only if the job was interrupted: its lease lost,
+	// a cancellation requested or the shutdown deadline reached.
+	interrupted := jobCtx.Err() != nil
+	cancelJob()
 
+	// Record the outcome even when shutdown has cancelled ctx.
+	ctx = context.WithoutCancel(ctx)
 	now := time.Now()
+
+	if execErr != nil && cancelRequested.Load() {
+		// Abandoned at the tenant's request; a job that finished anyway is
+		// recorded as completed below.
+		updated, err := w.store.UpdateJobStatus(ctx, store.UpdateJobStatusParams{
+			ID:          job.ID,
+			Status:      "cancelled",
+			Error:       pgtype.Text{String: "cancelled by request", Valid: true},
+			CompletedAt: &now,
+			RunAt:       job.RunAt,
+			Attempt:     job.Attempt,
+		})
+		if err != nil {
+			log.Printf("worker: mark cancelled error for job %s: %v", job.ID, describeUpdateErr(err))
+		} else {
+			w.advanceWorkflow(ctx, updated)
+		}
+		return true
+	}
+
+	if execErr != nil && runCtx.Err() != nil {
+		// Interrupted by the shutdown deadline rather than a genuine failure.
+		n, err := w.store.RequeueJob(ctx, store.RequeueJobParams{ID: job.ID, Attempt: job.Attempt})
+		if err != nil {
+			log.Printf("worker: requeue error for job %s: %v", job.ID, err)
+		} else if n == 1 {
+			log.Printf("worker: re-queued job %s interrupted by shutdown", job.ID)
+		}
+		return true
+	}
+
+	if !interrupted {
+		// An interrupted job's error says nothing about the provider.
+		w.recordProviderOutcome(ctx, job, execErr)
+	}
+
 	if execErr == nil {
-		_, err = w.store.UpdateJobStatus(ctx, store.UpdateJobStatusParams{
+		updated, err := w.store.UpdateJobStatus(ctx, store.UpdateJobStatusParams{
 			ID:          job.ID,
 			Status:      "completed",
 			Error:       pgtype.Text{Valid: false},
 			CompletedAt: &now,
 			RunAt:       job.RunAt,
+			Attempt:     job.Attempt,
 		})
 		if err != nil {
-			log.Printf("worker: mark completed error: %v", err)
+			log.Printf("worker: mark completed error for job %s: %v", job.ID, describeUpdateErr(err))
+		} else {
+			w.emitJobEvent(ctx, job, EventJobCompleted)
+			w.advanceWorkflow(ctx, updated)
 		}
-		return
+		return true
 	}
 
-	// Job failed — retry or mark as permanently failed.
-	if job.Attempt < job.MaxAttempts {
-		backoff := time.Duration(int64(1)<<uint(job.Attempt)) * 10 * time.Second
+	// Job failed — retry or mark as permanently failed. Permanent errors skip
+	// the remaining attempts; a provider's retry-after hint replaces the backoff.
+	if job.Attempt < job.MaxAttempts && !IsPermanent(execErr) {
+		backoff := retryBackoff(job)
+		if d, ok := retryDelay(execErr); ok {
+			backoff = d
+		}
 		runAt := now.Add(backoff)
 		_, err = w.store.UpdateJobStatus(ctx, store.UpdateJobStatusParams{
 			ID:          job.ID,
@@ -93,17 +493,109 @@ func (w *Worker) processNext(ctx context.Context) {
 			Error:       pgtype.Text{String: execErr.Error(), Valid: true},
 			CompletedAt: nil,
 			RunAt:       runAt,
+			Attempt:     job.Attempt,
 		})
 	} else {
-		_, err = w.store.UpdateJobStatus(ctx, store.UpdateJobStatusParams{
+		var updated store.Job
+		updated, err = w.store.UpdateJobStatus(ctx, store.UpdateJobStatusParams{
 			ID:          job.ID,
 			Status:      "failed",
 			Error:       pgtype.Text{String: execErr.Error(), Valid: true},
-			CompletedAt: nil,
+			CompletedAt: &now,
 			RunAt:       job.RunAt,
+			Attempt:     job.Attempt,
 		})
+		if err == nil {
+			w.emitJobEvent(ctx, job, EventJobFailed)
+			w.advanceWorkflow(ctx, updated)
+		}
 	}
 	if err != nil {
-		log.Printf("worker: update status error: %v", err)
+		log.Printf("worker: update status error for job %s: %v", job.ID, describeUpdateErr(err))
+	}
+	return true
+}
+
+// retryBackoff returns how long to wait before retrying job after its current
+// attempt failed: the job's base delay doubled per attempt, capped at its max
+// delay, less a random fraction of up to its jitter.
+func retryBackoff(job store.Job) time.Duration {
+	base := time.Duration(job.BackoffBaseSeconds) * time.Second
+	if base <= 0 {
+		base = defaultBackoffBase
+	}
+	maxDelay := time.Duration(job.BackoffMaxSeconds) * time.Second
+	if maxDelay <= 0 {
+		maxDelay = defaultBackoffMax
+	}
+
+	d := base
+	for i := int32(0); i < job.Attempt && d < maxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, maxDelay)
+	if job.BackoffJitter > 0 {
+		d -= time.Duration(rand.Float64() * min(job.BackoffJitter, 1) * float64(d))
+	}
+	return d
+}
+
+func (w *Worker) leaseSeconds() int32 {
+	return int32(w.lease / time.Second)
+}
+
+// heartbeat extends job's lease until the returned stop function is called.
+// If the lease turns out to have been lost to the reaper, cancel is called so
+// the executor can abandon work that is now owned by another worker. cancel is
+// also called, after setting cancelRequested, when the tenant cancels the job.
+func (w *Worker) heartbeat(ctx context.Context, job store.Job, cancel context.CancelFunc, cancelRequested *atomic.Bool) (stop func()) {
+	hbCtx, stopCtx := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(w.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-hbCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			requested, err := w.store.ExtendJobLease(hbCtx, store.ExtendJobLeaseParams{
+				LeaseSeconds: w.leaseSeconds(),
+				ID:           job.ID,
+				Attempt:      job.Attempt,
+			})
+			if errors.Is(err, pgx.ErrNoRows) {
+				log.Printf("worker: lost lease on job %s, cancelling", job.ID)
+				cancel()
+				return
+			}
+			if err != nil {
+				if hbCtx.Err() == nil {
+					log.Printf("worker: heartbeat error for job %s: %v", job.ID, err)
+				}
+				continue
+			}
+			if requested {
+				log.Printf("worker: cancellation requested for job %s", job.ID)
+				cancelRequested.Store(true)
+				cancel()
+				return
+			}
+		}
+	}()
+	return func() {
+		stopCtx()
+		<-done
+	}
+}
+
+// describeUpdateErr explains the ErrNoRows returned when a status update is
+// fenced out because the job's lease was reaped while it was executing.
+func describeUpdateErr(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errors.New("lease lost; job was reclaimed by another worker")
 	}
+	return err
 }
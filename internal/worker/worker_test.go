@@ -4,6 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -13,16 +19,38 @@ import (
 
 	"github.com/gsarma/tusker/internal/store"
 	"github.com/gsarma/tusker/internal/worker"
+	"github.com/gsarma/tusker/internal/workflow"
 )
 
 // stubQuerier implements store.Querier for worker tests.
-// Only ClaimNextJob and UpdateJobStatus are exercised; all others return zero values.
+// Only the job lifecycle queries are exercised; all others return zero values.
 type stubQuerier struct {
 	claimNextJobFn    func(ctx context.Context) (store.Job, error)
+	claimArgsFn       func(ctx context.Context, arg store.ClaimNextJobParams) (store.Job, error)
 	updateJobStatusFn func(ctx context.Context, arg store.UpdateJobStatusParams) (store.Job, error)
+	extendJobLeaseFn  func(ctx context.Context, arg store.ExtendJobLeaseParams) (bool, error)
+	reapExpiredJobsFn func(ctx context.Context) ([]store.Job, error)
+	requeueJobFn      func(ctx context.Context, arg store.RequeueJobParams) (int64, error)
+	listDueSchedFn    func(ctx context.Context) ([]store.Schedule, error)
+	fireScheduleFn    func(ctx context.Context, arg store.FireScheduleParams) (store.Job, error)
+	pauseScheduleFn   func(ctx context.Context, arg store.PauseScheduleParams) (store.Schedule, error)
+	providerFailureFn func(ctx context.Context, arg store.RecordProviderFailureParams) (store.ProviderCircuit, error)
+	providerSuccessFn func(ctx context.Context, arg store.RecordProviderSuccessParams) error
+	enqueueWebhookFn  func(ctx context.Context, arg store.EnqueueWebhookDeliveriesParams) (int64, error)
+	finishStepFn      func(ctx context.Context, jobID uuid.UUID) (store.WorkflowStep, error)
+	stalledStepsFn    func(ctx context.Context, limit int32) ([]store.ListStalledWorkflowStepsRow, error)
+	purgeJobsFn       func(ctx context.Context, arg store.PurgeFinishedJobsParams) (int64, error)
+	purgeWorkflowsFn  func(ctx context.Context, arg store.PurgeFinishedWorkflowsParams) (int64, error)
+	purgeIdemKeysFn   func(ctx context.Context, batchSize int32) (int64, error)
+	registerWorkerFn  func(ctx context.Context, arg store.RegisterWorkerParams) (store.Worker, error)
+	heartbeatFn       func(ctx context.Context, id string) (int64, error)
+	stopWorkerFn      func(ctx context.Context, id string) error
 }
 
-func (s *stubQuerier) ClaimNextJob(ctx context.Context) (store.Job, error) {
+func (s *stubQuerier) ClaimNextJob(ctx context.Context, arg store.ClaimNextJobParams) (store.Job, error) {
+	if s.claimArgsFn != nil {
+		return s.claimArgsFn(ctx, arg)
+	}
 	if s.claimNextJobFn != nil {
 		return s.claimNextJobFn(ctx)
 	}
@@ -34,6 +62,42 @@ func (s *stubQuerier) UpdateJobStatus(ctx context.Context, arg store.UpdateJobSt
 	}
 	return store.Job{}, nil
 }
+func (s *stubQuerier) ExtendJobLease(ctx context.Context, arg store.ExtendJobLeaseParams) (bool, error) {
+	if s.extendJobLeaseFn != nil {
+		return s.extendJobLeaseFn(ctx, arg)
+	}
+	return false, nil
+}
+func (s *stubQuerier) ReapExpiredJobs(ctx context.Context) ([]store.Job, error) {
+	if s.reapExpiredJobsFn != nil {
+		return s.reapExpiredJobsFn(ctx)
+	}
+	return nil, nil
+}
+func (s *stubQuerier) RequeueJob(ctx context.Context, arg store.RequeueJobParams) (int64, error) {
+	if s.requeueJobFn != nil {
+		return s.requeueJobFn(ctx, arg)
+	}
+	return 1, nil
+}
+func (s *stubQuerier) ListDueSchedules(ctx context.Context, limit int32) ([]store.Schedule, error) {
+	if s.listDueSchedFn != nil {
+		return s.listDueSchedFn(ctx)
+	}
+	return nil, nil
+}
+func (s *stubQuerier) FireSchedule(ctx context.Context, arg store.FireScheduleParams) (store.Job, error) {
+	if s.fireScheduleFn != nil {
+		return s.fireScheduleFn(ctx, arg)
+	}
+	return store.Job{}, pgx.ErrNoRows
+}
+func (s *stubQuerier) PauseSchedule(ctx context.Context, arg store.PauseScheduleParams) (store.Schedule, error) {
+	if s.pauseScheduleFn != nil {
+		return s.pauseScheduleFn(ctx, arg)
+	}
+	return store.Schedule{}, nil
+}
 func (s *stubQuerier) CreateJob(ctx context.Context, arg store.CreateJobParams) (store.Job, error) {
 	return store.Job{}, nil
 }
@@ -55,8 +119,8 @@ func (s *stubQuerier) GetOAuthToken(ctx context.Context, arg store.GetOAuthToken
 func (s *stubQuerier) GetProviderConfig(ctx context.Context, arg store.GetProviderConfigParams) (store.OauthProviderConfig, error) {
 	return store.OauthProviderConfig{}, nil
 }
-func (s *stubQuerier) GetTenantByAPIKeyHash(ctx context.Context, apiKeyHash string) (store.Tenant, error) {
-	return store.Tenant{}, nil
+func (s *stubQuerier) AuthenticateAPIKey(ctx context.Context, keyHash string) (store.ApiKey, error) {
+	return store.ApiKey{}, nil
 }
 func (s *stubQuerier) GetTenantByID(ctx context.Context, id uuid.UUID) (store.Tenant, error) {
 	return store.Tenant{}, nil
@@ -91,9 +155,252 @@ func (s *stubQuerier) UpsertCodeProviderConfig(ctx context.Context, arg store.Up
 func (s *stubQuerier) InsertCodeExecution(ctx context.Context, arg store.InsertCodeExecutionParams) (store.CodeExecution, error) {
 	return store.CodeExecution{}, nil
 }
+func (s *stubQuerier) CreateSchedule(ctx context.Context, arg store.CreateScheduleParams) (store.Schedule, error) {
+	return store.Schedule{}, nil
+}
+func (s *stubQuerier) GetSchedule(ctx context.Context, arg store.GetScheduleParams) (store.Schedule, error) {
+	return store.Schedule{}, nil
+}
+func (s *stubQuerier) ListSchedules(ctx context.Context, tenantID uuid.UUID) ([]store.Schedule, error) {
+	return nil, nil
+}
+func (s *stubQuerier) UpdateSchedule(ctx context.Context, arg store.UpdateScheduleParams) (store.Schedule, error) {
+	return store.Schedule{}, nil
+}
+func (s *stubQuerier) ResumeSchedule(ctx context.Context, arg store.ResumeScheduleParams) (store.Schedule, error) {
+	return store.Schedule{}, nil
+}
+func (s *stubQuerier) DeleteSchedule(ctx context.Context, arg store.DeleteScheduleParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) CancelJob(ctx context.Context, arg store.CancelJobParams) (store.Job, error) {
+	return store.Job{}, nil
+}
+func (s *stubQuerier) RequestJobCancel(ctx context.Context, arg store.RequestJobCancelParams) (store.Job, error) {
+	return store.Job{}, nil
+}
+func (s *stubQuerier) ListJobs(ctx context.Context, arg store.ListJobsParams) ([]store.Job, error) {
+	return nil, nil
+}
+func (s *stubQuerier) CountJobsByStatus(ctx context.Context, arg store.CountJobsByStatusParams) ([]store.CountJobsByStatusRow, error) {
+	return nil, nil
+}
+func (s *stubQuerier) RetryJob(ctx context.Context, arg store.RetryJobParams) (store.Job, error) {
+	return store.Job{}, nil
+}
+func (s *stubQuerier) ReplayFailedJobs(ctx context.Context, arg store.ReplayFailedJobsParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) ListJobAttempts(ctx context.Context, jobIds []uuid.UUID) ([]store.JobAttempt, error) {
+	return nil, nil
+}
+func (s *stubQuerier) ReserveIdempotencyKey(ctx context.Context, arg store.ReserveIdempotencyKeyParams) (store.IdempotencyKey, error) {
+	return store.IdempotencyKey{}, nil
+}
+func (s *stubQuerier) GetIdempotencyKey(ctx context.Context, arg store.GetIdempotencyKeyParams) (store.IdempotencyKey, error) {
+	return store.IdempotencyKey{}, nil
+}
+func (s *stubQuerier) CompleteIdempotencyKey(ctx context.Context, arg store.CompleteIdempotencyKeyParams) error {
+	return nil
+}
+func (s *stubQuerier) ReleaseIdempotencyKey(ctx context.Context, arg store.ReleaseIdempotencyKeyParams) error {
+	return nil
+}
 func (s *stubQuerier) GetCodeExecution(ctx context.Context, arg store.GetCodeExecutionParams) (store.CodeExecution, error) {
 	return store.CodeExecution{}, nil
 }
+func (s *stubQuerier) RecordProviderFailure(ctx context.Context, arg store.RecordProviderFailureParams) (store.ProviderCircuit, error) {
+	if s.providerFailureFn != nil {
+		return s.providerFailureFn(ctx, arg)
+	}
+	return store.ProviderCircuit{}, nil
+}
+func (s *stubQuerier) RecordProviderSuccess(ctx context.Context, arg store.RecordProviderSuccessParams) error {
+	if s.providerSuccessFn != nil {
+		return s.providerSuccessFn(ctx, arg)
+	}
+	return nil
+}
+func (s *stubQuerier) UpsertProviderRateLimit(ctx context.Context, arg store.UpsertProviderRateLimitParams) (store.ProviderRateLimit, error) {
+	return store.ProviderRateLimit{}, nil
+}
+func (s *stubQuerier) ListProviderRateLimits(ctx context.Context, tenantID uuid.UUID) ([]store.ProviderRateLimit, error) {
+	return nil, nil
+}
+func (s *stubQuerier) DeleteProviderRateLimit(ctx context.Context, arg store.DeleteProviderRateLimitParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) ListProviderCircuits(ctx context.Context) ([]store.ProviderCircuit, error) {
+	return nil, nil
+}
+func (s *stubQuerier) ResetProviderCircuit(ctx context.Context, arg store.ResetProviderCircuitParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) EnqueueWebhookDeliveries(ctx context.Context, arg store.EnqueueWebhookDeliveriesParams) (int64, error) {
+	if s.enqueueWebhookFn != nil {
+		return s.enqueueWebhookFn(ctx, arg)
+	}
+	return 0, nil
+}
+func (s *stubQuerier) CreateWebhookEndpoint(ctx context.Context, arg store.CreateWebhookEndpointParams) (store.WebhookEndpoint, error) {
+	return store.WebhookEndpoint{}, nil
+}
+func (s *stubQuerier) GetWebhookEndpoint(ctx context.Context, arg store.GetWebhookEndpointParams) (store.WebhookEndpoint, error) {
+	return store.WebhookEndpoint{}, nil
+}
+func (s *stubQuerier) ListWebhookEndpoints(ctx context.Context, tenantID uuid.UUID) ([]store.WebhookEndpoint, error) {
+	return nil, nil
+}
+func (s *stubQuerier) DeleteWebhookEndpoint(ctx context.Context, arg store.DeleteWebhookEndpointParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) GetWebhookDelivery(ctx context.Context, arg store.GetWebhookDeliveryParams) (store.WebhookDelivery, error) {
+	return store.WebhookDelivery{}, nil
+}
+func (s *stubQuerier) RecordWebhookAttempt(ctx context.Context, arg store.RecordWebhookAttemptParams) (store.WebhookDelivery, error) {
+	return store.WebhookDelivery{}, nil
+}
+func (s *stubQuerier) ListWebhookDeliveries(ctx context.Context, arg store.ListWebhookDeliveriesParams) ([]store.WebhookDelivery, error) {
+	return nil, nil
+}
+func (s *stubQuerier) ListPlaintextJobPayloads(ctx context.Context, limit int32) ([]store.ListPlaintextJobPayloadsRow, error) {
+	return nil, nil
+}
+func (s *stubQuerier) SealJobPayload(ctx context.Context, arg store.SealJobPayloadParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) ListPlaintextSchedulePayloads(ctx context.Context, limit int32) ([]store.ListPlaintextSchedulePayloadsRow, error) {
+	return nil, nil
+}
+func (s *stubQuerier) SealSchedulePayload(ctx context.Context, arg store.SealSchedulePayloadParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) ListPlaintextWorkflowStepPayloads(ctx context.Context, limit int32) ([]store.ListPlaintextWorkflowStepPayloadsRow, error) {
+	return nil, nil
+}
+func (s *stubQuerier) SealWorkflowStepPayload(ctx context.Context, arg store.SealWorkflowStepPayloadParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) PurgeFinishedJobs(ctx context.Context, arg store.PurgeFinishedJobsParams) (int64, error) {
+	if s.purgeJobsFn != nil {
+		return s.purgeJobsFn(ctx, arg)
+	}
+	return 0, nil
+}
+func (s *stubQuerier) DeleteExpiredIdempotencyKeys(ctx context.Context, batchSize int32) (int64, error) {
+	if s.purgeIdemKeysFn != nil {
+		return s.purgeIdemKeysFn(ctx, batchSize)
+	}
+	return 0, nil
+}
+func (s *stubQuerier) PurgeFinishedWorkflows(ctx context.Context, arg store.PurgeFinishedWorkflowsParams) (int64, error) {
+	if s.purgeWorkflowsFn != nil {
+		return s.purgeWorkflowsFn(ctx, arg)
+	}
+	return 0, nil
+}
+func (s *stubQuerier) SetTenantJobRetention(ctx context.Context, arg store.SetTenantJobRetentionParams) (store.Tenant, error) {
+	return store.Tenant{}, nil
+}
+func (s *stubQuerier) RegisterWorker(ctx context.Context, arg store.RegisterWorkerParams) (store.Worker, error) {
+	if s.registerWorkerFn != nil {
+		return s.registerWorkerFn(ctx, arg)
+	}
+	return store.Worker{ID: arg.ID}, nil
+}
+func (s *stubQuerier) HeartbeatWorker(ctx context.Context, id string) (int64, error) {
+	if s.heartbeatFn != nil {
+		return s.heartbeatFn(ctx, id)
+	}
+	return 1, nil
+}
+func (s *stubQuerier) StopWorker(ctx context.Context, id string) error {
+	if s.stopWorkerFn != nil {
+		return s.stopWorkerFn(ctx, id)
+	}
+	return nil
+}
+func (s *stubQuerier) DeleteStaleWorkers(ctx context.Context, olderThanSeconds int32) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) ListWorkers(ctx context.Context) ([]store.Worker, error) {
+	return nil, nil
+}
+func (s *stubQuerier) ListRunningJobs(ctx context.Context) ([]store.ListRunningJobsRow, error) {
+	return nil, nil
+}
+func (s *stubQuerier) GetQueueStats(ctx context.Context) ([]store.GetQueueStatsRow, error) {
+	return nil, nil
+}
+func (s *stubQuerier) CreateBatch(ctx context.Context, arg store.CreateBatchParams) (store.Batch, error) {
+	return store.Batch{}, nil
+}
+func (s *stubQuerier) GetBatch(ctx context.Context, arg store.GetBatchParams) (store.GetBatchRow, error) {
+	return store.GetBatchRow{}, nil
+}
+func (s *stubQuerier) CreateAPIKey(ctx context.Context, arg store.CreateAPIKeyParams) (store.ApiKey, error) {
+	return store.ApiKey{}, nil
+}
+func (s *stubQuerier) ListAPIKeys(ctx context.Context, tenantID uuid.UUID) ([]store.ApiKey, error) {
+	return nil, nil
+}
+func (s *stubQuerier) GetAPIKey(ctx context.Context, arg store.GetAPIKeyParams) (store.ApiKey, error) {
+	return store.ApiKey{}, nil
+}
+func (s *stubQuerier) RevokeAPIKey(ctx context.Context, arg store.RevokeAPIKeyParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) ListTenants(ctx context.Context, arg store.ListTenantsParams) ([]store.Tenant, error) {
+	return nil, nil
+}
+func (s *stubQuerier) SuspendTenant(ctx context.Context, id uuid.UUID) (store.Tenant, error) {
+	return store.Tenant{}, nil
+}
+func (s *stubQuerier) ReactivateTenant(ctx context.Context, id uuid.UUID) (store.Tenant, error) {
+	return store.Tenant{}, nil
+}
+func (s *stubQuerier) DeleteTenant(ctx context.Context, id uuid.UUID) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) SetJobOutput(ctx context.Context, arg store.SetJobOutputParams) error {
+	return nil
+}
+func (s *stubQuerier) CreateWorkflow(ctx context.Context, arg store.CreateWorkflowParams) (store.Workflow, error) {
+	return store.Workflow{}, nil
+}
+func (s *stubQuerier) GetWorkflow(ctx context.Context, arg store.GetWorkflowParams) (store.Workflow, error) {
+	return store.Workflow{}, nil
+}
+func (s *stubQuerier) ListWorkflows(ctx context.Context, arg store.ListWorkflowsParams) ([]store.Workflow, error) {
+	return nil, nil
+}
+func (s *stubQuerier) ListWorkflowSteps(ctx context.Context, workflowID uuid.UUID) ([]store.WorkflowStep, error) {
+	return nil, nil
+}
+func (s *stubQuerier) QueueWorkflowStep(ctx context.Context, arg store.QueueWorkflowStepParams) (store.Job, error) {
+	return store.Job{}, nil
+}
+func (s *stubQuerier) SkipWorkflowStep(ctx context.Context, arg store.SkipWorkflowStepParams) (int64, error) {
+	return 0, nil
+}
+func (s *stubQuerier) LockJobWorkflow(ctx context.Context, jobID uuid.UUID) (uuid.UUID, error) {
+	return uuid.Nil, nil
+}
+func (s *stubQuerier) ListStalledWorkflowSteps(ctx context.Context, limit int32) ([]store.ListStalledWorkflowStepsRow, error) {
+	if s.stalledStepsFn != nil {
+		return s.stalledStepsFn(ctx, limit)
+	}
+	return nil, nil
+}
+func (s *stubQuerier) FinishWorkflowStep(ctx context.Context, jobID uuid.UUID) (store.WorkflowStep, error) {
+	if s.finishStepFn != nil {
+		return s.finishStepFn(ctx, jobID)
+	}
+	return store.WorkflowStep{}, pgx.ErrNoRows
+}
+func (s *stubQuerier) FinishWorkflow(ctx context.Context, arg store.FinishWorkflowParams) (int64, error) {
+	return 0, nil
+}
 
 // stubExecutor implements worker.JobExecutor for tests.
 type stubExecutor struct {
@@ -108,11 +415,11 @@ func (s *stubExecutor) ExecuteJob(ctx context.Context, jobID uuid.UUID, tenantID
 }
 
 // runWorkerUntilDone starts a single-goroutine worker and waits for done to be closed or the test to time out.
-func runWorkerUntilDone(t *testing.T, q store.Querier, exec worker.JobExecutor, done <-chan struct{}) {
+func runWorkerUntilDone(t *testing.T, q store.Querier, exec worker.JobExecutor, done <-chan struct{}, opts ...worker.Option) {
 	t.Helper()
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	w := worker.New(q, exec, 1)
+	w := worker.New(q, exec, 1, opts...)
 	go w.Start(ctx)
 	select {
 	case <-done:
@@ -263,6 +570,10 @@ func TestWorker_JobExhaustsRetries(t *testing.T) {
 	if !captured.Error.Valid || captured.Error.String != execErr.Error() {
 		t.Errorf("expected error=%q, got %+v", execErr.Error(), captured.Error)
 	}
+	// The purge only deletes jobs with a completion time.
+	if captured.CompletedAt == nil {
+		t.Error("expected CompletedAt to be set on failure")
+	}
 }
 
 func TestWorker_BackoffGrowsWithAttempt(t *testing.T) {
@@ -317,20 +628,1079 @@ func TestWorker_BackoffGrowsWithAttempt(t *testing.T) {
 	}
 }
 
-// Compile-time check: stubQuerier satisfies store.Querier.
-var _ store.Querier = (*stubQuerier)(nil)
+func TestWorker_BackoffUsesJobRetryPolicy(t *testing.T) {
+	cases := []struct {
+		name     string
+		attempt  int32
+		base     int32
+		max      int32
+		jitter   float64
+		min, cap time.Duration
+	}{
+		{"first retry", 1, 3, 3600, 0, 6 * time.Second, 6 * time.Second},
+		{"doubles base", 2, 3, 3600, 0, 12 * time.Second, 12 * time.Second},
+		{"capped at max", 10, 10, 60, 0, 60 * time.Second, 60 * time.Second},
+		{"jitter shortens", 1, 50, 3600, 0.5, 50 * time.Second, 100 * time.Second},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			job := makeJob(tc.attempt, 20)
+			job.BackoffBaseSeconds, job.BackoffMaxSeconds, job.BackoffJitter = tc.base, tc.max, tc.jitter
+			q := singleJobQuerier(job)
+			var captured store.UpdateJobStatusParams
+			done := make(chan struct{})
+			q.updateJobStatusFn = func(_ context.Context, arg store.UpdateJobStatusParams) (store.Job, error) {
+				captured = arg
+				close(done)
+				return store.Job{}, nil
+			}
+			exec := &stubExecutor{
+				executeJobFn: func(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ string, _ json.RawMessage) error {
+					return errors.New("fail")
+				},
+			}
+			start := time.Now()
+			runWorkerUntilDone(t, q, exec, done)
 
-// Compile-time check: stubExecutor satisfies worker.JobExecutor.
-var _ worker.JobExecutor = (*stubExecutor)(nil)
+			d := captured.RunAt.Sub(start)
+			if d < tc.min-time.Second || d > tc.cap+time.Second {
+				t.Errorf("backoff %v outside [%v, %v]", d, tc.min, tc.cap)
+			}
+		})
+	}
+}
 
-// Confirm pgtype.Text works as expected in tests.
-func TestPgtypeText(t *testing.T) {
-	valid := pgtype.Text{String: "hello", Valid: true}
-	if !valid.Valid || valid.String != "hello" {
-		t.Error("pgtype.Text with value should be valid")
+func TestWorker_PermanentErrorFailsImmediately(t *testing.T) {
+	job := makeJob(1, 3)
+	q := singleJobQuerier(job)
+	var captured store.UpdateJobStatusParams
+	done := make(chan struct{})
+	q.updateJobStatusFn = func(_ context.Context, arg store.UpdateJobStatusParams) (store.Job, error) {
+		captured = arg
+		close(done)
+		return store.Job{}, nil
 	}
-	null := pgtype.Text{Valid: false}
-	if null.Valid {
-		t.Error("pgtype.Text zero value should be null")
+	exec := &stubExecutor{
+		executeJobFn: func(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ string, _ json.RawMessage) error {
+			return fmt.Errorf("send: %w", worker.Permanent(errors.New("invalid recipient")))
+		},
+	}
+	runWorkerUntilDone(t, q, exec, done)
+
+	if captured.Status != "failed" {
+		t.Errorf("expected status=failed with attempts remaining, got %s", captured.Status)
+	}
+	if captured.Error.String != "send: invalid recipient" {
+		t.Errorf("unexpected error %q", captured.Error.String)
+	}
+	if captured.CompletedAt == nil {
+		t.Error("expected CompletedAt to be set on failure")
+	}
+}
+
+func TestWorker_ProviderFailureFeedsCircuitBreaker(t *testing.T) {
+	job := makeJob(1, 3)
+	job.Provider = "twilio"
+	q := singleJobQuerier(job)
+	var captured store.RecordProviderFailureParams
+	q.providerFailureFn = func(_ context.Context, arg store.RecordProviderFailureParams) (store.ProviderCircuit, error) {
+		captured = arg
+		return store.ProviderCircuit{}, nil
+	}
+	done := make(chan struct{})
+	q.updateJobStatusFn = func(_ context.Context, _ store.UpdateJobStatusParams) (store.Job, error) {
+		close(done)
+		return store.Job{}, nil
+	}
+	exec := &stubExecutor{
+		executeJobFn: func(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ string, _ json.RawMessage) error {
+			return worker.RetryAfter(errors.New("429 too many requests"), 90*time.Second)
+		},
+	}
+	runWorkerUntilDone(t, q, exec, done, worker.WithCircuitBreaker(3, time.Minute))
+
+	want := store.RecordProviderFailureParams{
+		TenantID:          job.TenantID,
+		Provider:          "twilio",
+		FailureThreshold:  3,
+		OpenSeconds:       60,
+		RetryAfterSeconds: 90,
+		Error:             pgtype.Text{String: "429 too many requests", Valid: true},
+	}
+	if captured != want {
+		t.Errorf("RecordProviderFailure params = %+v, want %+v", captured, want)
+	}
+}
+
+func TestWorker_LostLeaseNotCountedAsProviderFailure(t *testing.T) {
+	job := makeJob(1, 3)
+	job.Provider = "twilio"
+	q := singleJobQuerier(job)
+	q.extendJobLeaseFn = func(_ context.Context, _ store.ExtendJobLeaseParams) (bool, error) {
+		return false, pgx.ErrNoRows
+	}
+	q.providerFailureFn = func(_ context.Context, _ store.RecordProviderFailureParams) (store.ProviderCircuit, error) {
+		t.Error("unexpected RecordProviderFailure")
+		return store.ProviderCircuit{}, nil
+	}
+	done := make(chan struct{})
+	q.updateJobStatusFn = func(_ context.Context, _ store.UpdateJobStatusParams) (store.Job, error) {
+		close(done)
+		return store.Job{}, pgx.ErrNoRows // fenced out
+	}
+	exec := &stubExecutor{
+		executeJobFn: func(ctx context.Context, _ uuid.UUID, _ uuid.UUID, _ string, _ json.RawMessage) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+	runWorkerUntilDone(t, q, exec, done, worker.WithLease(time.Second))
+}
+
+func TestWorker_CircuitBreakerOutcomes(t *testing.T) {
+	cases := []struct {
+		name        string
+		err         error
+		wantSuccess bool
+	}{
+		{"success closes circuit", nil, true},
+		{"permanent error not counted", worker.Permanent(errors.New("invalid number")), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			job := makeJob(1, 3)
+			job.Provider = "sendgrid"
+			q := singleJobQuerier(job)
+			var succeeded bool
+			q.providerSuccessFn = func(_ context.Context, arg store.RecordProviderSuccessParams) error {
+				succeeded = arg.Provider == "sendgrid" && arg.TenantID == job.TenantID
+				return nil
+			}
+			q.providerFailureFn = func(_ context.Context, _ store.RecordProviderFailureParams) (store.ProviderCircuit, error) {
+				t.Error("unexpected RecordProviderFailure")
+				return store.ProviderCircuit{}, nil
+			}
+			done := make(chan struct{})
+			q.updateJobStatusFn = func(_ context.Context, _ store.UpdateJobStatusParams) (store.Job, error) {
+				close(done)
+				return store.Job{}, nil
+			}
+			exec := &stubExecutor{
+				executeJobFn: func(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ string, _ json.RawMessage) error {
+					return tc.err
+				},
+			}
+			runWorkerUntilDone(t, q, exec, done)
+
+			if succeeded != tc.wantSuccess {
+				t.Errorf("RecordProviderSuccess called = %v, want %v", succeeded, tc.wantSuccess)
+			}
+		})
+	}
+}
+
+func TestWorker_FinishedJobEmitsWebhookEvent(t *testing.T) {
+	cases := []struct {
+		name      string
+		attempt   int32
+		err       error
+		wantEvent string
+	}{
+		{"completed", 1, nil, worker.EventJobCompleted},
+		{"attempts exhausted", 3, errors.New("boom"), worker.EventJobFailed},
+		{"retry pending", 1, errors.New("boom"), ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			job := makeJob(tc.attempt, 3)
+			q := singleJobQuerier(job)
+			events := make(chan string, 1)
+			q.enqueueWebhookFn = func(_ context.Context, arg store.EnqueueWebhookDeliveriesParams) (int64, error) {
+				if arg.JobID != job.ID {
+					t.Errorf("unexpected job id %s", arg.JobID)
+				}
+				events <- arg.Event
+				return 1, nil
+			}
+			done := make(chan struct{})
+			var update store.UpdateJobStatusParams
+			q.updateJobStatusFn = func(_ context.Context, arg store.UpdateJobStatusParams) (store.Job, error) {
+				update = arg
+				close(done)
+				return store.Job{}, nil
+			}
+			exec := &stubExecutor{
+				executeJobFn: func(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ string, _ json.RawMessage) error {
+					return tc.err
+				},
+			}
+			runWorkerUntilDone(t, q, exec, done)
+
+			// The event is queued just after the status update.
+			var event string
+			select {
+			case event = <-events:
+			case <-time.After(100 * time.Millisecond):
+			}
+			if event != tc.wantEvent {
+				t.Errorf("event = %q, want %q", event, tc.wantEvent)
+			}
+			// The delivery's completed_at is read from the job the update wrote.
+			if event != "" && update.CompletedAt == nil {
+				t.Errorf("%s event for a job without completed_at", event)
+			}
+		})
+	}
+}
+
+func TestWorker_FinishedWorkflowStepAdvancesWorkflow(t *testing.T) {
+	cases := []struct {
+		name        string
+		attempt     int32
+		err         error
+		inWorkflow  bool
+		wantAdvance bool
+	}{
+		{"completed step", 1, nil, true, true},
+		{"failed step", 3, errors.New("boom"), true, true},
+		{"step pending retry", 1, errors.New("boom"), true, false},
+		{"job outside a workflow", 1, nil, false, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			job := makeJob(tc.attempt, 3)
+			job.WorkflowID = pgtype.UUID{Bytes: uuid.New(), Valid: tc.inWorkflow}
+			q := singleJobQuerier(job)
+			advanced := make(chan uuid.UUID, 1)
+			q.finishStepFn = func(_ context.Context, jobID uuid.UUID) (store.WorkflowStep, error) {
+				advanced <- jobID
+				return store.WorkflowStep{}, pgx.ErrNoRows
+			}
+			done := make(chan struct{})
+			q.updateJobStatusFn = func(_ context.Context, arg store.UpdateJobStatusParams) (store.Job, error) {
+				close(done)
+				updated := job
+				updated.Status = arg.Status
+				return updated, nil
+			}
+			exec := &stubExecutor{
+				executeJobFn: func(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ string, _ json.RawMessage) error {
+					return tc.err
+				},
+			}
+			runWorkerUntilDone(t, q, exec, done)
+
+			var got uuid.UUID
+			select {
+			case got = <-advanced:
+			case <-time.After(100 * time.Millisecond):
+			}
+			if (got == job.ID) != tc.wantAdvance {
+				t.Errorf("advanced workflow for %s, want advance = %v", got, tc.wantAdvance)
+			}
+		})
+	}
+}
+
+func TestWorker_RetryAfterOverridesBackoff(t *testing.T) {
+	job := makeJob(1, 3)
+	q := singleJobQuerier(job)
+	var captured store.UpdateJobStatusParams
+	done := make(chan struct{})
+	q.updateJobStatusFn = func(_ context.Context, arg store.UpdateJobStatusParams) (store.Job, error) {
+		captured = arg
+		close(done)
+		return store.Job{}, nil
+	}
+	exec := &stubExecutor{
+		executeJobFn: func(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ string, _ json.RawMessage) error {
+			return worker.RetryAfter(errors.New("rate limited"), 5*time.Minute)
+		},
+	}
+	start := time.Now()
+	runWorkerUntilDone(t, q, exec, done)
+
+	if captured.Status != "pending" {
+		t.Fatalf("expected status=pending, got %s", captured.Status)
+	}
+	if d := captured.RunAt.Sub(start); d < 5*time.Minute || d > 5*time.Minute+5*time.Second {
+		t.Errorf("expected run_at ~5m from now, got %v", d)
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	base := errors.New("provider error")
+	cases := []struct {
+		status     int
+		retryAfter string
+		permanent  bool
+		delay      time.Duration
+	}{
+		{http.StatusBadRequest, "", true, 0},
+		{http.StatusUnauthorized, "", true, 0},
+		{http.StatusRequestTimeout, "", false, 0},
+		{http.StatusTooManyRequests, "", false, 0},
+		{http.StatusTooManyRequests, "30", false, 30 * time.Second},
+		{http.StatusServiceUnavailable, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), false, time.Hour},
+		{http.StatusInternalServerError, "", false, 0},
+	}
+	for _, tc := range cases {
+		resp := &http.Response{StatusCode: tc.status, Header: http.Header{}}
+		if tc.retryAfter != "" {
+			resp.Header.Set("Retry-After", tc.retryAfter)
+		}
+		err := worker.HTTPError(resp, base)
+		if !errors.Is(err, base) {
+			t.Errorf("%d: wrapped error lost", tc.status)
+		}
+		if got := worker.IsPermanent(err); got != tc.permanent {
+			t.Errorf("%d: IsPermanent = %v, want %v", tc.status, got, tc.permanent)
+		}
+		var ra *worker.RetryAfterError
+		if errors.As(err, &ra) != (tc.delay > 0) {
+			t.Errorf("%d: unexpected retry-after classification: %v", tc.status, err)
+		} else if tc.delay > 0 && (ra.Delay < tc.delay-2*time.Second || ra.Delay > tc.delay) {
+			t.Errorf("%d: delay = %v, want ~%v", tc.status, ra.Delay, tc.delay)
+		}
+	}
+}
+
+// The rotation across tenants is done by ClaimNextJob's SQL; the worker's part
+// is to pass the tenant it claimed from last, which is all this covers.
+func TestWorker_ClaimResumesAfterLastTenant(t *testing.T) {
+	tenants := []uuid.UUID{uuid.New(), uuid.New()}
+	var (
+		mu    sync.Mutex
+		after []uuid.UUID
+	)
+	done := make(chan struct{})
+	q := &stubQuerier{
+		claimArgsFn: func(_ context.Context, arg store.ClaimNextJobParams) (store.Job, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if len(after) > len(tenants) {
+				return store.Job{}, pgx.ErrNoRows
+			}
+			after = append(after, arg.AfterTenantID)
+			if len(after) > len(tenants) {
+				close(done)
+				return store.Job{}, pgx.ErrNoRows
+			}
+			job := makeJob(1, 3)
+			job.TenantID = tenants[len(after)-1]
+			return job, nil
+		},
+	}
+	runWorkerUntilDone(t, q, &stubExecutor{}, done)
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []uuid.UUID{uuid.Nil, tenants[0], tenants[1]}
+	if !slices.Equal(after, want) {
+		t.Errorf("claims after tenants %v, want %v", after, want)
+	}
+}
+
+func TestWorker_TenantConcurrencyPassedToClaim(t *testing.T) {
+	got := make(chan store.ClaimNextJobParams, 1)
+	q := &stubQuerier{
+		claimArgsFn: func(_ context.Context, arg store.ClaimNextJobParams) (store.Job, error) {
+			select {
+			case got <- arg:
+			default:
+			}
+			return store.Job{}, pgx.ErrNoRows
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	worker.New(q, &stubExecutor{}, 1, worker.WithTenantConcurrency(4)).Start(ctx)
+
+	arg := <-got
+	if !arg.DefaultTenantLimit.Valid || arg.DefaultTenantLimit.Int32 != 4 {
+		t.Errorf("expected default tenant limit 4, got %+v", arg.DefaultTenantLimit)
+	}
+}
+
+func TestWorker_QueuePools(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	q := &stubQuerier{
+		claimArgsFn: func(_ context.Context, arg store.ClaimNextJobParams) (store.Job, error) {
+			mu.Lock()
+			seen[strings.Join(arg.Queues, ",")] = true
+			mu.Unlock()
+			return store.Job{}, pgx.ErrNoRows
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	worker.New(q, &stubExecutor{}, 0, worker.WithQueue("transactional", 2)).Start(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !seen["transactional"] || len(seen) != 1 {
+		t.Errorf("expected claims from the transactional queue only, got %v", seen)
+	}
+}
+
+func TestWorker_HeartbeatExtendsLeaseWhileRunning(t *testing.T) {
+	job := makeJob(1, 3)
+	var extended atomic.Int32
+	done := make(chan struct{})
+	var claimCount int
+	q := &stubQuerier{
+		claimNextJobFn: func(_ context.Context) (store.Job, error) {
+			claimCount++
+			if claimCount == 1 {
+				return job, nil
+			}
+			return store.Job{}, pgx.ErrNoRows
+		},
+		extendJobLeaseFn: func(_ context.Context, arg store.ExtendJobLeaseParams) (bool, error) {
+			if arg.ID != job.ID || arg.Attempt != job.Attempt {
+				t.Errorf("unexpected ExtendJobLease params: %+v", arg)
+			}
+			extended.Add(1)
+			return false, nil
+		},
+		updateJobStatusFn: func(_ context.Context, arg store.UpdateJobStatusParams) (store.Job, error) {
+			if arg.Attempt != job.Attempt {
+				t.Errorf("expected attempt=%d fencing token, got %d", job.Attempt, arg.Attempt)
+			}
+			close(done)
+			return store.Job{}, nil
+		},
+	}
+	exec := &stubExecutor{
+		executeJobFn: func(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ string, _ json.RawMessage) error {
+			time.Sleep(1500 * time.Millisecond) // outlives one heartbeat at lease/3 = 1s
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	go worker.New(q, exec, 1, worker.WithLease(3*time.Second)).Start(ctx)
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for worker to process job")
+	}
+	if extended.Load() == 0 {
+		t.Error("expected the lease to be extended while the job was running")
+	}
+}
+
+func TestWorker_LostLeaseCancelsJob(t *testing.T) {
+	// Once the reaper has reclaimed the job, the heartbeat sees no rows and must cancel execution.
+	job := makeJob(1, 3)
+	cancelled := make(chan struct{})
+	var claimCount int
+	q := &stubQuerier{
+		claimNextJobFn: func(_ context.Context) (store.Job, error) {
+			claimCount++
+			if claimCount == 1 {
+				return job, nil
+			}
+			return store.Job{}, pgx.ErrNoRows
+		},
+		extendJobLeaseFn: func(_ context.Context, _ store.ExtendJobLeaseParams) (bool, error) {
+			return false, pgx.ErrNoRows
+		},
+		updateJobStatusFn: func(_ context.Context, _ store.UpdateJobStatusParams) (store.Job, error) {
+			return store.Job{}, pgx.ErrNoRows // fenced out
+		},
+	}
+	exec := &stubExecutor{
+		executeJobFn: func(ctx context.Context, _ uuid.UUID, _ uuid.UUID, _ string, _ json.RawMessage) error {
+			<-ctx.Done()
+			close(cancelled)
+			return ctx.Err()
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	go worker.New(q, exec, 1, worker.WithLease(time.Second)).Start(ctx)
+	select {
+	case <-cancelled:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for lost lease to cancel the job")
+	}
+}
+
+func TestWorker_CancelRequestCancelsJob(t *testing.T) {
+	// A cancellation flagged by the API is seen on the next heartbeat; the job is
+	// marked cancelled rather than retried.
+	job := makeJob(1, 3)
+	q := singleJobQuerier(job)
+	q.extendJobLeaseFn = func(_ context.Context, _ store.ExtendJobLeaseParams) (bool, error) {
+		return true, nil
+	}
+	done := make(chan store.UpdateJobStatusParams, 1)
+	q.updateJobStatusFn = func(_ context.Context, arg store.UpdateJobStatusParams) (store.Job, error) {
+		done <- arg
+		return store.Job{}, nil
+	}
+	exec := &stubExecutor{
+		executeJobFn: func(ctx context.Context, _ uuid.UUID, _ uuid.UUID, _ string, _ json.RawMessage) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	go worker.New(q, exec, 1, worker.WithLease(time.Second)).Start(ctx)
+	select {
+	case arg := <-done:
+		if arg.Status != "cancelled" || arg.CompletedAt == nil {
+			t.Errorf("expected job marked cancelled, got %+v", arg)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the cancel request to stop the job")
+	}
+}
+
+func TestWorker_ReapsExpiredLeasesOnStart(t *testing.T) {
+	reaped := make(chan struct{})
+	var once atomic.Bool
+	q := &stubQuerier{
+		reapExpiredJobsFn: func(_ context.Context) ([]store.Job, error) {
+			if once.CompareAndSwap(false, true) {
+				close(reaped)
+			}
+			return []store.Job{makeJob(1, 3)}, nil
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go worker.New(q, &stubExecutor{}, 1).Start(ctx)
+	select {
+	case <-reaped:
+	case <-ctx.Done():
+		t.Fatal("expected ReapExpiredJobs to run when the worker starts")
+	}
+}
+
+// singleJobQuerier returns a stubQuerier whose first claim yields job and every later claim finds nothing.
+func singleJobQuerier(job store.Job) *stubQuerier {
+	var claimed atomic.Bool
+	return &stubQuerier{
+		claimNextJobFn: func(_ context.Context) (store.Job, error) {
+			if claimed.CompareAndSwap(false, true) {
+				return job, nil
+			}
+			return store.Job{}, pgx.ErrNoRows
+		},
+	}
+}
+
+func TestWorker_ShutdownDrainsInFlightJob(t *testing.T) {
+	job := makeJob(1, 3)
+	q := singleJobQuerier(job)
+	var captured store.UpdateJobStatusParams
+	q.updateJobStatusFn = func(_ context.Context, arg store.UpdateJobStatusParams) (store.Job, error) {
+		captured = arg
+		return store.Job{}, nil
+	}
+	started := make(chan struct{})
+	exec := &stubExecutor{
+		executeJobFn: func(ctx context.Context, _ uuid.UUID, _ uuid.UUID, _ string, _ json.RawMessage) error {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			return ctx.Err() // must not be cancelled by the shutdown signal
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		worker.New(q, exec, 1, worker.WithShutdownTimeout(2*time.Second)).Start(ctx)
+		close(stopped)
+	}()
+	<-started
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Start did not return after draining")
+	}
+	if captured.Status != "completed" {
+		t.Errorf("expected in-flight job to complete during drain, got status=%q", captured.Status)
+	}
+}
+
+func TestWorker_ShutdownTimeoutRequeuesJob(t *testing.T) {
+	job := makeJob(2, 3)
+	q := singleJobQuerier(job)
+	var requeued store.RequeueJobParams
+	q.requeueJobFn = func(_ context.Context, arg store.RequeueJobParams) (int64, error) {
+		requeued = arg
+		return 1, nil
+	}
+	q.updateJobStatusFn = func(_ context.Context, arg store.UpdateJobStatusParams) (store.Job, error) {
+		t.Errorf("interrupted job should be re-queued, not marked %s", arg.Status)
+		return store.Job{}, nil
+	}
+	started := make(chan struct{})
+	exec := &stubExecutor{
+		executeJobFn: func(ctx context.Context, _ uuid.UUID, _ uuid.UUID, _ string, _ json.RawMessage) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		worker.New(q, exec, 1, worker.WithShutdownTimeout(100*time.Millisecond)).Start(ctx)
+		close(stopped)
+	}()
+	<-started
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Start did not return after the shutdown timeout")
+	}
+	if requeued.ID != job.ID || requeued.Attempt != job.Attempt {
+		t.Errorf("expected job %s attempt %d to be re-queued, got %+v", job.ID, job.Attempt, requeued)
+	}
+}
+
+// stubListener implements worker.Listener, handing the notify callback to the test.
+type stubListener struct {
+	ready chan func(payload string)
+}
+
+func (l *stubListener) Listen(ctx context.Context, notify func(payload string)) error {
+	l.ready <- notify
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestWorker_ListenerWakesIdleWorker(t *testing.T) {
+	// With polling effectively disabled, a notification alone must get the job claimed.
+	job := makeJob(0, 3)
+	var claimCount atomic.Int32
+	done := make(chan struct{})
+	q := &stubQuerier{
+		claimNextJobFn: func(_ context.Context) (store.Job, error) {
+			if claimCount.Add(1) == 2 {
+				return job, nil
+			}
+			return store.Job{}, pgx.ErrNoRows
+		},
+		updateJobStatusFn: func(_ context.Context, _ store.UpdateJobStatusParams) (store.Job, error) {
+			close(done)
+			return store.Job{}, nil
+		},
+	}
+	l := &stubListener{ready: make(chan func(payload string))}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	w := worker.New(q, &stubExecutor{}, 1, worker.WithListener(l), worker.WithPollInterval(time.Hour))
+	go w.Start(ctx)
+
+	notify := <-l.ready
+	for claimCount.Load() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	notify(job.ID.String())
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for notification to wake the worker")
+	}
+}
+
+// Compile-time check: stubQuerier satisfies store.Querier.
+var _ store.Querier = (*stubQuerier)(nil)
+
+// Compile-time check: stubExecutor satisfies worker.JobExecutor.
+var _ worker.JobExecutor = (*stubExecutor)(nil)
+
+// Compile-time check: stubListener satisfies worker.Listener.
+var _ worker.Listener = (*stubListener)(nil)
+
+// Confirm pgtype.Text works as expected in tests.
+func TestPgtypeText(t *testing.T) {
+	valid := pgtype.Text{String: "hello", Valid: true}
+	if !valid.Valid || valid.String != "hello" {
+		t.Error("pgtype.Text with value should be valid")
+	}
+	null := pgtype.Text{Valid: false}
+	if null.Valid {
+		t.Error("pgtype.Text zero value should be null")
+	}
+}
+
+func TestWorker_FiresDueSchedule(t *testing.T) {
+	dueAt := time.Now().Add(-30 * time.Second).Truncate(time.Minute)
+	sched := store.Schedule{ID: uuid.New(), CronExpr: "*/5 * * * *", Timezone: "UTC", NextRunAt: dueAt}
+
+	fired := make(chan store.FireScheduleParams, 1)
+	var listed atomic.Bool
+	q := &stubQuerier{
+		listDueSchedFn: func(_ context.Context) ([]store.Schedule, error) {
+			if listed.CompareAndSwap(false, true) {
+				return []store.Schedule{sched}, nil
+			}
+			return nil, nil
+		},
+		fireScheduleFn: func(_ context.Context, arg store.FireScheduleParams) (store.Job, error) {
+			fired <- arg
+			return store.Job{ID: uuid.New()}, nil
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go worker.New(q, &stubExecutor{}, 1).Start(ctx)
+
+	select {
+	case arg := <-fired:
+		if arg.ID != sched.ID || !arg.DueAt.Equal(dueAt) {
+			t.Errorf("expected to fire schedule %s due at %s, got %+v", sched.ID, dueAt, arg)
+		}
+		if !arg.NextRunAt.After(time.Now()) || arg.NextRunAt.Minute()%5 != 0 {
+			t.Errorf("next run %s should be the next */5 slot after now", arg.NextRunAt)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected the due schedule to be fired")
+	}
+}
+
+func TestWorker_InvalidScheduleIsPaused(t *testing.T) {
+	sched := store.Schedule{ID: uuid.New(), TenantID: uuid.New(), CronExpr: "not a cron", NextRunAt: time.Now()}
+
+	paused := make(chan store.PauseScheduleParams, 1)
+	var listed atomic.Bool
+	q := &stubQuerier{
+		listDueSchedFn: func(_ context.Context) ([]store.Schedule, error) {
+			if listed.CompareAndSwap(false, true) {
+				return []store.Schedule{sched}, nil
+			}
+			return nil, nil
+		},
+		fireScheduleFn: func(_ context.Context, _ store.FireScheduleParams) (store.Job, error) {
+			t.Error("an invalid schedule must not be fired")
+			return store.Job{}, pgx.ErrNoRows
+		},
+		pauseScheduleFn: func(_ context.Context, arg store.PauseScheduleParams) (store.Schedule, error) {
+			paused <- arg
+			return store.Schedule{}, nil
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go worker.New(q, &stubExecutor{}, 1).Start(ctx)
+
+	select {
+	case arg := <-paused:
+		if arg.ID != sched.ID || arg.TenantID != sched.TenantID {
+			t.Errorf("paused wrong schedule: %+v", arg)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected the invalid schedule to be paused")
+	}
+}
+
+func TestWorker_PurgesFinishedJobsInBatches(t *testing.T) {
+	var jobCalls atomic.Int32
+	purged := make(chan store.PurgeFinishedWorkflowsParams, 1)
+	q := &stubQuerier{
+		purgeJobsFn: func(_ context.Context, arg store.PurgeFinishedJobsParams) (int64, error) {
+			if !arg.DefaultDays.Valid || arg.DefaultDays.Int32 != 30 {
+				t.Errorf("expected default retention of 30 days, got %+v", arg.DefaultDays)
+			}
+			// A full batch means there may be more to delete.
+			if jobCalls.Add(1) == 1 {
+				return int64(arg.BatchSize), nil
+			}
+			return 3, nil
+		},
+		purgeWorkflowsFn: func(_ context.Context, arg store.PurgeFinishedWorkflowsParams) (int64, error) {
+			select {
+			case purged <- arg:
+			default:
+			}
+			return 0, nil
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go worker.New(q, &stubExecutor{}, 1, worker.WithRetention(30)).Start(ctx)
+
+	select {
+	case arg := <-purged:
+		if arg.DefaultDays.Int32 != 30 {
+			t.Errorf("unexpected workflow purge params: %+v", arg)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected finished workflows to be purged")
+	}
+	if n := jobCalls.Load(); n != 2 {
+		t.Errorf("expected 2 job purge batches, got %d", n)
+	}
+}
+
+func TestWorker_PurgesJobsItFailed(t *testing.T) {
+	job := makeJob(3, 3)
+	q := singleJobQuerier(job)
+	var (
+		mu     sync.Mutex
+		failed *store.UpdateJobStatusParams
+	)
+	q.updateJobStatusFn = func(_ context.Context, arg store.UpdateJobStatusParams) (store.Job, error) {
+		mu.Lock()
+		failed = &arg
+		mu.Unlock()
+		return store.Job{}, nil
+	}
+	purged := make(chan struct{})
+	var once sync.Once
+	q.purgeJobsFn = func(_ context.Context, arg store.PurgeFinishedJobsParams) (int64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// Like PurgeFinishedJobs once the retention window has passed: jobs
+		// without a completion time are never old enough.
+		if failed == nil || failed.CompletedAt == nil {
+			return 0, nil
+		}
+		once.Do(func() { close(purged) })
+		return 1, nil
+	}
+	exec := &stubExecutor{
+		executeJobFn: func(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ string, _ json.RawMessage) error {
+			return errors.New("boom")
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go worker.New(q, exec, 1, worker.WithRetention(30), worker.WithPurgeInterval(10*time.Millisecond)).Start(ctx)
+
+	select {
+	case <-purged:
+	case <-ctx.Done():
+		t.Fatal("expected the failed job to be purged")
+	}
+}
+
+func TestWorker_PurgeWithoutDefaultRetention(t *testing.T) {
+	called := make(chan store.PurgeFinishedJobsParams, 1)
+	q := &stubQuerier{
+		purgeJobsFn: func(_ context.Context, arg store.PurgeFinishedJobsParams) (int64, error) {
+			select {
+			case called <- arg:
+			default:
+			}
+			return 0, nil
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go worker.New(q, &stubExecutor{}, 1).Start(ctx)
+
+	select {
+	case arg := <-called:
+		// Only tenants with their own window are purged.
+		if arg.DefaultDays.Valid {
+			t.Errorf("expected no default retention, got %+v", arg.DefaultDays)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected a purge on startup")
+	}
+}
+
+func TestWorker_PurgesExpiredIdempotencyKeysInBatches(t *testing.T) {
+	var calls atomic.Int32
+	done := make(chan struct{})
+	q := &stubQuerier{
+		purgeIdemKeysFn: func(_ context.Context, batchSize int32) (int64, error) {
+			// A full batch means there may be more to delete.
+			switch calls.Add(1) {
+			case 1:
+				return int64(batchSize), nil
+			case 2:
+				close(done)
+			}
+			return 0, nil
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go worker.New(q, &stubExecutor{}, 1).Start(ctx)
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("expected expired idempotency keys to be purged")
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("expected 2 purge batches, got %d", n)
+	}
+}
+
+func TestWorker_ResumesStalledWorkflowSteps(t *testing.T) {
+	stalled := store.ListStalledWorkflowStepsRow{ID: uuid.New(), TenantID: uuid.New()}
+	finished := make(chan uuid.UUID, 1)
+	q := &stubQuerier{
+		stalledStepsFn: func(context.Context, int32) ([]store.ListStalledWorkflowStepsRow, error) {
+			return []store.ListStalledWorkflowStepsRow{stalled}, nil
+		},
+		finishStepFn: func(_ context.Context, jobID uuid.UUID) (store.WorkflowStep, error) {
+			finished <- jobID
+			return store.WorkflowStep{}, pgx.ErrNoRows
+		},
+	}
+	var txs atomic.Int32
+	tx := func(ctx context.Context, fn func(workflow.Store) error) error {
+		txs.Add(1)
+		return fn(q)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go worker.New(q, &stubExecutor{}, 1, worker.WithWorkflowTx(tx)).Start(ctx)
+
+	select {
+	case got := <-finished:
+		if got != stalled.ID {
+			t.Errorf("advanced job %s, want %s", got, stalled.ID)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected the stalled step's workflow to be advanced")
+	}
+	if txs.Load() == 0 {
+		t.Error("expected the workflow to be advanced in a transaction")
+	}
+}
+
+func TestWorker_ResumesFailedStepWhoseAdvanceFailed(t *testing.T) {
+	job := makeJob(3, 3)
+	job.WorkflowID = pgtype.UUID{Bytes: uuid.New(), Valid: true}
+	q := singleJobQuerier(job)
+	var failed atomic.Bool
+	q.updateJobStatusFn = func(_ context.Context, arg store.UpdateJobStatusParams) (store.Job, error) {
+		if arg.Status != "failed" || arg.CompletedAt == nil {
+			t.Errorf("unexpected status update: %+v", arg)
+		}
+		failed.Store(true)
+		updated := job
+		updated.Status = arg.Status
+		updated.CompletedAt = arg.CompletedAt
+		return updated, nil
+	}
+	// The step stays queued, so its failed job is stalled, until an advance
+	// records its outcome.
+	advanced := make(chan uuid.UUID, 1)
+	q.stalledStepsFn = func(context.Context, int32) ([]store.ListStalledWorkflowStepsRow, error) {
+		if !failed.Load() || len(advanced) > 0 {
+			return nil, nil
+		}
+		return []store.ListStalledWorkflowStepsRow{{ID: job.ID, TenantID: job.TenantID}}, nil
+	}
+	q.finishStepFn = func(_ context.Context, jobID uuid.UUID) (store.WorkflowStep, error) {
+		select {
+		case advanced <- jobID:
+		default:
+		}
+		return store.WorkflowStep{}, pgx.ErrNoRows
+	}
+	var txs atomic.Int32
+	tx := func(ctx context.Context, fn func(workflow.Store) error) error {
+		if txs.Add(1) == 1 {
+			return errors.New("connection reset")
+		}
+		return fn(q)
+	}
+	exec := &stubExecutor{
+		executeJobFn: func(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ string, _ json.RawMessage) error {
+			return errors.New("boom")
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go worker.New(q, exec, 1, worker.WithWorkflowTx(tx), worker.WithPurgeInterval(10*time.Millisecond)).Start(ctx)
+
+	select {
+	case got := <-advanced:
+		if got != job.ID {
+			t.Errorf("advanced job %s, want %s", got, job.ID)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected the failed step's workflow to be advanced again")
+	}
+}
+
+func TestWorker_RegistersHeartbeatsAndStops(t *testing.T) {
+	registered := make(chan store.RegisterWorkerParams, 2)
+	heartbeats := make(chan struct{}, 1)
+	stopped := make(chan string, 1)
+	q := &stubQuerier{
+		registerWorkerFn: func(_ context.Context, arg store.RegisterWorkerParams) (store.Worker, error) {
+			select {
+			case registered <- arg:
+			default:
+			}
+			return store.Worker{ID: arg.ID}, nil
+		},
+		heartbeatFn: func(_ context.Context, _ string) (int64, error) {
+			select {
+			case heartbeats <- struct{}{}:
+			default:
+			}
+			// The entry was pruned, so the worker registers again.
+			return 0, nil
+		},
+		stopWorkerFn: func(_ context.Context, id string) error {
+			stopped <- id
+			return nil
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		worker.New(q, &stubExecutor{}, 2,
+			worker.WithWorkerID("w1"),
+			worker.WithQueue("email", 3),
+			worker.WithHeartbeatInterval(10*time.Millisecond),
+		).Start(ctx)
+		close(done)
+	}()
+
+	arg := <-registered
+	if arg.ID != "w1" || arg.Concurrency != 5 {
+		t.Errorf("expected w1 registered with concurrency 5, got %+v", arg)
+	}
+	if len(arg.Queues) != 2 || arg.Queues[0] != "email" || arg.Queues[1] != "*" {
+		t.Errorf("expected queues [email *], got %v", arg.Queues)
+	}
+	select {
+	case <-heartbeats:
+	case <-time.After(time.Second):
+		t.Fatal("expected a heartbeat")
+	}
+	select {
+	case <-registered:
+	case <-time.After(time.Second):
+		t.Fatal("expected the worker to register again after a missed heartbeat")
+	}
+
+	cancel()
+	select {
+	case id := <-stopped:
+		if id != "w1" {
+			t.Errorf("expected w1 stopped, got %q", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the worker to deregister on shutdown")
 	}
+	<-done
 }
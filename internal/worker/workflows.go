@@ -0,0 +1,41 @@
+package worker
+
+import (
+	"context"
+	"log"
+
+	"github.com/gsarma/tusker/internal/store"
+	"github.com/gsarma/tusker/internal/workflow"
+)
+
+// advanceWorkflow queues the workflow steps unblocked by job, a step that has
+// just completed, failed for good or been cancelled. Step payloads are opened
+// and sealed by the executor if it implements workflow.Codec.
+func (w *Worker) advanceWorkflow(ctx context.Context, job store.Job) {
+	if !job.WorkflowID.Valid {
+		return
+	}
+	codec, _ := w.executor.(workflow.Codec)
+	if err := workflow.Advance(ctx, w.workflowTx, codec, job.TenantID, job.ID); err != nil {
+		log.Printf("worker: advance workflow error for job %s: %v", job.ID, err)
+	}
+}
+
+// resumeWorkflows advances the workflows of steps whose job finished but whose
+// outcome was never recorded, because advancing the workflow failed or the
+// worker died before it could.
+func (w *Worker) resumeWorkflows(ctx context.Context) {
+	stalled, err := w.store.ListStalledWorkflowSteps(ctx, purgeBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("worker: list stalled workflow steps error: %v", err)
+		}
+		return
+	}
+	codec, _ := w.executor.(workflow.Codec)
+	for _, s := range stalled {
+		if err := workflow.Advance(ctx, w.workflowTx, codec, s.TenantID, s.ID); err != nil {
+			log.Printf("worker: resume workflow error for job %s: %v", s.ID, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Result is what a finished step exposes to the payloads of later steps.
+type Result struct {
+	JobID  string
+	Status string
+	Error  string
+	Output json.RawMessage
+}
+
+// resultFields are the fields of a step a placeholder may name.
+var resultFields = map[string]bool{"status": true, "error": true, "job_id": true, "output": true}
+
+// placeholder matches {{steps.<name>.<field>[.<path>...]}}.
+var placeholder = regexp.MustCompile(`\{\{\s*steps\.([a-z0-9_-]+)\.([A-Za-z0-9_]+)((?:\.[A-Za-z0-9_-]+)*)\s*\}\}`)
+
+type reference struct {
+	step, field string
+	path        []string
+}
+
+func parseReference(m []string) reference {
+	r := reference{step: m[1], field: m[2]}
+	if m[3] != "" {
+		r.path = strings.Split(m[3][1:], ".")
+	}
+	return r
+}
+
+// references returns the placeholders in the string values of payload.
+func references(payload any) []reference {
+	var refs []reference
+	walk(payload, func(s string) any {
+		for _, m := range placeholder.FindAllStringSubmatch(s, -1) {
+			refs = append(refs, parseReference(m))
+		}
+		return s
+	})
+	return refs
+}
+
+// Render fills in the placeholders in the string values of payload from
+// results. A string that is exactly one placeholder is replaced by the value
+// it names, keeping its JSON type, so {{steps.run.output}} inserts the whole
+// output object; placeholders within longer strings are replaced by the value
+// as text. output may be followed by a path of object keys and array indexes,
+// e.g. {{steps.run.output.stdout}}. References to steps without a result, or
+// to paths their output does not have, render as null, or as empty text
+// within a longer string.
+func Render(payload []byte, results map[string]Result) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	v = walk(v, func(s string) any {
+		if m := placeholder.FindStringSubmatch(s); m != nil && m[0] == s {
+			return lookup(parseReference(m), results)
+		}
+		return placeholder.ReplaceAllStringFunc(s, func(p string) string {
+			return text(lookup(parseReference(placeholder.FindStringSubmatch(p)), results))
+		})
+	})
+	return json.Marshal(v)
+}
+
+// walk replaces every string value in v, but not object keys, with f's result.
+func walk(v any, f func(string) any) any {
+	switch v := v.(type) {
+	case string:
+		return f(v)
+	case map[string]any:
+		for k, e := range v {
+			v[k] = walk(e, f)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = walk(e, f)
+		}
+	}
+	return v
+}
+
+func lookup(r reference, results map[string]Result) any {
+	res, ok := results[r.step]
+	if !ok {
+		return nil
+	}
+	switch r.field {
+	case "status":
+		return res.Status
+	case "error":
+		if res.Error == "" {
+			return nil
+		}
+		return res.Error
+	case "job_id":
+		return res.JobID
+	case "output":
+	default:
+		return nil
+	}
+	if len(res.Output) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(res.Output))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil
+	}
+	for _, key := range r.path {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// text formats a looked-up value for interpolation into a string.
+func text(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
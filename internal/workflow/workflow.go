@@ -0,0 +1,354 @@
+// Package workflow runs small DAGs of jobs. Each step is a job that, once it
+// finishes, triggers the steps named in its on_success or on_failure edges.
+// A step with several upstream steps waits for all of them and runs if any
+// edge leading to it fired; otherwise it is skipped, and so are the steps that
+// depend only on it.
+//
+// Step payloads may reference the results of upstream steps with placeholders
+// such as {{steps.run.output.stdout}}; see Render.
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/gsarma/tusker/internal/store"
+)
+
+// MaxSteps bounds the size of a workflow.
+const MaxSteps = 20
+
+var stepNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// Step is the shape of a workflow step checked by Validate.
+type Step struct {
+	Name      string
+	Payload   json.RawMessage
+	OnSuccess []string
+	OnFailure []string
+}
+
+// Validate checks that steps form a DAG of uniquely named steps whose edges
+// and placeholders only refer to steps that exist and, for placeholders, run
+// before the step that uses them.
+func Validate(steps []Step) error {
+	if len(steps) == 0 || len(steps) > MaxSteps {
+		return fmt.Errorf("a workflow must have between 1 and %d steps", MaxSteps)
+	}
+	index := make(map[string]int, len(steps))
+	for i, s := range steps {
+		if !stepNamePattern.MatchString(s.Name) {
+			return fmt.Errorf("step name %q must be 1-64 lowercase letters, digits, '_' or '-'", s.Name)
+		}
+		if _, dup := index[s.Name]; dup {
+			return fmt.Errorf("duplicate step name %q", s.Name)
+		}
+		index[s.Name] = i
+	}
+
+	children := make([][]int, len(steps))
+	indegree := make([]int, len(steps))
+	for i, s := range steps {
+		for _, edges := range [][]string{s.OnSuccess, s.OnFailure} {
+			for _, name := range edges {
+				j, ok := index[name]
+				if !ok {
+					return fmt.Errorf("step %q: unknown step %q", s.Name, name)
+				}
+				if j == i {
+					return fmt.Errorf("step %q: a step cannot trigger itself", s.Name)
+				}
+				children[i] = append(children[i], j)
+				indegree[j]++
+			}
+		}
+	}
+
+	// Kahn's algorithm, collecting each step's ancestors on the way.
+	ancestors := make([]map[string]bool, len(steps))
+	var ready []int
+	for i := range steps {
+		ancestors[i] = map[string]bool{}
+		if indegree[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	visited := 0
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		visited++
+		for _, j := range children[i] {
+			ancestors[j][steps[i].Name] = true
+			for a := range ancestors[i] {
+				ancestors[j][a] = true
+			}
+			if indegree[j]--; indegree[j] == 0 {
+				ready = append(ready, j)
+			}
+		}
+	}
+	if visited != len(steps) {
+		return errors.New("steps must not form a cycle")
+	}
+
+	for i, s := range steps {
+		var payload any
+		if err := json.Unmarshal(s.Payload, &payload); err != nil {
+			return fmt.Errorf("step %q: payload must be a JSON object", s.Name)
+		}
+		if _, ok := payload.(map[string]any); !ok {
+			return fmt.Errorf("step %q: payload must be a JSON object", s.Name)
+		}
+		for _, ref := range references(payload) {
+			if !ancestors[i][ref.step] {
+				return fmt.Errorf("step %q: {{steps.%s.%s}} must refer to a step that runs before it", s.Name, ref.step, ref.field)
+			}
+			if !resultFields[ref.field] || (ref.field != "output" && len(ref.path) > 0) {
+				return fmt.Errorf("step %q: unknown field %q of step %q; use status, error, job_id or output", s.Name, ref.field, ref.step)
+			}
+		}
+	}
+	return nil
+}
+
+// Roots reports the steps no edge leads to, which are queued as soon as the
+// workflow is created.
+func Roots(steps []Step) map[string]bool {
+	roots := make(map[string]bool, len(steps))
+	for _, s := range steps {
+		roots[s.Name] = true
+	}
+	for _, s := range steps {
+		for _, name := range s.OnSuccess {
+			delete(roots, name)
+		}
+		for _, name := range s.OnFailure {
+			delete(roots, name)
+		}
+	}
+	return roots
+}
+
+// Store is the subset of store.Querier used to advance workflows.
+type Store interface {
+	LockJobWorkflow(ctx context.Context, jobID uuid.UUID) (uuid.UUID, error)
+	FinishWorkflowStep(ctx context.Context, jobID uuid.UUID) (store.WorkflowStep, error)
+	ListWorkflowSteps(ctx context.Context, workflowID uuid.UUID) ([]store.WorkflowStep, error)
+	QueueWorkflowStep(ctx context.Context, arg store.QueueWorkflowStepParams) (store.Job, error)
+	SkipWorkflowStep(ctx context.Context, arg store.SkipWorkflowStepParams) (int64, error)
+	FinishWorkflow(ctx context.Context, arg store.FinishWorkflowParams) (int64, error)
+}
+
+// Transactor runs fn with a Store whose queries belong to one transaction,
+// committed if fn returns nil and rolled back otherwise.
+type Transactor func(ctx context.Context, fn func(q Store) error) error
+
+// Beginner begins transactions; *pgxpool.Pool implements it.
+type Beginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// InTx returns a Transactor running transactions begun on db.
+func InTx(db Beginner) Transactor {
+	return func(ctx context.Context, fn func(q Store) error) error {
+		return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+			return fn(store.New(tx))
+		})
+	}
+}
+
+// Direct returns a Transactor that runs fn on q outside any transaction, for
+// stores that cannot begin one.
+func Direct(q Store) Transactor {
+	return func(_ context.Context, fn func(q Store) error) error {
+		return fn(q)
+	}
+}
+
+// Codec opens and seals the stored payloads of a tenant's steps, which are
+// encrypted at rest.
+type Codec interface {
+	OpenPayload(ctx context.Context, tenantID uuid.UUID, payload []byte) ([]byte, error)
+	SealPayload(ctx context.Context, tenantID uuid.UUID, payload []byte) ([]byte, error)
+}
+
+// Advance records the outcome of the finished job jobID, of tenant tenantID,
+// on its workflow step, queues the steps it unblocks with their placeholders
+// filled in, and skips the steps it rules out. Step payloads are opened with
+// codec before rendering and sealed again; a nil codec leaves them as stored.
+// Once no step is left to run the workflow is marked failed if a step failed
+// without an on_failure edge, cancelled if a step was cancelled, and completed
+// otherwise.
+//
+// All of this happens in one transaction of tx: if any of it fails, the step
+// is left queued with its outcome unrecorded, to be advanced again later. It
+// is a no-op for jobs that are not a queued step's. Concurrent calls for steps
+// of the same workflow are safe: they lock the workflow and run one at a time,
+// so the last step to finish always sees the outcomes of the others, and a
+// step is queued or skipped at most once.
+func Advance(ctx context.Context, tx Transactor, codec Codec, tenantID, jobID uuid.UUID) error {
+	return tx(ctx, func(q Store) error {
+		return advance(ctx, q, codec, tenantID, jobID)
+	})
+}
+
+func advance(ctx context.Context, q Store, codec Codec, tenantID, jobID uuid.UUID) error {
+	if _, err := q.LockJobWorkflow(ctx, jobID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("lock workflow: %w", err)
+	}
+	finished, err := q.FinishWorkflowStep(ctx, jobID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("record step outcome: %w", err)
+	}
+	steps, err := q.ListWorkflowSteps(ctx, finished.WorkflowID)
+	if err != nil {
+		return fmt.Errorf("list steps: %w", err)
+	}
+
+	for {
+		runnable, skipped := next(steps)
+		if len(runnable) == 0 && len(skipped) == 0 {
+			break
+		}
+		for _, s := range skipped {
+			if _, err := q.SkipWorkflowStep(ctx, store.SkipWorkflowStepParams{WorkflowID: s.WorkflowID, Name: s.Name}); err != nil {
+				return fmt.Errorf("skip step %q: %w", s.Name, err)
+			}
+			s.Status = "skipped"
+		}
+		for _, s := range runnable {
+			payload, err := render(ctx, codec, tenantID, s.Payload, results(steps))
+			if err != nil {
+				return fmt.Errorf("render step %q: %w", s.Name, err)
+			}
+			_, err = q.QueueWorkflowStep(ctx, store.QueueWorkflowStepParams{WorkflowID: s.WorkflowID, Name: s.Name, Payload: payload})
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("queue step %q: %w", s.Name, err)
+			}
+			s.Status = "queued"
+		}
+	}
+
+	if status, done := outcome(steps); done {
+		if _, err := q.FinishWorkflow(ctx, store.FinishWorkflowParams{ID: finished.WorkflowID, Status: status}); err != nil {
+			return fmt.Errorf("finish workflow: %w", err)
+		}
+	}
+	return nil
+}
+
+// render opens a stored step payload, fills in its placeholders and seals
+// the result.
+func render(ctx context.Context, codec Codec, tenantID uuid.UUID, stored []byte, results map[string]Result) ([]byte, error) {
+	if codec == nil {
+		return Render(stored, results)
+	}
+	tmpl, err := codec.OpenPayload(ctx, tenantID, stored)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := Render(tmpl, results)
+	if err != nil {
+		return nil, err
+	}
+	return codec.SealPayload(ctx, tenantID, payload)
+}
+
+func isFinished(status string) bool {
+	switch status {
+	case "completed", "failed", "cancelled", "skipped":
+		return true
+	}
+	return false
+}
+
+// next returns the waiting steps whose upstream steps have all finished,
+// split into those an edge fired for and those none did.
+func next(steps []store.WorkflowStep) (runnable, skipped []*store.WorkflowStep) {
+	type edge struct {
+		from      *store.WorkflowStep
+		onSuccess bool
+	}
+	incoming := make(map[string][]edge, len(steps))
+	for i := range steps {
+		p := &steps[i]
+		for _, name := range p.OnSuccess {
+			incoming[name] = append(incoming[name], edge{p, true})
+		}
+		for _, name := range p.OnFailure {
+			incoming[name] = append(incoming[name], edge{p, false})
+		}
+	}
+
+	for i := range steps {
+		s := &steps[i]
+		edges := incoming[s.Name]
+		if s.Status != "waiting" || len(edges) == 0 {
+			continue
+		}
+		ready, fired := true, false
+		for _, e := range edges {
+			if !isFinished(e.from.Status) {
+				ready = false
+				break
+			}
+			if (e.onSuccess && e.from.Status == "completed") || (!e.onSuccess && e.from.Status == "failed") {
+				fired = true
+			}
+		}
+		switch {
+		case !ready:
+		case fired:
+			runnable = append(runnable, s)
+		default:
+			skipped = append(skipped, s)
+		}
+	}
+	return runnable, skipped
+}
+
+// outcome reports the workflow's final status, or false if a step is still
+// to run.
+func outcome(steps []store.WorkflowStep) (string, bool) {
+	status := "completed"
+	for _, s := range steps {
+		switch {
+		case s.Status == "waiting" || s.Status == "queued":
+			return "", false
+		case s.Status == "failed" && len(s.OnFailure) == 0:
+			status = "failed"
+		case s.Status == "cancelled" && status == "completed":
+			status = "cancelled"
+		}
+	}
+	return status, true
+}
+
+// results collects the results of the finished steps for Render.
+func results(steps []store.WorkflowStep) map[string]Result {
+	m := make(map[string]Result, len(steps))
+	for _, s := range steps {
+		if s.Status != "completed" && s.Status != "failed" && s.Status != "cancelled" {
+			continue
+		}
+		r := Result{Status: s.Status, Error: s.Error.String, Output: json.RawMessage(s.Output)}
+		if s.JobID.Valid {
+			r.JobID = uuid.UUID(s.JobID.Bytes).String()
+		}
+		m[s.Name] = r
+	}
+	return m
+}
@@ -0,0 +1,376 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/gsarma/tusker/internal/store"
+)
+
+func TestValidate(t *testing.T) {
+	obj := json.RawMessage(`{}`)
+	cases := []struct {
+		name    string
+		steps   []Step
+		wantErr string
+	}{
+		{"ok", []Step{
+			{Name: "run", Payload: obj, OnSuccess: []string{"report"}, OnFailure: []string{"alert"}},
+			{Name: "report", Payload: json.RawMessage(`{"body":"{{steps.run.output.stdout}}"}`)},
+			{Name: "alert", Payload: json.RawMessage(`{"body":"failed: {{ steps.run.error }}"}`)},
+		}, ""},
+		{"empty", nil, "between 1 and"},
+		{"bad name", []Step{{Name: "Run", Payload: obj}}, "step name"},
+		{"duplicate", []Step{{Name: "a", Payload: obj}, {Name: "a", Payload: obj}}, "duplicate"},
+		{"unknown edge", []Step{{Name: "a", Payload: obj, OnSuccess: []string{"b"}}}, `unknown step "b"`},
+		{"self edge", []Step{{Name: "a", Payload: obj, OnFailure: []string{"a"}}}, "itself"},
+		{"cycle", []Step{
+			{Name: "a", Payload: obj, OnSuccess: []string{"b"}},
+			{Name: "b", Payload: obj, OnSuccess: []string{"c"}},
+			{Name: "c", Payload: obj, OnSuccess: []string{"b"}},
+		}, "cycle"},
+		{"payload not object", []Step{{Name: "a", Payload: json.RawMessage(`[]`)}}, "JSON object"},
+		{"reference to later step", []Step{
+			{Name: "a", Payload: json.RawMessage(`{"x":"{{steps.b.status}}"}`), OnSuccess: []string{"b"}},
+			{Name: "b", Payload: obj},
+		}, "runs before it"},
+		{"reference to unrelated step", []Step{
+			{Name: "a", Payload: obj},
+			{Name: "b", Payload: json.RawMessage(`{"x":"{{steps.a.status}}"}`)},
+		}, "runs before it"},
+		{"unknown field", []Step{
+			{Name: "a", Payload: obj, OnSuccess: []string{"b"}},
+			{Name: "b", Payload: json.RawMessage(`{"x":"{{steps.a.stdout}}"}`)},
+		}, "unknown field"},
+		{"transitive reference", []Step{
+			{Name: "a", Payload: obj, OnSuccess: []string{"b"}},
+			{Name: "b", Payload: obj, OnSuccess: []string{"c"}},
+			{Name: "c", Payload: json.RawMessage(`{"x":["{{steps.a.job_id}}"]}`)},
+		}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate(tc.steps)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRoots(t *testing.T) {
+	roots := Roots([]Step{
+		{Name: "a", OnSuccess: []string{"c"}},
+		{Name: "b", OnFailure: []string{"c"}},
+		{Name: "c"},
+	})
+	if len(roots) != 2 || !roots["a"] || !roots["b"] {
+		t.Errorf("unexpected roots: %v", roots)
+	}
+}
+
+func TestRender(t *testing.T) {
+	results := map[string]Result{
+		"run": {
+			JobID:  "job-1",
+			Status: "completed",
+			Output: json.RawMessage(`{"stdout":"42\n","memory":1024,"lines":["a","b"]}`),
+		},
+	}
+	payload := `{
+		"body": "Result: {{steps.run.output.stdout}}(status {{ steps.run.status }})",
+		"memory": "{{steps.run.output.memory}}",
+		"output": "{{steps.run.output}}",
+		"second": "{{steps.run.output.lines.1}}",
+		"missing": "{{steps.run.output.nope}}",
+		"err": "[{{steps.run.error}}]",
+		"other": "{{steps.ghost.status}}",
+		"nested": {"list": ["{{steps.run.job_id}}", 7]},
+		"{{steps.run.status}}": true
+	}`
+
+	got, err := Render([]byte(payload), results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]any
+	if err := json.Unmarshal(got, &v); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"body":    "Result: 42\n(status completed)",
+		"memory":  float64(1024),
+		"output":  map[string]any{"stdout": "42\n", "memory": float64(1024), "lines": []any{"a", "b"}},
+		"second":  "b",
+		"missing": nil,
+		"err":     "[]",
+		"other":   nil,
+		"nested":  map[string]any{"list": []any{"job-1", float64(7)}},
+		// Keys are left alone.
+		"{{steps.run.status}}": true,
+	}
+	gotJSON, _ := json.Marshal(v)
+	wantJSON, _ := json.Marshal(want)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("Render =\n%s\nwant\n%s", gotJSON, wantJSON)
+	}
+}
+
+// memStore implements Store over an in-memory list of steps.
+type memStore struct {
+	steps    []store.WorkflowStep
+	queued   map[string][]byte
+	finished string
+
+	// queueErr, if set, fails QueueWorkflowStep.
+	queueErr error
+}
+
+// tx is a Transactor that undoes fn's changes if it fails.
+func (m *memStore) tx(_ context.Context, fn func(q Store) error) error {
+	steps := append([]store.WorkflowStep(nil), m.steps...)
+	queued := maps.Clone(m.queued)
+	finished := m.finished
+	if err := fn(m); err != nil {
+		m.steps, m.queued, m.finished = steps, queued, finished
+		return err
+	}
+	return nil
+}
+
+func (m *memStore) step(name string) *store.WorkflowStep {
+	for i := range m.steps {
+		if m.steps[i].Name == name {
+			return &m.steps[i]
+		}
+	}
+	return nil
+}
+
+// finish simulates the step's job ending with status and output.
+func (m *memStore) finish(name, status, output string) uuid.UUID {
+	s := m.step(name)
+	id := uuid.New()
+	s.JobID = pgtype.UUID{Bytes: id, Valid: true}
+	s.Output = []byte(output)
+	if status == "failed" {
+		s.Error = pgtype.Text{String: "boom", Valid: true}
+	}
+	s.Status = "finishing:" + status
+	return id
+}
+
+func (m *memStore) LockJobWorkflow(_ context.Context, jobID uuid.UUID) (uuid.UUID, error) {
+	for _, s := range m.steps {
+		if s.JobID.Valid && s.JobID.Bytes == jobID {
+			return s.WorkflowID, nil
+		}
+	}
+	return uuid.Nil, pgx.ErrNoRows
+}
+
+func (m *memStore) FinishWorkflowStep(_ context.Context, jobID uuid.UUID) (store.WorkflowStep, error) {
+	for i := range m.steps {
+		s := &m.steps[i]
+		if s.JobID.Valid && s.JobID.Bytes == jobID && strings.HasPrefix(s.Status, "finishing:") {
+			s.Status = strings.TrimPrefix(s.Status, "finishing:")
+			return *s, nil
+		}
+	}
+	return store.WorkflowStep{}, pgx.ErrNoRows
+}
+
+func (m *memStore) ListWorkflowSteps(context.Context, uuid.UUID) ([]store.WorkflowStep, error) {
+	return append([]store.WorkflowStep(nil), m.steps...), nil
+}
+
+func (m *memStore) QueueWorkflowStep(_ context.Context, arg store.QueueWorkflowStepParams) (store.Job, error) {
+	if m.queueErr != nil {
+		return store.Job{}, m.queueErr
+	}
+	s := m.step(arg.Name)
+	if s.Status != "waiting" {
+		return store.Job{}, pgx.ErrNoRows
+	}
+	s.Status = "queued"
+	m.queued[arg.Name] = arg.Payload
+	return store.Job{}, nil
+}
+
+func (m *memStore) SkipWorkflowStep(_ context.Context, arg store.SkipWorkflowStepParams) (int64, error) {
+	s := m.step(arg.Name)
+	if s.Status != "waiting" {
+		return 0, nil
+	}
+	s.Status = "skipped"
+	return 1, nil
+}
+
+func (m *memStore) FinishWorkflow(_ context.Context, arg store.FinishWorkflowParams) (int64, error) {
+	m.finished = arg.Status
+	return 1, nil
+}
+
+func newMemStore(steps ...store.WorkflowStep) *memStore {
+	for i := range steps {
+		if steps[i].Status == "" {
+			steps[i].Status = "waiting"
+		}
+		if steps[i].Payload == nil {
+			steps[i].Payload = []byte(`{}`)
+		}
+	}
+	return &memStore{steps: steps, queued: map[string][]byte{}}
+}
+
+func TestAdvance_OnSuccessQueuesRenderedStepAndSkipsFailureBranch(t *testing.T) {
+	m := newMemStore(
+		store.WorkflowStep{Name: "run", OnSuccess: []string{"report"}, OnFailure: []string{"alert"}},
+		store.WorkflowStep{Name: "report", Payload: []byte(`{"body":"{{steps.run.output.stdout}}"}`), OnSuccess: []string{"audit"}},
+		store.WorkflowStep{Name: "alert", OnSuccess: []string{"page"}},
+		store.WorkflowStep{Name: "page"},
+		store.WorkflowStep{Name: "audit"},
+	)
+	jobID := m.finish("run", "completed", `{"stdout":"42"}`)
+
+	if err := Advance(context.Background(), m.tx, nil, uuid.Nil, jobID); err != nil {
+		t.Fatal(err)
+	}
+	if string(m.queued["report"]) != `{"body":"42"}` {
+		t.Errorf("unexpected report payload: %s", m.queued["report"])
+	}
+	if m.step("alert").Status != "skipped" || m.step("page").Status != "skipped" {
+		t.Errorf("expected failure branch to be skipped, got alert=%s page=%s", m.step("alert").Status, m.step("page").Status)
+	}
+	if m.step("audit").Status != "waiting" || m.finished != "" {
+		t.Errorf("workflow should still be running: audit=%s finished=%q", m.step("audit").Status, m.finished)
+	}
+
+	// report fails without an on_failure edge: audit is skipped and the
+	// workflow fails.
+	jobID = m.finish("report", "failed", "")
+	if err := Advance(context.Background(), m.tx, nil, uuid.Nil, jobID); err != nil {
+		t.Fatal(err)
+	}
+	if m.step("audit").Status != "skipped" || m.finished != "failed" {
+		t.Errorf("expected audit skipped and workflow failed, got audit=%s finished=%q", m.step("audit").Status, m.finished)
+	}
+}
+
+func TestAdvance_HandledFailureCompletesWorkflow(t *testing.T) {
+	m := newMemStore(
+		store.WorkflowStep{Name: "sms", OnFailure: []string{"email"}},
+		store.WorkflowStep{Name: "email", Payload: []byte(`{"body":"SMS failed: {{steps.sms.error}}"}`)},
+	)
+	if err := Advance(context.Background(), m.tx, nil, uuid.Nil, m.finish("sms", "failed", "")); err != nil {
+		t.Fatal(err)
+	}
+	if string(m.queued["email"]) != `{"body":"SMS failed: boom"}` {
+		t.Fatalf("unexpected email payload: %s", m.queued["email"])
+	}
+	if err := Advance(context.Background(), m.tx, nil, uuid.Nil, m.finish("email", "completed", "")); err != nil {
+		t.Fatal(err)
+	}
+	if m.finished != "completed" {
+		t.Errorf("expected workflow completed, got %q", m.finished)
+	}
+}
+
+func TestAdvance_JoinWaitsForAllUpstreamSteps(t *testing.T) {
+	m := newMemStore(
+		store.WorkflowStep{Name: "a", OnSuccess: []string{"join"}},
+		store.WorkflowStep{Name: "b", OnSuccess: []string{"join"}},
+		store.WorkflowStep{Name: "join"},
+	)
+	if err := Advance(context.Background(), m.tx, nil, uuid.Nil, m.finish("a", "completed", "")); err != nil {
+		t.Fatal(err)
+	}
+	if m.step("join").Status != "waiting" {
+		t.Fatalf("join should wait for b, got %s", m.step("join").Status)
+	}
+	// b failing does not fire its edge, but a's did.
+	if err := Advance(context.Background(), m.tx, nil, uuid.Nil, m.finish("b", "failed", "")); err != nil {
+		t.Fatal(err)
+	}
+	if m.step("join").Status != "queued" {
+		t.Errorf("expected join queued, got %s", m.step("join").Status)
+	}
+}
+
+func TestAdvance_UnknownJobIsNoop(t *testing.T) {
+	m := newMemStore(store.WorkflowStep{Name: "a"})
+	if err := Advance(context.Background(), m.tx, nil, uuid.Nil, uuid.New()); err != nil {
+		t.Fatal(err)
+	}
+	if m.finished != "" {
+		t.Errorf("unexpected finish: %q", m.finished)
+	}
+}
+
+func TestAdvance_FailureLeavesStepUnfinished(t *testing.T) {
+	m := newMemStore(
+		store.WorkflowStep{Name: "run", OnSuccess: []string{"report"}, OnFailure: []string{"alert"}},
+		store.WorkflowStep{Name: "report"},
+		store.WorkflowStep{Name: "alert"},
+	)
+	jobID := m.finish("run", "completed", "")
+	m.queueErr = errors.New("connection reset")
+
+	if err := Advance(context.Background(), m.tx, nil, uuid.Nil, jobID); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := m.step("run").Status; got != "finishing:completed" {
+		t.Errorf("step outcome should not be recorded, got %s", got)
+	}
+	if m.step("alert").Status != "waiting" || m.finished != "" {
+		t.Errorf("expected no changes, got alert=%s finished=%q", m.step("alert").Status, m.finished)
+	}
+
+	// Advancing again once the store recovers picks up where it failed.
+	m.queueErr = nil
+	if err := Advance(context.Background(), m.tx, nil, uuid.Nil, jobID); err != nil {
+		t.Fatal(err)
+	}
+	if m.step("run").Status != "completed" || m.step("report").Status != "queued" || m.step("alert").Status != "skipped" {
+		t.Errorf("unexpected steps: run=%s report=%s alert=%s", m.step("run").Status, m.step("report").Status, m.step("alert").Status)
+	}
+}
+
+// prefixCodec seals a payload by prefixing it with its tenant id.
+type prefixCodec struct{}
+
+func (prefixCodec) OpenPayload(_ context.Context, tenantID uuid.UUID, payload []byte) ([]byte, error) {
+	return []byte(strings.TrimPrefix(string(payload), tenantID.String()+":")), nil
+}
+
+func (prefixCodec) SealPayload(_ context.Context, tenantID uuid.UUID, payload []byte) ([]byte, error) {
+	return []byte(tenantID.String() + ":" + string(payload)), nil
+}
+
+func TestAdvance_OpensAndSealsStepPayloads(t *testing.T) {
+	tenantID := uuid.New()
+	m := newMemStore(
+		store.WorkflowStep{Name: "run", OnSuccess: []string{"report"}},
+		store.WorkflowStep{Name: "report", Payload: []byte(tenantID.String() + `:{"body":"{{steps.run.status}}"}`)},
+	)
+	if err := Advance(context.Background(), m.tx, prefixCodec{}, tenantID, m.finish("run", "completed", "")); err != nil {
+		t.Fatal(err)
+	}
+	if want := tenantID.String() + `:{"body":"completed"}`; string(m.queued["report"]) != want {
+		t.Errorf("unexpected report payload: %s, want %s", m.queued["report"], want)
+	}
+}
@@ -2,8 +2,10 @@ package tusker
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // EmailService provides email configuration, sending, and template operations.
@@ -25,19 +27,118 @@ type SendOptions struct {
 	// Sync, when true, waits for the email to be delivered before returning.
 	// By default emails are queued and sent asynchronously.
 	Sync bool
+
+	// SendAt schedules an async send for a future time. The server rejects
+	// times more than 30 days ahead, and SendAt cannot be combined with Sync.
+	SendAt *time.Time
+
+	// Retry overrides the server's default retry policy for the job type.
+	// It applies to async sends only.
+	Retry *RetryPolicy
+
+	// Queue routes an async send to a named queue (default "default"), e.g.
+	// "transactional" for mail served by dedicated workers.
+	Queue string
+
+	// Priority orders async sends within a queue, 0–100; higher runs first.
+	Priority int
+
+	// IdempotencyKey makes the send safe to repeat: the server queues at most
+	// one job per key (per tenant, for 24 hours) and answers repeats with the
+	// original response. Reusing a key for a different request fails with an
+	// *APIError with StatusCode 422. If empty, a key is generated per call so
+	// the client's own retries (see WithRetries) never send twice.
+	IdempotencyKey string
+}
+
+func (o *SendOptions) idempotencyKey() string {
+	if o == nil {
+		return ""
+	}
+	return o.IdempotencyKey
+}
+
+// RetryPolicy controls how a failed async job is retried. Zero-valued fields
+// keep the server's default for the job type.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, 1–25. Use 1 to fail fast.
+	MaxAttempts int
+	// BaseDelay is the base of the backoff: the n-th retry waits BaseDelay
+	// times 2^n, so the first retry waits twice BaseDelay. Whole seconds, up
+	// to one hour.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries. Whole seconds, up to 24 hours.
+	MaxDelay time.Duration
+	// Jitter, between 0 and 1, shortens each delay by a random fraction of up
+	// to Jitter so that many failed jobs do not retry in lockstep.
+	Jitter *float64
+}
+
+func (p *RetryPolicy) body() map[string]any {
+	body := map[string]any{}
+	if p.MaxAttempts > 0 {
+		body["max_attempts"] = p.MaxAttempts
+	}
+	if p.BaseDelay > 0 {
+		body["base_delay_seconds"] = int(p.BaseDelay / time.Second)
+	}
+	if p.MaxDelay > 0 {
+		body["max_delay_seconds"] = int(p.MaxDelay / time.Second)
+	}
+	if p.Jitter != nil {
+		body["jitter"] = *p.Jitter
+	}
+	return body
+}
+
+// apply returns the query parameters for opts and req with any scheduling
+// and retry fields merged into the JSON body.
+func (o *SendOptions) apply(req any) (map[string]string, any, error) {
+	query := map[string]string{}
+	if o == nil {
+		return query, req, nil
+	}
+	if o.Sync {
+		query["sync"] = "true"
+	}
+	if o.SendAt == nil && o.Retry == nil && o.Queue == "" && o.Priority == 0 {
+		return query, req, nil
+	}
+
+	raw, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tusker: marshal request: %w", err)
+	}
+	body := map[string]any{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, nil, fmt.Errorf("tusker: marshal request: %w", err)
+	}
+	if o.SendAt != nil {
+		body["send_at"] = o.SendAt.UTC().Format(time.RFC3339)
+	}
+	if o.Retry != nil {
+		body["retry"] = o.Retry.body()
+	}
+	if o.Queue != "" {
+		body["queue"] = o.Queue
+	}
+	if o.Priority != 0 {
+		body["priority"] = o.Priority
+	}
+	return query, body, nil
 }
 
 // Send queues (or synchronously sends) an email via the given provider.
 // provider is one of "smtp" or "sendgrid".
 func (s *EmailService) Send(ctx context.Context, provider string, req SendEmailRequest, opts *SendOptions) (*SendEmailResponse, error) {
 	path := fmt.Sprintf("/email/%s/send", provider)
-	query := map[string]string{}
-	if opts != nil && opts.Sync {
-		query["sync"] = "true"
+	query, body, err := opts.apply(req)
+	if err != nil {
+		return nil, err
 	}
 	// Async returns 202, sync returns 200
-	return doRequestWithQuery[SendEmailResponse](ctx, s.c, http.MethodPost, path, query, req,
-		http.StatusAccepted, http.StatusOK)
+	return doIdempotentRequest[SendEmailResponse](ctx, s.c, http.MethodPost, path, query, body,
+		opts.idempotencyKey(), http.StatusAccepted, http.StatusOK)
 }
 
 // CreateTemplate creates or updates a named email template.
@@ -66,5 +167,21 @@ func (s *EmailService) DeleteTemplate(ctx context.Context, name string) error {
 // provider is one of "smtp" or "sendgrid".
 func (s *EmailService) SendTemplate(ctx context.Context, provider string, req SendTemplateRequest) (*StatusResponse, error) {
 	path := fmt.Sprintf("/email/%s/send-template", provider)
-	return doRequest[StatusResponse](ctx, s.c, http.MethodPost, path, req, http.StatusOK)
+	return doIdempotentRequest[StatusResponse](ctx, s.c, http.MethodPost, path, nil, req, "", http.StatusOK)
+}
+
+// SendTemplateWithOptions is SendTemplate with SendOptions: like Send, it
+// queues the email unless opts.Sync is set.
+func (s *EmailService) SendTemplateWithOptions(ctx context.Context, provider string, req SendTemplateRequest, opts *SendOptions) (*SendEmailResponse, error) {
+	path := fmt.Sprintf("/email/%s/send-template", provider)
+	query, body, err := opts.apply(req)
+	if err != nil {
+		return nil, err
+	}
+	if query["sync"] == "" {
+		query["async"] = "true"
+	}
+	// Async returns 202, sync returns 200
+	return doIdempotentRequest[SendEmailResponse](ctx, s.c, http.MethodPost, path, query, body,
+		opts.idempotencyKey(), http.StatusAccepted, http.StatusOK)
 }
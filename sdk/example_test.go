@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	tusker "github.com/gsarma/tusker/sdk"
 )
@@ -11,8 +12,8 @@ import (
 func Example_basicUsage() {
 	ctx := context.Background()
 
-	// --- Provision a new tenant (one-time setup, no API key needed) ---
-	provisioner := tusker.NewProvisioner("https://api.tusker.io")
+	// --- Provision a new tenant (one-time setup, needs the admin key) ---
+	provisioner := tusker.NewProvisioner("https://api.tusker.io", "your-admin-key")
 	tenant, err := provisioner.CreateTenant(ctx)
 	if err != nil {
 		log.Fatal(err)
@@ -105,6 +106,23 @@ func Example_sms() {
 	fmt.Println("Message SID:", resp.MessageSID)
 }
 
+func Example_scheduledSend() {
+	ctx := context.Background()
+	client := tusker.New("https://api.tusker.io", "your-api-key")
+
+	// Queue a reminder to go out tomorrow; the worker holds it until then.
+	sendAt := time.Now().Add(24 * time.Hour)
+	resp, err := client.SMS.Send(ctx, "twilio", tusker.SendSMSRequest{
+		From: "+15550001234",
+		To:   "+15559876543",
+		Body: "Reminder: your appointment is tomorrow at 10am",
+	}, &tusker.SendOptions{SendAt: &sendAt})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Job ID:", resp.JobID, "runs at", resp.RunAt)
+}
+
 func Example_emailTemplates() {
 	ctx := context.Background()
 	client := tusker.New("https://api.tusker.io", "your-api-key")
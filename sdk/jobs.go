@@ -4,16 +4,96 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 )
 
-// JobsService provides job status lookup operations.
+// JobsService provides job status lookup and cancellation operations.
 type JobsService struct {
 	c *Client
 }
 
-// Get retrieves the current status and metadata for a background job.
+// ListJobsOptions filters and paginates Jobs.List. Zero values are ignored.
+type ListJobsOptions struct {
+	Status        string    // one of the JobStatus constants
+	JobType       string    // e.g. "email.send"
+	CreatedAfter  time.Time // inclusive
+	CreatedBefore time.Time // exclusive
+	Limit         int       // page size; the server defaults to 50 and allows up to 200
+	Cursor        string    // JobList.NextCursor from the previous page
+}
+
+// List returns a page of the tenant's jobs, newest first, together with job
+// counts per status. Pass the returned NextCursor in opts.Cursor to fetch the
+// next page; it is empty on the last page.
+func (s *JobsService) List(ctx context.Context, opts *ListJobsOptions) (*JobList, error) {
+	return doRequestWithQuery[JobList](ctx, s.c, http.MethodGet, "/jobs", opts.query(), nil, http.StatusOK)
+}
+
+func (o *ListJobsOptions) query() map[string]string {
+	query := map[string]string{}
+	if o == nil {
+		return query
+	}
+	if o.Status != "" {
+		query["status"] = o.Status
+	}
+	if o.JobType != "" {
+		query["job_type"] = o.JobType
+	}
+	if !o.CreatedAfter.IsZero() {
+		query["created_after"] = o.CreatedAfter.UTC().Format(time.RFC3339)
+	}
+	if !o.CreatedBefore.IsZero() {
+		query["created_before"] = o.CreatedBefore.UTC().Format(time.RFC3339)
+	}
+	if o.Limit > 0 {
+		query["limit"] = strconv.Itoa(o.Limit)
+	}
+	if o.Cursor != "" {
+		query["cursor"] = o.Cursor
+	}
+	return query
+}
+
+// DeadLetter returns a page of permanently failed jobs, newest first, each
+// with its attempt timeline. opts.Status is ignored.
+func (s *JobsService) DeadLetter(ctx context.Context, opts *ListJobsOptions) (*DeadLetterList, error) {
+	query := opts.query()
+	delete(query, "status")
+	return doRequestWithQuery[DeadLetterList](ctx, s.c, http.MethodGet, "/jobs/dead-letter", query, nil, http.StatusOK)
+}
+
+// Retry re-enqueues a failed job with a fresh set of attempts.
+// Retrying a job that is not failed returns an *APIError with StatusCode 409.
+func (s *JobsService) Retry(ctx context.Context, jobID string) (*JobActionResponse, error) {
+	path := fmt.Sprintf("/jobs/%s/retry", jobID)
+	return doRequest[JobActionResponse](ctx, s.c, http.MethodPost, path, nil, http.StatusAccepted)
+}
+
+// Replay re-enqueues failed jobs created within a time window, e.g. after a
+// provider outage. Each call replays at most 1000 jobs; call again while
+// ReplayResponse.More is true.
+func (s *JobsService) Replay(ctx context.Context, req ReplayRequest) (*ReplayResponse, error) {
+	return doRequest[ReplayResponse](ctx, s.c, http.MethodPost, "/jobs/dead-letter/replay", req, http.StatusAccepted)
+}
+
+// Get retrieves the current status, metadata and attempt timeline for a
+// background job.
 // jobID is the UUID returned when a send operation is queued asynchronously.
 func (s *JobsService) Get(ctx context.Context, jobID string) (*Job, error) {
 	path := fmt.Sprintf("/jobs/%s", jobID)
 	return doRequest[Job](ctx, s.c, http.MethodGet, path, nil, http.StatusOK)
 }
+
+// Cancel cancels a queued or scheduled job so it never runs; the response
+// status is "cancelled". A running job can only be cancelled if its type
+// supports it (currently code execution): the response status is then
+// "cancelling" and the job moves to cancelled once its worker stops it.
+// Cancelling a running job of another type, or a finished job, returns an
+// *APIError with StatusCode 409.
+func (s *JobsService) Cancel(ctx context.Context, jobID string) (*JobActionResponse, error) {
+	path := fmt.Sprintf("/jobs/%s", jobID)
+	return doRequestWithQuery[JobActionResponse](ctx, s.c, http.MethodDelete, path, nil, nil,
+		http.StatusOK, http.StatusAccepted)
+}
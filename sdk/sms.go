@@ -24,11 +24,11 @@ func (s *SMSService) SetConfig(ctx context.Context, provider string, cfg any) er
 // provider is currently "twilio".
 func (s *SMSService) Send(ctx context.Context, provider string, req SendSMSRequest, opts *SendOptions) (*SendSMSResponse, error) {
 	path := fmt.Sprintf("/sms/%s/send", provider)
-	query := map[string]string{}
-	if opts != nil && opts.Sync {
-		query["sync"] = "true"
+	query, body, err := opts.apply(req)
+	if err != nil {
+		return nil, err
 	}
 	// Async returns 202, sync returns 200
-	return doRequestWithQuery[SendSMSResponse](ctx, s.c, http.MethodPost, path, query, req,
-		http.StatusAccepted, http.StatusOK)
+	return doIdempotentRequest[SendSMSResponse](ctx, s.c, http.MethodPost, path, query, body,
+		opts.idempotencyKey(), http.StatusAccepted, http.StatusOK)
 }
@@ -7,8 +7,8 @@
 //
 //	client := tusker.New("https://api.tusker.io", "your-api-key")
 //
-//	// Create a tenant (no API key required)
-//	provisioner := tusker.NewProvisioner("https://api.tusker.io")
+//	// Create a tenant (requires the server's admin key)
+//	provisioner := tusker.NewProvisioner("https://api.tusker.io", "your-admin-key")
 //	tenant, err := provisioner.CreateTenant(ctx)
 //
 //	// Send an email
@@ -23,11 +23,14 @@ package tusker
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // Client is the authenticated Tusker API client.
@@ -35,17 +38,20 @@ type Client struct {
 	baseURL    string
 	apiKey     string
 	httpClient *http.Client
+	maxRetries int
 
 	// Service accessors
-	OAuth  *OAuthService
-	Email  *EmailService
-	SMS    *SMSService
-	Jobs   *JobsService
+	OAuth    *OAuthService
+	Email    *EmailService
+	SMS      *SMSService
+	Jobs     *JobsService
+	Webhooks *WebhooksService
 }
 
-// Provisioner is an unauthenticated client used only for tenant provisioning.
+// Provisioner is an admin-authenticated client used only for tenant provisioning.
 type Provisioner struct {
 	baseURL    string
+	adminKey   string
 	httpClient *http.Client
 }
 
@@ -59,6 +65,22 @@ func WithHTTPClient(hc *http.Client) Option {
 	}
 }
 
+// WithRetries sets how many times a send call is retried after a network
+// error, 429 or 5xx response. Retries reuse the call's Idempotency-Key, so a
+// message is never queued twice. Defaults to 2; 0 disables retries.
+func WithRetries(n int) Option {
+	return func(c *Client) {
+		c.maxRetries = n
+	}
+}
+
+// defaultRetries and retryBaseDelay control retries of send calls; the delay
+// doubles after each attempt.
+const (
+	defaultRetries = 2
+	retryBaseDelay = 500 * time.Millisecond
+)
+
 // New creates an authenticated Tusker client.
 // baseURL should be the root URL (e.g. "https://api.tusker.io").
 // apiKey is the Bearer token returned when a tenant is provisioned.
@@ -67,6 +89,7 @@ func New(baseURL, apiKey string, opts ...Option) *Client {
 		baseURL:    strings.TrimRight(baseURL, "/"),
 		apiKey:     apiKey,
 		httpClient: &http.Client{},
+		maxRetries: defaultRetries,
 	}
 	for _, o := range opts {
 		o(c)
@@ -75,13 +98,16 @@ func New(baseURL, apiKey string, opts ...Option) *Client {
 	c.Email = &EmailService{c: c}
 	c.SMS = &SMSService{c: c}
 	c.Jobs = &JobsService{c: c}
+	c.Webhooks = &WebhooksService{c: c}
 	return c
 }
 
-// NewProvisioner creates an unauthenticated client for tenant provisioning.
-func NewProvisioner(baseURL string, opts ...Option) *Provisioner {
+// NewProvisioner creates a client for tenant provisioning, authenticated with
+// the server's admin key (ADMIN_API_KEY).
+func NewProvisioner(baseURL, adminKey string, opts ...Option) *Provisioner {
 	p := &Provisioner{
 		baseURL:    strings.TrimRight(baseURL, "/"),
+		adminKey:   adminKey,
 		httpClient: &http.Client{},
 	}
 	// Apply any HTTP client options via a temporary Client to reuse Option type
@@ -98,10 +124,11 @@ func NewProvisioner(baseURL string, opts ...Option) *Provisioner {
 // CreateTenant provisions a new tenant and returns the API key.
 // Store the returned API key securely — it is shown only once.
 func (p *Provisioner) CreateTenant(ctx context.Context) (*CreateTenantResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/tenants", nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/admin/tenants", nil)
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Authorization", "Bearer "+p.adminKey)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := p.httpClient.Do(req)
@@ -189,7 +216,74 @@ func doRequestWithQuery[T any](ctx context.Context, c *Client, method, path stri
 		return nil, err
 	}
 	defer resp.Body.Close()
+	return decodeResponse[T](resp, expectedStatuses...)
+}
+
+// doIdempotentRequest is doRequestWithQuery for send calls: it sends key (or a
+// generated one) as the Idempotency-Key header and retries network errors,
+// 409 (the first attempt is still in progress), 429 and 5xx responses up to
+// the client's retry limit with the same key.
+func doIdempotentRequest[T any](ctx context.Context, c *Client, method, path string, query map[string]string, body any, key string, expectedStatuses ...int) (*T, error) {
+	if key == "" {
+		var err error
+		if key, err = newIdempotencyKey(); err != nil {
+			return nil, err
+		}
+	}
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		req, err := c.newRequest(ctx, method, path, body)
+		if err != nil {
+			return nil, err
+		}
+		if len(query) > 0 {
+			q := req.URL.Query()
+			for k, v := range query {
+				q.Set(k, v)
+			}
+			req.URL.RawQuery = q.Encode()
+		}
+		req.Header.Set("Idempotency-Key", key)
+
+		resp, err := c.httpClient.Do(req)
+		if attempt >= c.maxRetries || ctx.Err() != nil || !shouldRetry(resp, err) {
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			return decodeResponse[T](resp, expectedStatuses...)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusConflict ||
+		resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode >= 500
+}
+
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("tusker: generate idempotency key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
 
+func decodeResponse[T any](resp *http.Response, expectedStatuses ...int) (*T, error) {
 	for _, s := range expectedStatuses {
 		if resp.StatusCode == s {
 			var out T
@@ -1,6 +1,9 @@
 package tusker
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // --- Tenant ---
 
@@ -61,12 +64,14 @@ type SendEmailRequest struct {
 	HTML    bool     `json:"html"`
 }
 
-// SendEmailResponse is returned by POST /email/:provider/send.
-// When async (default), JobID and Status are populated.
+// SendEmailResponse is returned by POST /email/:provider/send and send-template.
+// When async (default), JobID and Status are populated, plus RunAt for sends
+// scheduled with SendOptions.SendAt.
 // When sync (?sync=true), only Status is populated.
 type SendEmailResponse struct {
-	JobID  string `json:"job_id,omitempty"`
-	Status string `json:"status"`
+	JobID  string     `json:"job_id,omitempty"`
+	Status string     `json:"status"`
+	RunAt  *time.Time `json:"run_at,omitempty"`
 }
 
 // CreateEmailTemplateRequest creates or updates a named email template.
@@ -125,11 +130,12 @@ type SendSMSRequest struct {
 // When async (default), JobID and Status are populated.
 // When sync (?sync=true), MessageSID, Status, From, and To are populated.
 type SendSMSResponse struct {
-	JobID      string `json:"job_id,omitempty"`
-	Status     string `json:"status"`
-	MessageSID string `json:"message_sid,omitempty"`
-	From       string `json:"from,omitempty"`
-	To         string `json:"to,omitempty"`
+	JobID      string     `json:"job_id,omitempty"`
+	Status     string     `json:"status"`
+	RunAt      *time.Time `json:"run_at,omitempty"`
+	MessageSID string     `json:"message_sid,omitempty"`
+	From       string     `json:"from,omitempty"`
+	To         string     `json:"to,omitempty"`
 }
 
 // --- Jobs ---
@@ -142,11 +148,20 @@ type Job struct {
 	Status      string     `json:"status"`
 	Attempt     int        `json:"attempt"`
 	MaxAttempts int        `json:"max_attempts"`
+	Queue       string     `json:"queue"`
+	Priority    int        `json:"priority"`
 	Error       *string    `json:"error,omitempty"`
 	RunAt       time.Time  `json:"run_at"`
 	StartedAt   *time.Time `json:"started_at,omitempty"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
+
+	// CancelRequested is set while a running job is being cancelled.
+	CancelRequested bool `json:"cancel_requested"`
+
+	// Attempts is the job's attempt timeline, oldest first. It is returned by
+	// Jobs.Get and Jobs.DeadLetter but not by Jobs.List.
+	Attempts []JobAttempt `json:"attempts,omitempty"`
 }
 
 // JobStatus constants for Job.Status.
@@ -155,4 +170,114 @@ const (
 	JobStatusRunning   = "running"
 	JobStatusCompleted = "completed"
 	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
 )
+
+// JobList is returned by GET /jobs.
+type JobList struct {
+	Jobs []Job `json:"jobs"`
+	// NextCursor is set when more jobs may follow; pass it as ListJobsOptions.Cursor.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Counts holds the number of jobs per status matching the JobType and
+	// created-at filters (the Status filter is not applied).
+	Counts map[string]int64 `json:"counts"`
+}
+
+// JobAttempt records one attempt at running a job.
+type JobAttempt struct {
+	Attempt  int    `json:"attempt"`
+	WorkerID string `json:"worker_id"`
+	// Outcome is "running" while the attempt is in progress, then one of
+	// "completed", "failed", "cancelled", "interrupted" (worker shutdown) or
+	// "lease_expired" (worker stopped responding).
+	Outcome    string     `json:"outcome"`
+	Error      *string    `json:"error"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	DurationMs *int64     `json:"duration_ms"`
+}
+
+// DeadLetterList is returned by GET /jobs/dead-letter.
+type DeadLetterList struct {
+	Jobs       []Job  `json:"jobs"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ReplayRequest selects failed jobs to re-enqueue by creation time and,
+// optionally, job type.
+type ReplayRequest struct {
+	JobType       string    `json:"job_type,omitempty"`
+	CreatedAfter  time.Time `json:"created_after"`
+	CreatedBefore time.Time `json:"created_before"`
+}
+
+// ReplayResponse is returned by POST /jobs/dead-letter/replay.
+type ReplayResponse struct {
+	Replayed int64 `json:"replayed"`
+	// More is true when the batch limit was reached and more jobs may match.
+	More bool `json:"more"`
+}
+
+// JobActionResponse is returned by DELETE /jobs/:id and POST /jobs/:id/retry.
+// For a cancel, Status is "cancelled", or "cancelling" while a running job is
+// being stopped; for a retry it is "queued".
+type JobActionResponse struct {
+	JobID  string `json:"job_id"`
+	Status string `json:"status"`
+}
+
+// --- Webhooks ---
+
+// Webhook event types.
+const (
+	EventJobCompleted = "job.completed"
+	EventJobFailed    = "job.failed"
+)
+
+// CreateWebhookRequest registers an endpoint for job events.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"` // EventJobCompleted and/or EventJobFailed
+}
+
+// Webhook is a registered endpoint. Secret is only set in the response to
+// Webhooks.Create; store it to verify deliveries.
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is one entry of an endpoint's delivery log.
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	JobID          string          `json:"job_id"`
+	Event          string          `json:"event"`
+	Data           json.RawMessage `json:"data"`
+	Status         string          `json:"status"` // pending, succeeded or failed
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// WebhookEvent is the body Tusker POSTs to a webhook endpoint.
+type WebhookEvent struct {
+	ID        string           `json:"id"`
+	Type      string           `json:"type"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      WebhookEventData `json:"data"`
+}
+
+// WebhookEventData describes the job that raised a WebhookEvent.
+type WebhookEventData struct {
+	JobID       string     `json:"job_id"`
+	JobType     string     `json:"job_type"`
+	Status      string     `json:"status"`
+	Attempt     int        `json:"attempt"`
+	Error       *string    `json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
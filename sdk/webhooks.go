@@ -0,0 +1,123 @@
+package tusker
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhooksService manages endpoints notified when jobs complete or fail.
+type WebhooksService struct {
+	c *Client
+}
+
+// Create registers an endpoint. The returned Webhook.Secret is shown only
+// once; keep it to verify deliveries with ParseWebhookEvent.
+func (s *WebhooksService) Create(ctx context.Context, req CreateWebhookRequest) (*Webhook, error) {
+	return doRequest[Webhook](ctx, s.c, http.MethodPost, "/webhooks", req, http.StatusCreated)
+}
+
+// List returns the tenant's webhook endpoints.
+func (s *WebhooksService) List(ctx context.Context) ([]Webhook, error) {
+	out, err := doRequest[[]Webhook](ctx, s.c, http.MethodGet, "/webhooks", nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return *out, nil
+}
+
+// Delete removes an endpoint.
+func (s *WebhooksService) Delete(ctx context.Context, webhookID string) error {
+	path := fmt.Sprintf("/webhooks/%s", webhookID)
+	_, err := doRequest[StatusResponse](ctx, s.c, http.MethodDelete, path, nil, http.StatusOK)
+	return err
+}
+
+// Deliveries returns an endpoint's most recent deliveries, newest first.
+// limit <= 0 uses the server default of 50.
+func (s *WebhooksService) Deliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error) {
+	query := map[string]string{}
+	if limit > 0 {
+		query["limit"] = strconv.Itoa(limit)
+	}
+	path := fmt.Sprintf("/webhooks/%s/deliveries", webhookID)
+	out, err := doRequestWithQuery[[]WebhookDelivery](ctx, s.c, http.MethodGet, path, query, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return *out, nil
+}
+
+// WebhookSignatureHeader carries a delivery's signature.
+const WebhookSignatureHeader = "Tusker-Signature"
+
+// DefaultWebhookTolerance is how old a delivery's signature timestamp may be
+// before ParseWebhookEvent rejects it as a possible replay.
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// ErrInvalidWebhookSignature is returned when a delivery's signature does not
+// match its body and the endpoint's secret, or its timestamp is too old.
+var ErrInvalidWebhookSignature = errors.New("tusker: invalid webhook signature")
+
+// VerifyWebhookSignature checks that body was signed by Tusker with secret.
+// header is the value of the Tusker-Signature header, "t=<unix>,v1=<hex>",
+// where v1 is the HMAC-SHA256 of "<t>.<body>". Signatures with a timestamp
+// more than tolerance from now are rejected; tolerance <= 0 skips that check.
+func VerifyWebhookSignature(body []byte, header, secret string, tolerance time.Duration) error {
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrInvalidWebhookSignature
+	}
+	if tolerance > 0 {
+		if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+			return ErrInvalidWebhookSignature
+		}
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	want := mac.Sum(nil)
+	for _, s := range sigs {
+		if got, err := hex.DecodeString(s); err == nil && hmac.Equal(got, want) {
+			return nil
+		}
+	}
+	return ErrInvalidWebhookSignature
+}
+
+// ParseWebhookEvent verifies a delivery's signature with
+// DefaultWebhookTolerance and decodes its body. Pass the raw request body,
+// before any JSON decoding:
+//
+//	body, _ := io.ReadAll(r.Body)
+//	ev, err := tusker.ParseWebhookEvent(body, r.Header.Get(tusker.WebhookSignatureHeader), secret)
+func ParseWebhookEvent(body []byte, header, secret string) (*WebhookEvent, error) {
+	if err := VerifyWebhookSignature(body, header, secret, DefaultWebhookTolerance); err != nil {
+		return nil, err
+	}
+	var ev WebhookEvent
+	if err := json.Unmarshal(body, &ev); err != nil {
+		return nil, fmt.Errorf("tusker: decode webhook event: %w", err)
+	}
+	return &ev, nil
+}
@@ -0,0 +1,51 @@
+package tusker_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	tusker "github.com/gsarma/tusker/sdk"
+)
+
+func sign(secret string, ts time.Time, body []byte) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseWebhookEvent(t *testing.T) {
+	body := []byte(`{"id":"d1","type":"job.completed","created_at":"2025-01-01T00:00:00Z","data":{"job_id":"j1","job_type":"email.send","status":"completed","attempt":1}}`)
+	ev, err := tusker.ParseWebhookEvent(body, sign("whsec_test", time.Now(), body), "whsec_test")
+	if err != nil {
+		t.Fatalf("ParseWebhookEvent: %v", err)
+	}
+	if ev.Type != tusker.EventJobCompleted || ev.Data.JobID != "j1" || ev.Data.Status != "completed" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
+
+func TestVerifyWebhookSignature_Rejects(t *testing.T) {
+	body := []byte(`{"id":"d1"}`)
+	cases := map[string]struct {
+		body   []byte
+		header string
+	}{
+		"wrong secret":  {body, sign("other", time.Now(), body)},
+		"tampered body": {[]byte(`{"id":"d2"}`), sign("whsec_test", time.Now(), body)},
+		"too old":       {body, sign("whsec_test", time.Now().Add(-time.Hour), body)},
+		"malformed":     {body, "v1=abc"},
+	}
+	for name, tc := range cases {
+		err := tusker.VerifyWebhookSignature(tc.body, tc.header, "whsec_test", tusker.DefaultWebhookTolerance)
+		if !errors.Is(err, tusker.ErrInvalidWebhookSignature) {
+			t.Errorf("%s: expected ErrInvalidWebhookSignature, got %v", name, err)
+		}
+	}
+}